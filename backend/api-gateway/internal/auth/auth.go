@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"shared/jwks"
+	"shared/tokenstatus"
+)
+
+// Cabeceras de identidad que el gateway inyecta hacia los microservicios.
+// Cualquier valor enviado por el cliente se descarta antes de hacer proxy; aun
+// así los servicios validan por su cuenta el JWT contra el JWKS.
+const (
+	HeaderUserID    = "X-User-ID"
+	HeaderUserEmail = "X-User-Email"
+	HeaderUserRole  = "X-User-Role"
+
+	// API key de una cuenta de servicio (también se admite como bearer)
+	HeaderAPIKey = "X-API-Key"
+	apiKeyPrefix = "vk_"
+)
+
+var (
+	errAuthorizationRequired = errors.New("Authorization header required")
+	errBearerRequired        = errors.New("Bearer token required")
+	errInvalidToken          = errors.New("Invalid token")
+	errInvalidClaims         = errors.New("Invalid token claims")
+	errAPIKeyNotAllowed      = errors.New("API keys are not accepted on this route")
+	errTokenRevoked          = errors.New("Token has been revoked")
+	errTokenStatus           = errors.New("Could not verify token")
+)
+
+// Identity representa la identidad verificada extraída del JWT
+type Identity struct {
+	UserID string
+	Email  string
+	Role   string
+}
+
+// Verifier valida los JWT emitidos por user-service con sus claves públicas y
+// le consulta si se han revocado
+type Verifier struct {
+	keys   *jwks.KeySet
+	status *tokenstatus.Checker
+}
+
+func NewVerifier(keys *jwks.KeySet, status *tokenstatus.Checker) *Verifier {
+	return &Verifier{keys: keys, status: status}
+}
+
+// Mode indica qué exige una ruta respecto al token del cliente
+type Mode string
+
+const (
+	ModeRequired Mode = "required" // token válido obligatorio
+	ModeOptional Mode = "optional" // se inyecta identidad solo si el token es válido
+	ModeNone     Mode = "none"     // solo se limpian las cabeceras de identidad
+)
+
+// ParseMode valida el modo configurado; vacío equivale a "required"
+func ParseMode(value string) (Mode, error) {
+	switch Mode(value) {
+	case "", ModeRequired:
+		return ModeRequired, nil
+	case ModeOptional, ModeNone:
+		return Mode(value), nil
+	default:
+		return "", fmt.Errorf("modo de autenticación desconocido: %q", value)
+	}
+}
+
+// Authenticate limpia las cabeceras X-User-* del cliente y, según el modo, valida
+// el bearer token e inyecta la identidad verificada. Devuelve false si abortó la petición.
+//
+// En las rutas con apiKeys las API keys de cuentas de servicio (vk_...) se
+// reenvían sin validar: el gateway no tiene acceso a ellas y las comprueba
+// user-service. En el resto de rutas se descartan.
+func (v *Verifier) Authenticate(c *gin.Context, mode Mode, apiKeys bool) bool {
+	stripIdentityHeaders(c.Request)
+
+	if _, ok := apiKeyFromRequest(c.Request); ok {
+		if !apiKeys {
+			if mode == ModeRequired {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": errAPIKeyNotAllowed.Error()})
+				return false
+			}
+			c.Request.Header.Del(HeaderAPIKey)
+			if strings.HasPrefix(c.Request.Header.Get("Authorization"), "Bearer "+apiKeyPrefix) {
+				c.Request.Header.Del("Authorization")
+			}
+			return true
+		}
+		return true
+	}
+
+	if mode == ModeNone {
+		return true
+	}
+
+	identity, err := v.identityFromRequest(c.Request)
+	if err != nil {
+		if mode == ModeRequired {
+			status := http.StatusUnauthorized
+			if errors.Is(err, errTokenStatus) {
+				status = http.StatusServiceUnavailable
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return false
+		}
+		return true
+	}
+
+	v.injectIdentity(c, identity)
+	return true
+}
+
+func (v *Verifier) identityFromRequest(r *http.Request) (*Identity, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, errAuthorizationRequired
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		return nil, errBearerRequired
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, v.keys.Keyfunc, jwt.WithValidMethods(jwks.SigningMethods))
+	if err != nil || !token.Valid {
+		return nil, errInvalidToken
+	}
+
+	userID, _ := claims["user_id"].(string)
+	role, _ := claims["role"].(string)
+	email, _ := claims["email"].(string)
+	if userID == "" || role == "" {
+		return nil, errInvalidClaims
+	}
+
+	// La firma no dice si el token se revocó (logout, sesión cerrada, cuenta desactivada)
+	var expiresAt time.Time
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		expiresAt = exp.Time
+	}
+	active, err := v.status.Active(r.Context(), tokenString, expiresAt)
+	if err != nil {
+		log.Printf("Error comprobando el estado del token: %v", err)
+		return nil, errTokenStatus
+	}
+	if !active {
+		return nil, errTokenRevoked
+	}
+
+	return &Identity{UserID: userID, Email: email, Role: role}, nil
+}
+
+func (v *Verifier) injectIdentity(c *gin.Context, identity *Identity) {
+	c.Request.Header.Set(HeaderUserID, identity.UserID)
+	c.Request.Header.Set(HeaderUserRole, identity.Role)
+	if identity.Email != "" {
+		c.Request.Header.Set(HeaderUserEmail, identity.Email)
+	}
+
+	c.Set("user_id", identity.UserID)
+	c.Set("user_role", identity.Role)
+}
+
+// stripIdentityHeaders descarta cualquier cabecera X-User-* enviada por el cliente
+func stripIdentityHeaders(r *http.Request) {
+	for name := range r.Header {
+		if strings.HasPrefix(http.CanonicalHeaderKey(name), "X-User-") {
+			r.Header.Del(name)
+		}
+	}
+}
+
+// apiKeyFromRequest extrae la API key de X-API-Key o de un bearer con prefijo vk_
+func apiKeyFromRequest(r *http.Request) (string, bool) {
+	if key := r.Header.Get(HeaderAPIKey); key != "" {
+		return key, true
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if strings.HasPrefix(token, apiKeyPrefix) {
+		return token, true
+	}
+	return "", false
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Rule define un token bucket: Burst peticiones de golpe y Rate tokens por segundo
+type Rule struct {
+	Rate  float64
+	Burst int
+}
+
+// Result es la decisión del limitador para una clave
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter consume un token de la clave indicada
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// MemoryLimiter guarda los buckets en memoria. Sirve para despliegues de una sola
+// instancia y como respaldo cuando Redis no está disponible.
+type MemoryLimiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens   float64
+	updated  time.Time
+	lastSeen time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: make(map[string]*bucket)}
+}
+
+func (m *MemoryLimiter) Allow(_ context.Context, key string, rule Rule) (Result, error) {
+	now := time.Now()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), updated: now}
+		m.buckets[key] = b
+	}
+
+	b.tokens = min(float64(rule.Burst), b.tokens+now.Sub(b.updated).Seconds()*rule.Rate)
+	b.updated = now
+	b.lastSeen = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return Result{Allowed: true, Remaining: int(b.tokens)}, nil
+	}
+
+	wait := time.Duration((1 - b.tokens) / rule.Rate * float64(time.Second))
+	return Result{Allowed: false, RetryAfter: wait}, nil
+}
+
+// Cleanup elimina periódicamente los buckets sin uso para acotar la memoria
+func (m *MemoryLimiter) Cleanup(ctx context.Context, idle time.Duration) {
+	ticker := time.NewTicker(idle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.mutex.Lock()
+			for key, b := range m.buckets {
+				if now.Sub(b.lastSeen) > idle {
+					delete(m.buckets, key)
+				}
+			}
+			m.mutex.Unlock()
+		}
+	}
+}
+
+// FallbackLimiter usa el limitador principal (Redis) y recurre al de memoria si falla
+type FallbackLimiter struct {
+	primary  Limiter
+	fallback Limiter
+
+	mutex    sync.Mutex
+	lastWarn time.Time
+}
+
+func NewFallbackLimiter(primary, fallback Limiter) *FallbackLimiter {
+	return &FallbackLimiter{primary: primary, fallback: fallback}
+}
+
+func (f *FallbackLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	result, err := f.primary.Allow(ctx, key, rule)
+	if err == nil {
+		return result, nil
+	}
+
+	f.warn(err)
+	return f.fallback.Allow(ctx, key, rule)
+}
+
+// warn registra el fallo de Redis como mucho una vez por minuto
+func (f *FallbackLimiter) warn(err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if time.Since(f.lastWarn) < time.Minute {
+		return
+	}
+	f.lastWarn = time.Now()
+	log.Printf("Rate limiting: Redis no disponible, usando límites en memoria: %v", err)
+}
@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// testRedisLimiter conecta con GATEWAY_TEST_REDIS_URL; sin ella se omite el test
+func testRedisLimiter(t *testing.T) *RedisLimiter {
+	t.Helper()
+	url := os.Getenv("GATEWAY_TEST_REDIS_URL")
+	if url == "" {
+		t.Skip("GATEWAY_TEST_REDIS_URL no definido")
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := redis.NewClient(opts)
+	t.Cleanup(func() { client.Close() })
+	return NewRedisLimiter(client)
+}
+
+// testKey evita reutilizar buckets de ejecuciones anteriores en Redis
+func testKey() string {
+	return "test:" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// checkTokenBucket comprueba el mismo comportamiento en memoria y en Redis
+func checkTokenBucket(t *testing.T, limiter Limiter) {
+	ctx := context.Background()
+	key := testKey()
+	rule := Rule{Rate: 20, Burst: 3}
+
+	// El bucket empieza lleno: Burst peticiones seguidas pasan
+	for i := 2; i >= 0; i-- {
+		result, err := limiter.Allow(ctx, key, rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != i {
+			t.Fatalf("request %d: %+v, want allowed with %d remaining", 3-i, result, i)
+		}
+	}
+
+	result, err := limiter.Allow(ctx, key, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed {
+		t.Fatal("request over the burst was allowed")
+	}
+	// A 20 tokens/s falta como mucho 50ms para el siguiente
+	if result.RetryAfter <= 0 || result.RetryAfter > 50*time.Millisecond {
+		t.Errorf("RetryAfter = %s, want (0, 50ms]", result.RetryAfter)
+	}
+
+	// Otra clave tiene su propio bucket
+	if result, err := limiter.Allow(ctx, key+":otra", rule); err != nil || !result.Allowed {
+		t.Errorf("independent key: %+v, %v", result, err)
+	}
+
+	// Pasado RetryAfter se ha repuesto un token
+	time.Sleep(result.RetryAfter + 20*time.Millisecond)
+	if result, err := limiter.Allow(ctx, key, rule); err != nil || !result.Allowed {
+		t.Errorf("after refill: %+v, %v", result, err)
+	}
+}
+
+func TestMemoryTokenBucket(t *testing.T) {
+	checkTokenBucket(t, NewMemoryLimiter())
+}
+
+func TestRedisTokenBucket(t *testing.T) {
+	limiter := testRedisLimiter(t)
+	checkTokenBucket(t, limiter)
+
+	// La clave caduca cuando el bucket se llenaría de nuevo, sin quedarse en Redis
+	key := testKey()
+	if _, err := limiter.Allow(context.Background(), key, Rule{Rate: 1, Burst: 5}); err != nil {
+		t.Fatal(err)
+	}
+	ttl, err := limiter.client.PTTL(context.Background(), limiter.prefix+key).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl <= 5*time.Second || ttl > 6*time.Second {
+		t.Errorf("TTL = %s, want burst/rate + 1s", ttl)
+	}
+}
+
+func TestFallbackWhenRedisIsDown(t *testing.T) {
+	// Nada escucha en el puerto 1: todas las llamadas a Redis fallan
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond, MaxRetries: -1})
+	defer client.Close()
+	redisLimiter := NewRedisLimiter(client)
+
+	if _, err := redisLimiter.Allow(context.Background(), "down", Rule{Rate: 1, Burst: 1}); err == nil {
+		t.Fatal("RedisLimiter.Allow() without Redis returned no error")
+	}
+
+	limiter := NewFallbackLimiter(redisLimiter, NewMemoryLimiter())
+	rule := Rule{Rate: 0.001, Burst: 1}
+	if result, err := limiter.Allow(context.Background(), "down", rule); err != nil || !result.Allowed {
+		t.Fatalf("first request: %+v, %v", result, err)
+	}
+	// El bucket en memoria sigue limitando mientras Redis no está
+	if result, err := limiter.Allow(context.Background(), "down", rule); err != nil || result.Allowed {
+		t.Errorf("second request: %+v, %v, want denied by the memory bucket", result, err)
+	}
+}
@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config es la tabla de rutas del gateway. Se carga desde YAML o JSON
+// (JSON es YAML válido) y admite ${VAR} y ${VAR:-default} en los valores.
+type Config struct {
+	Upstreams  map[string]UpstreamConfig  `yaml:"upstreams"`
+	RateLimits map[string]RateLimitConfig `yaml:"rate_limits"`
+	Routes     []RouteConfig              `yaml:"routes"`
+}
+
+// UpstreamConfig describe un microservicio al que el gateway hace proxy.
+// Se puede indicar una única url o varias instancias en targets.
+type UpstreamConfig struct {
+	URL              string        `yaml:"url"`
+	Targets          []string      `yaml:"targets"`
+	HealthPath       string        `yaml:"health_path"`       // por defecto /health
+	HealthInterval   time.Duration `yaml:"health_interval"`   // por defecto 10s
+	FailureThreshold int           `yaml:"failure_threshold"` // fallos seguidos para abrir el circuito, por defecto 5
+	OpenTimeout      time.Duration `yaml:"open_timeout"`      // tiempo con el circuito abierto, por defecto 30s
+}
+
+// Instances devuelve todas las urls configuradas del upstream
+func (u UpstreamConfig) Instances() []string {
+	var urls []string
+	if u.URL != "" {
+		urls = append(urls, u.URL)
+	}
+	for _, target := range u.Targets {
+		if target != "" {
+			urls = append(urls, target)
+		}
+	}
+	return urls
+}
+
+// RateLimitConfig define una clase de límite (token bucket) reutilizable entre rutas
+type RateLimitConfig struct {
+	Requests int           `yaml:"requests"` // tokens repuestos en cada periodo
+	Per      time.Duration `yaml:"per"`
+	Burst    int           `yaml:"burst"` // capacidad del bucket; por defecto = requests
+	Keys     []string      `yaml:"keys"`  // ip, user; por defecto ip
+}
+
+// RouteConfig asocia un prefijo de ruta (y opcionalmente métodos) a un upstream
+type RouteConfig struct {
+	Prefix    string        `yaml:"prefix"`
+	Methods   []string      `yaml:"methods"`
+	Upstream  string        `yaml:"upstream"`
+	Timeout   time.Duration `yaml:"timeout"`
+	Rewrite   *string       `yaml:"rewrite"`    // reemplaza el prefijo antes de reenviar
+	Auth      string        `yaml:"auth"`       // required (por defecto), optional, none
+	RateLimit string        `yaml:"rate_limit"` // nombre de una clase en rate_limits
+	APIKeys   bool          `yaml:"api_keys"`   // acepta API keys de cuentas de servicio (las valida el upstream)
+}
+
+// LoadConfig lee y valida la tabla de rutas desde un archivo
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo %s: %w", path, err)
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig decodifica la tabla de rutas tras expandir variables de entorno
+func ParseConfig(data []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(os.Expand(string(data), expandEnv)), &cfg); err != nil {
+		return nil, fmt.Errorf("configuración de rutas inválida: %w", err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (cfg *Config) validate() error {
+	if len(cfg.Routes) == 0 {
+		return fmt.Errorf("la tabla de rutas está vacía")
+	}
+
+	for name, upstream := range cfg.Upstreams {
+		if len(upstream.Instances()) == 0 {
+			return fmt.Errorf("upstream %q sin url ni targets", name)
+		}
+		if upstream.HealthInterval < 0 || upstream.OpenTimeout < 0 || upstream.FailureThreshold < 0 {
+			return fmt.Errorf("upstream %q: valores de salud negativos", name)
+		}
+	}
+
+	for name, limit := range cfg.RateLimits {
+		if limit.Requests <= 0 || limit.Per <= 0 {
+			return fmt.Errorf("rate_limit %q: requests y per deben ser positivos", name)
+		}
+		if limit.Burst < 0 {
+			return fmt.Errorf("rate_limit %q: burst negativo", name)
+		}
+		for _, key := range limit.Keys {
+			if key != KeyIP && key != KeyUser {
+				return fmt.Errorf("rate_limit %q: clave desconocida %q", name, key)
+			}
+		}
+	}
+
+	for i, route := range cfg.Routes {
+		if !strings.HasPrefix(route.Prefix, "/") {
+			return fmt.Errorf("ruta #%d: el prefijo debe empezar por '/'", i)
+		}
+		if _, ok := cfg.Upstreams[route.Upstream]; !ok {
+			return fmt.Errorf("ruta %s: upstream desconocido %q", route.Prefix, route.Upstream)
+		}
+		if _, ok := cfg.RateLimits[route.RateLimit]; route.RateLimit != "" && !ok {
+			return fmt.Errorf("ruta %s: rate_limit desconocido %q", route.Prefix, route.RateLimit)
+		}
+		if route.Timeout < 0 {
+			return fmt.Errorf("ruta %s: timeout negativo", route.Prefix)
+		}
+	}
+	return nil
+}
+
+// expandEnv resuelve "VAR" y "VAR:-default" para os.Expand
+func expandEnv(key string) string {
+	name, fallback, hasFallback := strings.Cut(key, ":-")
+	if value := os.Getenv(name); value != "" || !hasFallback {
+		return value
+	}
+	return fallback
+}
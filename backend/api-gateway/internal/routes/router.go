@@ -0,0 +1,186 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"api-gateway/internal/auth"
+	"api-gateway/internal/ratelimit"
+	"api-gateway/internal/upstream"
+)
+
+// Router despacha las peticiones según la tabla de rutas vigente. La tabla se
+// intercambia de forma atómica al recargar: las peticiones en curso terminan con
+// la tabla (y los proxies) con los que empezaron.
+type Router struct {
+	table    atomic.Pointer[Table]
+	verifier *auth.Verifier
+	limiter  ratelimit.Limiter
+
+	path     string // archivo de configuración; vacío = solo configuración embebida
+	fallback []byte
+}
+
+// NewRouter carga la tabla desde path o, si path está vacío, desde la configuración embebida
+func NewRouter(path string, fallback []byte, verifier *auth.Verifier, limiter ratelimit.Limiter) (*Router, error) {
+	r := &Router{verifier: verifier, limiter: limiter, path: path, fallback: fallback}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload vuelve a leer la configuración. Si es inválida se conserva la tabla anterior.
+func (r *Router) Reload() error {
+	var cfg *Config
+	var err error
+	if r.path != "" {
+		cfg, err = LoadConfig(r.path)
+	} else {
+		cfg, err = ParseConfig(r.fallback)
+	}
+	if err != nil {
+		return err
+	}
+
+	table, err := NewTable(cfg)
+	if err != nil {
+		return err
+	}
+
+	table.Inherit(r.table.Load())
+	table.Start()
+	if previous := r.table.Swap(table); previous != nil {
+		previous.Stop()
+	}
+	return nil
+}
+
+// Table devuelve la tabla vigente
+func (r *Router) Table() *Table {
+	return r.table.Load()
+}
+
+// Handle es el handler de gin para todas las rutas proxificadas
+func (r *Router) Handle(c *gin.Context) {
+	route, pathMatched := r.table.Load().Match(c.Request.Method, c.Request.URL.Path)
+	if route == nil {
+		if pathMatched {
+			c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Method not allowed"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+
+	if !r.verifier.Authenticate(c, route.Auth, route.APIKeys) {
+		return
+	}
+
+	if route.RateLimit != nil && !r.allow(c, route.RateLimit) {
+		return
+	}
+
+	req := c.Request
+	if route.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), route.Timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+	route.rewritePath(req)
+
+	route.pool.ServeHTTP(c.Writer, upstream.WithClientIP(req, c.ClientIP()))
+}
+
+// allow aplica el límite de la ruta para cada clave configurada (IP y/o usuario).
+// Si se supera, responde 429 con Retry-After en segundos.
+func (r *Router) allow(c *gin.Context, limit *RateLimit) bool {
+	for _, kind := range limit.Keys {
+		id := c.ClientIP()
+		if kind == KeyUser {
+			// Sin identidad verificada el límite por usuario se aplica por IP. Las
+			// API keys llegan sin validar: un bucket por key permitiría esquivar el
+			// límite inventando prefijos, así que cuentan como su IP.
+			if userID := c.GetString("user_id"); userID != "" {
+				id = "user:" + userID
+			}
+		}
+
+		result, err := r.limiter.Allow(c.Request.Context(), limit.Class+":"+kind+":"+id, limit.Rule)
+		if err != nil {
+			// Sin limitador disponible se deja pasar la petición
+			log.Printf("Rate limiting (%s) no disponible: %v", limit.Class, err)
+			continue
+		}
+
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Too many requests",
+				"retry_after": max(retryAfter, 1),
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// Watch recarga la tabla al recibir SIGHUP o cuando cambia el archivo de configuración
+func (r *Router) Watch(ctx context.Context, interval time.Duration) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastMod := r.modTime()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			lastMod = r.modTime()
+			r.reloadAndLog("SIGHUP")
+		case <-ticker.C:
+			if r.path == "" {
+				continue
+			}
+			if mod := r.modTime(); !mod.Equal(lastMod) {
+				lastMod = mod
+				r.reloadAndLog("cambio en " + r.path)
+			}
+		}
+	}
+}
+
+func (r *Router) reloadAndLog(reason string) {
+	if err := r.Reload(); err != nil {
+		log.Printf("Recarga de rutas (%s) fallida, se mantiene la tabla anterior: %v", reason, err)
+		return
+	}
+	log.Printf("Tabla de rutas recargada (%s): %d rutas", reason, len(r.Table().Routes()))
+}
+
+func (r *Router) modTime() time.Time {
+	if r.path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,137 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"api-gateway/internal/auth"
+	"api-gateway/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+func writeRoutes(t *testing.T, path, prefix string) {
+	t.Helper()
+	config := "upstreams: {users: {url: \"http://users:8080\", health_interval: 1h}}\n" +
+		"routes: [{prefix: " + prefix + ", upstream: users}]\n"
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func hasRoute(r *Router, prefix string) bool {
+	for _, route := range r.Table().Routes() {
+		if route.Prefix == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRouterReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	writeRoutes(t, path, "/api/v1")
+
+	r, err := NewRouter(path, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Table().Stop() })
+	first := r.Table()
+
+	writeRoutes(t, path, "/api/v2")
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !hasRoute(r, "/api/v2") || hasRoute(r, "/api/v1") {
+		t.Fatal("Reload() did not replace the table")
+	}
+	if r.Table() == first {
+		t.Fatal("Reload() modified the table in place instead of swapping it")
+	}
+
+	// Una configuración inválida no tumba la tabla vigente
+	if err := os.WriteFile(path, []byte("routes: [{prefix: /api, upstream: nadie}]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("Reload() accepted an invalid table")
+	}
+	if !hasRoute(r, "/api/v2") {
+		t.Error("invalid reload replaced the current table")
+	}
+}
+
+func TestRouterWatchReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	writeRoutes(t, path, "/api/v1")
+
+	r, err := NewRouter(path, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Table().Stop() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	// Watch compara la fecha de modificación: se fuerza una distinta
+	time.Sleep(20 * time.Millisecond)
+	writeRoutes(t, path, "/api/v2")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !hasRoute(r, "/api/v2") {
+		if time.Now().After(deadline) {
+			t.Fatal("Watch did not reload the table after the file changed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRateLimitUnvalidatedAPIKeysByIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	config := "upstreams: {users: {url: \"http://users:8080\", health_interval: 1h}}\n" +
+		"rate_limits: {api: {requests: 1, per: 1h, keys: [user]}}\n" +
+		"routes: [{prefix: /api/v1, upstream: users, rate_limit: api, api_keys: true}]\n"
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewRouter(path, nil, auth.NewVerifier(nil, nil), ratelimit.NewMemoryLimiter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Table().Stop() })
+
+	route, _ := r.Table().Match(http.MethodGet, "/api/v1/users")
+	// Autentica y aplica el límite como Handle, sin llegar al upstream
+	allowed := func(key string) (bool, int) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+key)
+		if !r.verifier.Authenticate(c, route.Auth, route.APIKeys) {
+			t.Fatalf("API key %s rejected: %d", key, w.Code)
+		}
+		return r.allow(c, route.RateLimit), w.Code
+	}
+
+	if ok, _ := allowed("vk_aaaa_secreto"); !ok {
+		t.Fatal("first request was rate limited")
+	}
+	// Una key inventada distinta desde la misma IP comparte el bucket
+	if ok, code := allowed("vk_bbbb_secreto"); ok || code != http.StatusTooManyRequests {
+		t.Errorf("request with a new key: allowed = %v, status = %d, want 429", ok, code)
+	}
+}
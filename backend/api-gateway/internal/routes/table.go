@@ -0,0 +1,202 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"api-gateway/internal/auth"
+	"api-gateway/internal/ratelimit"
+	"api-gateway/internal/upstream"
+)
+
+// Claves por las que se puede agrupar un límite de peticiones
+const (
+	KeyIP   = "ip"
+	KeyUser = "user"
+)
+
+// RateLimit es una clase de límite compilada
+type RateLimit struct {
+	Class string
+	Rule  ratelimit.Rule
+	Keys  []string
+}
+
+// Route es una entrada compilada de la tabla de rutas
+type Route struct {
+	Prefix    string
+	Methods   map[string]bool
+	Upstream  string
+	Timeout   time.Duration
+	Rewrite   *string
+	Auth      auth.Mode
+	APIKeys   bool
+	RateLimit *RateLimit
+
+	pool *upstream.Pool
+}
+
+// Table es una versión inmutable de la tabla de rutas; se reemplaza entera al recargar
+type Table struct {
+	routes []*Route
+	pools  map[string]*upstream.Pool
+}
+
+// NewTable compila la configuración, creando un pool balanceado por upstream
+func NewTable(cfg *Config) (*Table, error) {
+	pools := make(map[string]*upstream.Pool, len(cfg.Upstreams))
+	for name, uc := range cfg.Upstreams {
+		pool, err := upstream.NewPool(name, uc.Instances(), upstream.Options{
+			HealthPath:       withDefault(uc.HealthPath, "/health"),
+			HealthInterval:   withDefault(uc.HealthInterval, 10*time.Second),
+			HealthTimeout:    2 * time.Second,
+			FailureThreshold: withDefault(uc.FailureThreshold, 5),
+			OpenTimeout:      withDefault(uc.OpenTimeout, 30*time.Second),
+		})
+		if err != nil {
+			return nil, err
+		}
+		pools[name] = pool
+	}
+
+	limits := make(map[string]*RateLimit, len(cfg.RateLimits))
+	for name, lc := range cfg.RateLimits {
+		burst := lc.Burst
+		if burst == 0 {
+			burst = lc.Requests
+		}
+		keys := lc.Keys
+		if len(keys) == 0 {
+			keys = []string{KeyIP}
+		}
+		limits[name] = &RateLimit{
+			Class: name,
+			Rule:  ratelimit.Rule{Rate: float64(lc.Requests) / lc.Per.Seconds(), Burst: burst},
+			Keys:  keys,
+		}
+	}
+
+	table := &Table{pools: pools}
+	for _, rc := range cfg.Routes {
+		mode, err := auth.ParseMode(rc.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("ruta %s: %w", rc.Prefix, err)
+		}
+
+		route := &Route{
+			Prefix:   strings.TrimSuffix(rc.Prefix, "/"),
+			Upstream: rc.Upstream,
+			Timeout:  rc.Timeout,
+			Rewrite:  rc.Rewrite,
+			Auth:     mode,
+			APIKeys:  rc.APIKeys,
+			pool:     pools[rc.Upstream],
+		}
+		if rc.RateLimit != "" {
+			route.RateLimit = limits[rc.RateLimit]
+		}
+		if len(rc.Methods) > 0 {
+			route.Methods = make(map[string]bool, len(rc.Methods))
+			for _, method := range rc.Methods {
+				route.Methods[strings.ToUpper(method)] = true
+			}
+		}
+		table.routes = append(table.routes, route)
+	}
+
+	// El prefijo más largo gana; a igual prefijo, las rutas con métodos explícitos primero
+	sort.SliceStable(table.routes, func(i, j int) bool {
+		a, b := table.routes[i], table.routes[j]
+		if len(a.Prefix) != len(b.Prefix) {
+			return len(a.Prefix) > len(b.Prefix)
+		}
+		return a.Methods != nil && b.Methods == nil
+	})
+
+	return table, nil
+}
+
+// Match busca la ruta para method+path. pathMatched indica si algún prefijo
+// coincidió aunque el método no estuviera permitido (para responder 405).
+func (t *Table) Match(method, path string) (route *Route, pathMatched bool) {
+	for _, r := range t.routes {
+		if !r.matchesPath(path) {
+			continue
+		}
+		pathMatched = true
+		if r.Methods == nil || r.Methods[method] {
+			return r, true
+		}
+	}
+	return nil, pathMatched
+}
+
+// Inherit traslada a los upstreams de la tabla el estado de los del mismo
+// nombre en previous (ver upstream.Pool.Inherit)
+func (t *Table) Inherit(previous *Table) {
+	if previous == nil {
+		return
+	}
+	for name, pool := range t.pools {
+		pool.Inherit(previous.pools[name])
+	}
+}
+
+// Start arranca las sondas de salud de todos los upstreams
+func (t *Table) Start() {
+	for _, pool := range t.pools {
+		pool.Start()
+	}
+}
+
+// Stop detiene las sondas; se llama sobre la tabla anterior tras una recarga
+func (t *Table) Stop() {
+	for _, pool := range t.pools {
+		pool.Stop()
+	}
+}
+
+// Health devuelve el estado de cada upstream
+func (t *Table) Health() map[string]upstream.Status {
+	health := make(map[string]upstream.Status, len(t.pools))
+	for name, pool := range t.pools {
+		health[name] = pool.Status()
+	}
+	return health
+}
+
+// Routes devuelve las rutas en orden de evaluación
+func (t *Table) Routes() []*Route {
+	return t.routes
+}
+
+func (r *Route) matchesPath(path string) bool {
+	if r.Prefix == "" {
+		return true
+	}
+	return path == r.Prefix || strings.HasPrefix(path, r.Prefix+"/")
+}
+
+// rewritePath aplica la reescritura de prefijo configurada
+func (r *Route) rewritePath(req *http.Request) {
+	if r.Rewrite == nil {
+		return
+	}
+	rewritten := *r.Rewrite + strings.TrimPrefix(req.URL.Path, r.Prefix)
+	if rewritten == "" {
+		rewritten = "/"
+	}
+	req.URL.Path = rewritten
+	req.URL.RawPath = ""
+}
+
+func withDefault[T comparable](value, fallback T) T {
+	var zero T
+	if value == zero {
+		return fallback
+	}
+	return value
+}
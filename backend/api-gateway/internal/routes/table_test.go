@@ -0,0 +1,140 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testRoutes = `
+upstreams:
+  users: {url: "http://users:8080"}
+  calls: {url: "http://calls:8080"}
+routes:
+  - {prefix: /api/users, upstream: users}
+  - {prefix: /api/users/import, methods: [post], upstream: calls}
+  - {prefix: /api/users/import, upstream: users}
+  - {prefix: /api/calls/, upstream: calls, methods: [GET, POST]}
+  - {prefix: /ws/calls, upstream: calls, rewrite: /ws}
+  - {prefix: /legacy, upstream: users, rewrite: ""}
+`
+
+func newTestTable(t *testing.T, config string) *Table {
+	t.Helper()
+	cfg, err := ParseConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := NewTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func TestMatch(t *testing.T) {
+	table := newTestTable(t, testRoutes)
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		wantPrefix   string
+		wantUpstream string
+		wantPath     bool
+	}{
+		{"prefijo exacto", "GET", "/api/users", "/api/users", "users", true},
+		{"subruta", "DELETE", "/api/users/42", "/api/users", "users", true},
+		{"gana el prefijo más largo", "GET", "/api/users/import/status", "/api/users/import", "users", true},
+		{"a igual prefijo, primero la ruta con métodos", "POST", "/api/users/import", "/api/users/import", "calls", true},
+		{"el prefijo respeta los segmentos", "GET", "/api/usersX", "", "", false},
+		{"barra final del prefijo ignorada", "GET", "/api/calls", "/api/calls", "calls", true},
+		{"método no permitido", "DELETE", "/api/calls/1", "", "", true},
+		{"sin ruta", "GET", "/otra", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, pathMatched := table.Match(tt.method, tt.path)
+			if pathMatched != tt.wantPath {
+				t.Errorf("pathMatched = %v, want %v", pathMatched, tt.wantPath)
+			}
+			if tt.wantPrefix == "" {
+				if route != nil {
+					t.Errorf("matched %s, want no route", route.Prefix)
+				}
+				return
+			}
+			if route == nil || route.Prefix != tt.wantPrefix || route.Upstream != tt.wantUpstream {
+				t.Errorf("matched %+v, want %s -> %s", route, tt.wantPrefix, tt.wantUpstream)
+			}
+		})
+	}
+}
+
+func TestRewritePath(t *testing.T) {
+	table := newTestTable(t, testRoutes)
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"sin reescritura", "/api/users/42", "/api/users/42"},
+		{"reemplaza el prefijo", "/ws/calls/room-1", "/ws/room-1"},
+		{"prefijo vacío", "/legacy/report", "/report"},
+		{"ruta vacía pasa a /", "/legacy", "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			route, _ := table.Match(http.MethodGet, tt.path)
+			if route == nil {
+				t.Fatalf("no route for %s", tt.path)
+			}
+			route.rewritePath(req)
+			if req.URL.Path != tt.want {
+				t.Errorf("path = %s, want %s", req.URL.Path, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	t.Setenv("TEST_USERS_URL", "http://users-1:8080")
+
+	cfg, err := ParseConfig([]byte(`
+upstreams:
+  users: {url: "${TEST_USERS_URL}", targets: ["${TEST_USERS_2:-http://users-2:8080}"]}
+routes:
+  - {prefix: /api/users, upstream: users}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(cfg.Upstreams["users"].Instances(), ",")
+	if got != "http://users-1:8080,http://users-2:8080" {
+		t.Errorf("instances = %s", got)
+	}
+
+	invalid := []struct {
+		name   string
+		config string
+	}{
+		{"tabla vacía", `upstreams: {users: {url: "http://users"}}`},
+		{"upstream desconocido", `routes: [{prefix: /api, upstream: nadie}]`},
+		{"prefijo sin barra", "upstreams: {users: {url: \"http://users\"}}\nroutes: [{prefix: api, upstream: users}]"},
+		{"rate_limit desconocido", "upstreams: {users: {url: \"http://users\"}}\nroutes: [{prefix: /api, upstream: users, rate_limit: nada}]"},
+		{"clave de límite desconocida", "upstreams: {users: {url: \"http://users\"}}\nrate_limits: {auth: {requests: 1, per: 1s, keys: [pais]}}\nroutes: [{prefix: /api, upstream: users}]"},
+		{"upstream sin instancias", "upstreams: {users: {}}\nroutes: [{prefix: /api, upstream: users}]"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseConfig([]byte(tt.config)); err == nil {
+				t.Error("ParseConfig() accepted an invalid table")
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package upstream
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState es el estado del circuit breaker de una instancia
+type BreakerState string
+
+const (
+	StateClosed   BreakerState = "closed"    // tráfico normal
+	StateOpen     BreakerState = "open"      // se falla rápido sin contactar la instancia
+	StateHalfOpen BreakerState = "half_open" // se deja pasar una petición de prueba
+)
+
+// Breaker abre el circuito tras FailureThreshold fallos consecutivos y, pasado
+// OpenTimeout, deja pasar una única petición de prueba antes de cerrarlo de nuevo
+type Breaker struct {
+	failureThreshold int
+	openTimeout      time.Duration
+
+	mutex    sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewBreaker(failureThreshold int, openTimeout time.Duration) *Breaker {
+	return &Breaker{
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		state:            StateClosed,
+	}
+}
+
+// Allow indica si se puede enviar una petición a la instancia
+func (b *Breaker) Allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return true
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Ready indica, sin cambiar el estado, si Allow dejaría pasar una petición
+func (b *Breaker) Ready() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		return time.Since(b.openedAt) >= b.openTimeout
+	case StateHalfOpen:
+		return !b.probing
+	default:
+		return true
+	}
+}
+
+// Success cierra el circuito y reinicia el contador de fallos
+func (b *Breaker) Success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.state = StateClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure registra un fallo; abre el circuito al superar el umbral o si falla la prueba
+func (b *Breaker) Failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == StateHalfOpen || b.failures >= b.failureThreshold {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Release libera la petición de prueba sin resultado (p. ej. el cliente canceló)
+func (b *Breaker) Release() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.probing = false
+}
+
+// State devuelve el estado actual
+func (b *Breaker) State() BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state
+}
@@ -0,0 +1,83 @@
+package upstream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	b := NewBreaker(3, time.Minute)
+
+	// Los fallos por debajo del umbral no abren el circuito y un éxito reinicia la cuenta
+	b.Failure()
+	b.Failure()
+	b.Success()
+	b.Failure()
+	b.Failure()
+	if b.State() != StateClosed || !b.Allow() {
+		t.Fatalf("state = %s, want closed", b.State())
+	}
+
+	b.Failure()
+	if b.State() != StateOpen {
+		t.Fatalf("state = %s after reaching the threshold, want open", b.State())
+	}
+	if b.Ready() || b.Allow() {
+		t.Fatal("open breaker let a request through before OpenTimeout")
+	}
+
+	// Pasado OpenTimeout deja pasar una sola petición de prueba
+	b.openedAt = time.Now().Add(-2 * time.Minute)
+	if !b.Ready() {
+		t.Fatal("Ready() = false after OpenTimeout")
+	}
+	if !b.Allow() || b.State() != StateHalfOpen {
+		t.Fatalf("state = %s, want the probe allowed in half_open", b.State())
+	}
+	if b.Ready() || b.Allow() {
+		t.Fatal("half_open breaker allowed a second concurrent probe")
+	}
+
+	// Si la prueba falla vuelve a abrirse sin esperar al umbral
+	b.Failure()
+	if b.State() != StateOpen {
+		t.Fatalf("state = %s after a failed probe, want open", b.State())
+	}
+
+	b.openedAt = time.Now().Add(-2 * time.Minute)
+	b.Allow()
+	b.Success()
+	if b.State() != StateClosed || !b.Allow() {
+		t.Fatalf("state = %s after a successful probe, want closed", b.State())
+	}
+}
+
+func TestBreakerRelease(t *testing.T) {
+	b := NewBreaker(1, time.Minute)
+	b.Failure()
+	b.openedAt = time.Now().Add(-2 * time.Minute)
+
+	if !b.Allow() {
+		t.Fatal("probe not allowed after OpenTimeout")
+	}
+	// El cliente canceló la prueba: otra petición puede intentarla
+	b.Release()
+	if b.State() != StateHalfOpen || !b.Allow() {
+		t.Fatalf("state = %s, want a new probe allowed after Release", b.State())
+	}
+}
+
+func TestBreakerReadyDoesNotChangeState(t *testing.T) {
+	b := NewBreaker(1, time.Minute)
+	b.Failure()
+	b.openedAt = time.Now().Add(-2 * time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if !b.Ready() {
+			t.Fatal("Ready() = false after OpenTimeout")
+		}
+	}
+	if b.State() != StateOpen {
+		t.Errorf("state = %s, Ready must not start the probe", b.State())
+	}
+}
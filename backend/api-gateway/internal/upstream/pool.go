@@ -0,0 +1,305 @@
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// Options configura las sondas de salud y el circuit breaker de un pool
+type Options struct {
+	HealthPath       string
+	HealthInterval   time.Duration
+	HealthTimeout    time.Duration
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// Target es una instancia concreta de un upstream
+type Target struct {
+	URL     *url.URL
+	proxy   *httputil.ReverseProxy
+	healthy atomic.Bool
+	breaker *Breaker
+}
+
+// Pool balancea peticiones (round-robin) entre las instancias sanas de un upstream
+type Pool struct {
+	Name    string
+	targets []*Target
+	next    atomic.Uint64
+	opts    Options
+	client  *http.Client
+	cancel  context.CancelFunc
+}
+
+// attempt acompaña a la petición para reintentar sobre la original (no la
+// reescrita por el proxy) y no repetir instancias ya intentadas
+type attempt struct {
+	original *http.Request
+	tried    map[*Target]bool
+}
+
+type attemptKey struct{}
+
+type clientIPKey struct{}
+
+// WithClientIP guarda la IP del cliente ya resuelta por el gateway (con sus
+// proxies de confianza). Es la única que recibe el upstream en X-Forwarded-For:
+// la cadena enviada por el cliente se descarta para que no pueda falsearla.
+func WithClientIP(r *http.Request, ip string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip))
+}
+
+// NewPool crea el pool; las sondas no arrancan hasta llamar a Start
+func NewPool(name string, urls []string, opts Options) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("upstream %q sin instancias", name)
+	}
+
+	pool := &Pool{
+		Name:   name,
+		opts:   opts,
+		client: &http.Client{Timeout: opts.HealthTimeout},
+	}
+
+	for _, raw := range urls {
+		target, err := url.Parse(raw)
+		if err != nil || target.Scheme == "" || target.Host == "" {
+			return nil, fmt.Errorf("upstream %q: url inválida %q", name, raw)
+		}
+
+		t := &Target{URL: target, breaker: NewBreaker(opts.FailureThreshold, opts.OpenTimeout)}
+		t.healthy.Store(true) // optimista hasta la primera sonda
+		t.proxy = &httputil.ReverseProxy{Rewrite: rewriteTo(target)}
+		t.proxy.ModifyResponse = pool.observeResponse(t)
+		t.proxy.ErrorHandler = pool.handleError(t)
+		pool.targets = append(pool.targets, t)
+	}
+
+	return pool, nil
+}
+
+// rewriteTo dirige la petición a la instancia conservando el Host original y
+// sustituye X-Forwarded-For por la IP del cliente (ver WithClientIP)
+func rewriteTo(target *url.URL) func(*httputil.ProxyRequest) {
+	return func(pr *httputil.ProxyRequest) {
+		pr.SetURL(target)
+		pr.Out.Host = pr.In.Host
+		pr.Out.Header.Del("X-Real-IP")
+		if ip, ok := pr.In.Context().Value(clientIPKey{}).(string); ok && ip != "" {
+			pr.Out.Header.Set("X-Forwarded-For", ip)
+		} else {
+			pr.SetXForwarded()
+		}
+	}
+}
+
+// ServeHTTP envía la petición a una instancia sana o responde 503 en JSON
+func (p *Pool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	state, ok := r.Context().Value(attemptKey{}).(*attempt)
+	if !ok {
+		state = &attempt{tried: make(map[*Target]bool, len(p.targets))}
+		r = r.WithContext(context.WithValue(r.Context(), attemptKey{}, state))
+		state.original = r
+	}
+
+	target := p.pick(state.tried)
+	if target == nil {
+		writeError(w, http.StatusServiceUnavailable, "Service temporarily unavailable", p.Name)
+		return
+	}
+
+	state.tried[target] = true
+	target.proxy.ServeHTTP(w, state.original)
+}
+
+// pick elige la siguiente instancia sana con el circuito cerrado, saltando las
+// ya intentadas. Allow solo se llama sobre la instancia elegida: en un circuito
+// abierto reserva la petición de prueba, que no debe gastarse en una descartada.
+func (p *Pool) pick(tried map[*Target]bool) *Target {
+	start := p.next.Add(1)
+	for i := range p.targets {
+		t := p.targets[(start+uint64(i))%uint64(len(p.targets))]
+		if tried[t] || !t.healthy.Load() || !t.breaker.Ready() {
+			continue
+		}
+		// Otra petición pudo llevarse la prueba entre Ready y Allow
+		if t.breaker.Allow() {
+			return t
+		}
+	}
+	return nil
+}
+
+// observeResponse cuenta como éxito cualquier respuesta de la instancia, también
+// un 502/503/504 propio de la aplicación (p. ej. una dependencia caída): solo
+// abren el circuito los errores de transporte y los 502/504 que genera el
+// gateway en handleError
+func (p *Pool) observeResponse(t *Target) func(*http.Response) error {
+	return func(resp *http.Response) error {
+		t.breaker.Success()
+		return nil
+	}
+}
+
+// handleError se invoca cuando la instancia no responde. Las peticiones idempotentes
+// sin cuerpo se reintentan en otra instancia; el resto recibe un error JSON.
+func (p *Pool) handleError(t *Target) func(http.ResponseWriter, *http.Request, error) {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		if errors.Is(err, context.Canceled) {
+			// El cliente abandonó la petición: no es culpa de la instancia
+			t.breaker.Release()
+			return
+		}
+
+		t.breaker.Failure()
+		log.Printf("Upstream %s (%s) falló: %v", p.Name, t.URL, err)
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			writeError(w, http.StatusGatewayTimeout, "Upstream timeout", p.Name)
+			return
+		}
+
+		if state, ok := r.Context().Value(attemptKey{}).(*attempt); ok && isRetryable(state.original) {
+			p.ServeHTTP(w, state.original)
+			return
+		}
+
+		writeError(w, http.StatusBadGateway, "Upstream unavailable", p.Name)
+	}
+}
+
+func isRetryable(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return r.Body == nil || r.Body == http.NoBody
+	default:
+		return false
+	}
+}
+
+// Inherit conserva la salud y el circuit breaker de las instancias de previous
+// que siguen en este pool con las mismas opciones, para que recargar las rutas
+// no devuelva a servicio instancias caídas ni cierre circuitos abiertos. Se
+// llama antes de Start.
+func (p *Pool) Inherit(previous *Pool) {
+	if previous == nil || previous.opts != p.opts {
+		return
+	}
+
+	byURL := make(map[string]*Target, len(previous.targets))
+	for _, t := range previous.targets {
+		byURL[t.URL.String()] = t
+	}
+	for _, t := range p.targets {
+		old, ok := byURL[t.URL.String()]
+		if !ok {
+			continue
+		}
+		// El breaker se comparte: las peticiones en curso de la tabla anterior
+		// siguen contando sobre el mismo circuito
+		t.breaker = old.breaker
+		t.healthy.Store(old.healthy.Load())
+	}
+}
+
+// Start lanza las sondas periódicas a HealthPath de cada instancia
+func (p *Pool) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	for _, t := range p.targets {
+		go p.probeLoop(ctx, t)
+	}
+}
+
+// Stop detiene las sondas; las peticiones en curso no se ven afectadas
+func (p *Pool) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+}
+
+func (p *Pool) probeLoop(ctx context.Context, t *Target) {
+	ticker := time.NewTicker(p.opts.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		p.probe(ctx, t)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) probe(ctx context.Context, t *Target) {
+	healthy := false
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL.JoinPath(p.opts.HealthPath).String(), nil)
+	if err == nil {
+		resp, err := p.client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			healthy = resp.StatusCode == http.StatusOK
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	if previous := t.healthy.Swap(healthy); previous != healthy {
+		if healthy {
+			log.Printf("Upstream %s (%s) vuelve a estar sano", p.Name, t.URL)
+		} else {
+			log.Printf("Upstream %s (%s) no responde en %s", p.Name, t.URL, p.opts.HealthPath)
+		}
+	}
+}
+
+// TargetStatus es el estado de una instancia para /health
+type TargetStatus struct {
+	URL     string       `json:"url"`
+	Healthy bool         `json:"healthy"`
+	Circuit BreakerState `json:"circuit"`
+}
+
+// Status es el estado agregado de un upstream para /health
+type Status struct {
+	Healthy        bool           `json:"healthy"`
+	HealthyTargets int            `json:"healthy_targets"`
+	Targets        []TargetStatus `json:"targets"`
+}
+
+// Status informa qué instancias están sanas y el estado de su circuito
+func (p *Pool) Status() Status {
+	status := Status{}
+	for _, t := range p.targets {
+		ts := TargetStatus{URL: t.URL.String(), Healthy: t.healthy.Load(), Circuit: t.breaker.State()}
+		if ts.Healthy && ts.Circuit != StateOpen {
+			status.HealthyTargets++
+		}
+		status.Targets = append(status.Targets, ts)
+	}
+	status.Healthy = status.HealthyTargets > 0
+	return status
+}
+
+func writeError(w http.ResponseWriter, code int, message, upstream string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":    message,
+		"upstream": upstream,
+	})
+}
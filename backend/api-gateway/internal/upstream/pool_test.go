@@ -0,0 +1,177 @@
+package upstream
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestPool crea un pool de n instancias que responden con su índice
+func newTestPool(t *testing.T, n int) *Pool {
+	t.Helper()
+
+	urls := make([]string, n)
+	for i := range urls {
+		name := string(rune('a' + i))
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name)
+		}))
+		t.Cleanup(server.Close)
+		urls[i] = server.URL
+	}
+
+	pool, err := NewPool("test", urls, Options{FailureThreshold: 1, OpenTimeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func servedBy(t *testing.T, pool *Pool) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	pool.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		return ""
+	}
+	return w.Body.String()
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(pool *Pool)
+		want  string
+	}{
+		{"todas sanas", func(pool *Pool) {}, "bcabca"},
+		{"salta la instancia caída", func(pool *Pool) { pool.targets[1].healthy.Store(false) }, "ccacca"},
+		{"salta el circuito abierto", func(pool *Pool) { pool.targets[2].breaker.Failure() }, "baabaa"},
+		{"ninguna disponible", func(pool *Pool) {
+			for _, target := range pool.targets {
+				target.healthy.Store(false)
+			}
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newTestPool(t, 3)
+			tt.setup(pool)
+
+			got := ""
+			for i := 0; i < 6; i++ {
+				got += servedBy(t, pool)
+			}
+			if got != tt.want {
+				t.Errorf("served by %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolPickOnlyAllowsChosenTarget(t *testing.T) {
+	pool := newTestPool(t, 2)
+	// La instancia 0 espera su petición de prueba; la 1 está caída
+	open := pool.targets[0].breaker
+	open.Failure()
+	pool.targets[1].healthy.Store(false)
+
+	if target := pool.pick(map[*Target]bool{}); target != nil {
+		t.Fatalf("picked %s with every target unavailable", target.URL)
+	}
+	if open.State() != StateOpen {
+		t.Fatalf("state = %s, pick must not start a probe on a target it does not use", open.State())
+	}
+
+	// Pasado OpenTimeout la prueba se reserva para la instancia elegida
+	open.openedAt = time.Now().Add(-2 * time.Minute)
+	if target := pool.pick(map[*Target]bool{}); target != pool.targets[0] {
+		t.Fatalf("picked %v, want the half-open target", target)
+	}
+	if open.State() != StateHalfOpen {
+		t.Errorf("state = %s, want half_open", open.State())
+	}
+	if target := pool.pick(map[*Target]bool{}); target != nil {
+		t.Errorf("picked %s while its probe is in flight", target.URL)
+	}
+}
+
+func TestPoolUpstreamErrorsKeepCircuitClosed(t *testing.T) {
+	// Un 503 de la aplicación no es un fallo de la instancia
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	pool, err := NewPool("test", []string{server.URL}, Options{FailureThreshold: 1, OpenTimeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		pool.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("status = %d, want the upstream's 503", w.Code)
+		}
+	}
+	if state := pool.targets[0].breaker.State(); state != StateClosed {
+		t.Errorf("state = %s after upstream 503s, want closed", state)
+	}
+
+	// Una instancia que no responde sí abre el circuito
+	server.Close()
+	w := httptest.NewRecorder()
+	pool.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadGateway)
+	}
+	if state := pool.targets[0].breaker.State(); state != StateOpen {
+		t.Errorf("state = %s after a transport error, want open", state)
+	}
+}
+
+func TestPoolInherit(t *testing.T) {
+	opts := Options{FailureThreshold: 1, OpenTimeout: time.Minute}
+	previous, err := NewPool("test", []string{"http://a:8080", "http://b:8080"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous.targets[0].breaker.Failure()
+	previous.targets[1].healthy.Store(false)
+
+	tests := []struct {
+		name        string
+		urls        []string
+		opts        Options
+		wantOpen    []bool
+		wantHealthy []bool
+	}{
+		{"mismas instancias", []string{"http://a:8080", "http://b:8080"}, opts,
+			[]bool{true, false}, []bool{true, false}},
+		{"instancia nueva", []string{"http://b:8080", "http://c:8080"}, opts,
+			[]bool{false, false}, []bool{false, true}},
+		{"opciones distintas", []string{"http://a:8080", "http://b:8080"}, Options{FailureThreshold: 3, OpenTimeout: time.Minute},
+			[]bool{false, false}, []bool{true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := NewPool("test", tt.urls, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pool.Inherit(previous)
+
+			for i, target := range pool.targets {
+				if open := target.breaker.State() == StateOpen; open != tt.wantOpen[i] {
+					t.Errorf("%s open = %v, want %v", target.URL, open, tt.wantOpen[i])
+				}
+				if healthy := target.healthy.Load(); healthy != tt.wantHealthy[i] {
+					t.Errorf("%s healthy = %v, want %v", target.URL, healthy, tt.wantHealthy[i])
+				}
+			}
+		})
+	}
+}
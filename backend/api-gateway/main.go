@@ -1,20 +1,54 @@
 package main
 
 import (
+	"context"
+	_ "embed"
 	"log"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"api-gateway/internal/auth"
+	"api-gateway/internal/ratelimit"
+	"api-gateway/internal/routes"
+	"shared/jwks"
+	"shared/tokenstatus"
 )
 
+// Tabla de rutas por defecto, usada cuando no se define GATEWAY_ROUTES_FILE
+//
+//go:embed config/routes.yaml
+var defaultRoutes []byte
+
 func main() {
+	// Los JWT se validan con las claves públicas de user-service: el gateway no guarda secretos
+	jwksURL := os.Getenv("JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = strings.TrimRight(userServiceURL(), "/") + "/.well-known/jwks.json"
+	}
+	keySet := jwks.NewKeySet(jwksURL)
+	go keySet.Watch(context.Background(), 5*time.Minute)
+	// Las revocaciones (logout, sesiones cerradas, cuentas desactivadas) solo las conoce user-service
+	tokenStatus := tokenstatus.NewCheckerFromEnv(strings.TrimRight(userServiceURL(), "/") + "/api/auth/introspect")
+	verifier := auth.NewVerifier(keySet, tokenStatus)
+
 	// Configurar Gin
 	r := gin.Default()
 
+	// Solo se confía en X-Forwarded-For de los proxies indicados (nginx local por defecto)
+	trustedProxies := []string{"127.0.0.1", "::1"}
+	if value := os.Getenv("GATEWAY_TRUSTED_PROXIES"); value != "" {
+		trustedProxies = strings.Split(value, ",")
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatal("GATEWAY_TRUSTED_PROXIES inválido:", err)
+	}
+
 	// Configurar CORS (incluye dominios de desarrollo y producción)
 	r.Use(cors.New(cors.Config{
 		AllowOrigins: []string{
@@ -27,18 +61,11 @@ func main() {
 		},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		ExposeHeaders:    []string{"X-Total-Count", "X-Next-Cursor"},
 		AllowCredentials: true,
 		AllowWildcard:    true,
 	}))
 
-	// Health check endpoint
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "ok",
-			"service": "api-gateway",
-		})
-	})
-
 	// API routes placeholder
 	api := r.Group("/api/v1")
 	{
@@ -50,39 +77,40 @@ func main() {
 		})
 	}
 
-	// Configurar proxies para microservicios
-	userServiceHost := os.Getenv("USER_SERVICE_URL")
-	if userServiceHost == "" {
-		userServiceHost = "http://user-service:8080"
+	// Tabla de rutas hacia los microservicios (config/routes.yaml o GATEWAY_ROUTES_FILE)
+	router, err := routes.NewRouter(os.Getenv("GATEWAY_ROUTES_FILE"), defaultRoutes, verifier, newRateLimiter())
+	if err != nil {
+		log.Fatal("Error cargando la tabla de rutas:", err)
 	}
-	userServiceURL, _ := url.Parse(userServiceHost)
-
-	userProxy := httputil.NewSingleHostReverseProxy(userServiceURL)
-
-	// Rutas de autenticación → user-service
-	r.Any("/api/auth/*path", func(c *gin.Context) {
-		userProxy.ServeHTTP(c.Writer, c.Request)
-	})
-
-	// Rutas de usuarios → user-service
-	r.Any("/api/users/*path", func(c *gin.Context) {
-		userProxy.ServeHTTP(c.Writer, c.Request)
-	})
+	go router.Watch(context.Background(), 5*time.Second)
 
-	// Rutas de cola → user-service (sistema unificado con WebSocket)
-	r.Any("/api/queue/*path", func(c *gin.Context) {
-		userProxy.ServeHTTP(c.Writer, c.Request)
-	})
-
-	// Rutas de llamadas → user-service (unified system)
-	r.Any("/api/calls/*path", func(c *gin.Context) {
-		// All call endpoints are handled by user-service
-		userProxy.ServeHTTP(c.Writer, c.Request)
-	})
+	r.NoRoute(router.Handle)
 
-	// WebSocket → user-service
-	r.GET("/ws", func(c *gin.Context) {
-		userProxy.ServeHTTP(c.Writer, c.Request)
+	// Health check: estado del gateway y de cada upstream según las sondas activas
+	r.GET("/health", func(c *gin.Context) {
+		upstreams := router.Table().Health()
+
+		// Con algún upstream caído el gateway sigue sirviendo el resto: solo
+		// responde 503 si no queda ninguno disponible
+		healthy := 0
+		for _, upstream := range upstreams {
+			if upstream.Healthy {
+				healthy++
+			}
+		}
+		status, code := "ok", http.StatusOK
+		switch {
+		case healthy == 0 && len(upstreams) > 0:
+			status, code = "down", http.StatusServiceUnavailable
+		case healthy < len(upstreams):
+			status = "degraded"
+		}
+
+		c.JSON(code, gin.H{
+			"status":    status,
+			"service":   "api-gateway",
+			"upstreams": upstreams,
+		})
 	})
 
 	// Obtener puerto del entorno
@@ -93,11 +121,40 @@ func main() {
 
 	log.Printf("API Gateway iniciado en puerto %s", port)
 	log.Printf("Proxying:")
-	log.Printf("  /api/auth/* → user-service:8080")
-	log.Printf("  /api/users/* → user-service:8080")
-	log.Printf("  /api/queue/* → user-service:8080 (unified system)")
-	log.Printf("  /api/calls/* → user-service:8080 (unified system)")
-	log.Printf("  /ws → user-service:8080")
+	for _, route := range router.Table().Routes() {
+		log.Printf("  %s/* → %s (auth: %s)", route.Prefix, route.Upstream, route.Auth)
+	}
 
 	log.Fatal(r.Run(":" + port))
 }
+
+// newRateLimiter usa Redis (REDIS_URL) para compartir límites entre instancias y
+// recurre a buckets en memoria si Redis no está configurado o deja de responder
+func newRateLimiter() ratelimit.Limiter {
+	memory := ratelimit.NewMemoryLimiter()
+	go memory.Cleanup(context.Background(), 10*time.Minute)
+
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		log.Printf("Rate limiting: REDIS_URL no definido, usando límites en memoria")
+		return memory
+	}
+
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		log.Fatal("REDIS_URL inválido:", err)
+	}
+	opts.DialTimeout = 500 * time.Millisecond
+	opts.ReadTimeout = 200 * time.Millisecond
+	opts.WriteTimeout = 200 * time.Millisecond
+
+	return ratelimit.NewFallbackLimiter(ratelimit.NewRedisLimiter(redis.NewClient(opts)), memory)
+}
+
+// userServiceURL es la URL interna de user-service (USER_SERVICE_URL)
+func userServiceURL() string {
+	if url := os.Getenv("USER_SERVICE_URL"); url != "" {
+		return url
+	}
+	return "http://user-service:8080"
+}
@@ -0,0 +1,252 @@
+// Package audit registra en audit_logs (migración 005) los accesos a llamadas y
+// grabaciones. Las entradas se escriben en segundo plano y por lotes para no
+// añadir latencia a las peticiones.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ActionPHIAccess es la misma acción que registra user-service para los accesos
+// a datos de salud
+const ActionPHIAccess = "phi_access"
+
+const (
+	batchSize     = 100
+	flushInterval = 2 * time.Second
+	bufferSize    = 10000
+	// Un lote que falla se reintenta maxAttempts veces, doblando la espera
+	maxAttempts  = 5
+	retryBackoff = 500 * time.Millisecond
+)
+
+type entry struct {
+	id             uuid.UUID
+	userID         string
+	resourceType   string
+	resourceID     string
+	ipAddress      string
+	userAgent      string
+	details        []byte
+	responseStatus int
+	timestamp      time.Time
+	sessionID      string
+}
+
+// String representa la entrada completa para el log cuando no se puede escribir
+func (e entry) String() string {
+	return fmt.Sprintf("id=%s user_id=%s action=%s resource=%s/%s ip=%s user_agent=%q details=%s status=%d timestamp=%s session_id=%s",
+		e.id, e.userID, ActionPHIAccess, e.resourceType, e.resourceID, e.ipAddress, e.userAgent, e.details,
+		e.responseStatus, e.timestamp.Format(time.RFC3339Nano), e.sessionID)
+}
+
+// Writer acumula las entradas en memoria y las inserta en audit_logs
+type Writer struct {
+	db           *sql.DB
+	entries      chan entry
+	retryBackoff time.Duration
+	done         chan struct{}
+}
+
+func NewWriter(db *sql.DB) *Writer {
+	return &Writer{
+		db:           db,
+		entries:      make(chan entry, bufferSize),
+		retryBackoff: retryBackoff,
+		done:         make(chan struct{}),
+	}
+}
+
+// CheckChain comprueba que audit_logs tiene activo el trigger que encadena las
+// filas (migraciones 019 y 024) y si el rol de la conexión podría reescribir el
+// historial: superusuario o propietario de la tabla (migración 020)
+func CheckChain(db *sql.DB) (canRewrite bool, err error) {
+	var enabled bool
+	err = db.QueryRow(`SELECT EXISTS (
+		SELECT 1 FROM pg_trigger t JOIN pg_proc p ON p.oid = t.tgfoid
+		WHERE t.tgrelid = 'audit_logs'::regclass AND t.tgname = 'audit_logs_chain' AND t.tgenabled <> 'D'
+			AND p.pronamespace::regnamespace::text = 'audit'
+	)`).Scan(&enabled)
+	if err != nil {
+		return false, err
+	}
+	if !enabled {
+		return false, errors.New("falta el trigger audit_logs_chain o está desactivado (migraciones 019, 020 y 024)")
+	}
+	err = db.QueryRow(`SELECT r.rolsuper OR pg_has_role(c.relowner, 'USAGE')
+		FROM pg_class c, pg_roles r
+		WHERE c.oid = 'audit_logs'::regclass AND r.rolname = current_user`).Scan(&canRewrite)
+	return canRewrite, err
+}
+
+// Run escribe las entradas al completar un lote o cada flushInterval. Al
+// cancelarse ctx vacía la cola, escribe lo pendiente y cierra Done; se cancela
+// después de parar el servidor HTTP para que no lleguen entradas nuevas.
+func (w *Writer) Run(ctx context.Context) {
+	defer close(w.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]entry, 0, batchSize)
+	for {
+		select {
+		case e := <-w.entries:
+			batch = append(batch, e)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-ctx.Done():
+			w.drain(batch)
+			return
+		}
+
+		w.flush(batch)
+		batch = make([]entry, 0, batchSize)
+	}
+}
+
+// Done se cierra cuando Run ha escrito todo lo pendiente
+func (w *Writer) Done() <-chan struct{} {
+	return w.done
+}
+
+// drain escribe el lote en curso y lo que quede en la cola
+func (w *Writer) drain(batch []entry) {
+	for {
+		select {
+		case e := <-w.entries:
+			batch = append(batch, e)
+			if len(batch) >= batchSize {
+				w.flush(batch)
+				batch = make([]entry, 0, batchSize)
+			}
+		default:
+			if len(batch) > 0 {
+				w.flush(batch)
+			}
+			return
+		}
+	}
+}
+
+// flush reintenta el lote con backoff exponencial; si sigue fallando inserta
+// las entradas una a una para que una fila inválida no se lleve el resto, y
+// las que aun así no se pueden escribir quedan completas en el log
+func (w *Writer) flush(batch []entry) {
+	backoff := w.retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := w.insert(batch)
+		if err == nil {
+			return
+		}
+		if attempt == maxAttempts {
+			log.Printf("Error escribiendo %d registros de auditoría tras %d intentos, se escriben uno a uno: %v", len(batch), attempt, err)
+			break
+		}
+		log.Printf("Error escribiendo %d registros de auditoría (intento %d), reintento en %s: %v", len(batch), attempt, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	for _, e := range batch {
+		// Un lote que sí llegó a escribirse aunque fallara la respuesta da clave duplicada
+		if err := w.insert([]entry{e}); err != nil && !isUniqueViolation(err) {
+			log.Printf("Registro de auditoría perdido %s: %v", e, err)
+		}
+	}
+}
+
+// isUniqueViolation detecta el error 23505 de Postgres (unique_violation)
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// Access registra cada petición como un acceso a una llamada o, en las rutas de
+// grabaciones, a sus grabaciones. Va después de identity.RequireToken.
+func (w *Writer) Access(idParam string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		userID := c.GetString("user_id")
+		if userID == "" {
+			return
+		}
+
+		resourceType := "call"
+		if strings.Contains(c.FullPath(), "/recordings") {
+			resourceType = "recording"
+		}
+
+		request := gin.H{
+			"method": c.Request.Method,
+			"route":  c.FullPath(),
+			"path":   c.Request.URL.Path,
+		}
+		// Con un token de suplantación el acceso se atribuye al admin
+		if actorID := c.GetString("actor_id"); actorID != "" {
+			request["impersonated_user_id"] = userID
+			userID = actorID
+		}
+		details, _ := json.Marshal(request)
+
+		w.record(entry{
+			id:             uuid.New(),
+			userID:         userID,
+			resourceType:   resourceType,
+			resourceID:     c.Param(idParam),
+			ipAddress:      c.ClientIP(),
+			userAgent:      c.Request.UserAgent(),
+			details:        details,
+			responseStatus: c.Writer.Status(),
+			timestamp:      time.Now(),
+			sessionID:      c.GetString("session_id"),
+		})
+	}
+}
+
+// record encola la entrada; con la cola llena se escribe en la propia petición
+// para no perder registros
+func (w *Writer) record(e entry) {
+	select {
+	case w.entries <- e:
+	default:
+		if err := w.insert([]entry{e}); err != nil {
+			log.Printf("Error escribiendo registro de auditoría: %v", err)
+		}
+	}
+}
+
+func (w *Writer) insert(batch []entry) error {
+	const columns = 11
+	placeholders := make([]string, 0, len(batch))
+	args := make([]interface{}, 0, len(batch)*columns)
+	for i, e := range batch {
+		n := i * columns
+		placeholders = append(placeholders, fmt.Sprintf("($%d, 'user', $%d, $%d, $%d, $%d, NULLIF($%d, '')::inet, $%d, $%d, $%d, $%d, NULLIF($%d, ''))",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11))
+		args = append(args, e.id, e.userID, ActionPHIAccess, e.resourceType, e.resourceID,
+			e.ipAddress, e.userAgent, string(e.details), e.responseStatus, e.timestamp, e.sessionID)
+	}
+
+	_, err := w.db.Exec(`
+		INSERT INTO audit_logs (id, principal_type, user_id, action, resource_type, resource_id,
+			ip_address, user_agent, request_details, response_status, timestamp, session_id)
+		VALUES `+strings.Join(placeholders, ", "), args...)
+	return err
+}
@@ -95,7 +95,7 @@ func (h *CallHandlers) GetActiveCalls(c *gin.Context) {
 			FROM calls c
 			LEFT JOIN users p ON c.patient_id = p.id
 			LEFT JOIN users e ON c.employee_id = e.id
-			INNER JOIN family_relationships fr ON c.patient_id = fr.patient_id
+			INNER JOIN family_relationships fr ON c.patient_id = fr.patient_id AND fr.status = 'approved'
 			WHERE fr.family_member_id = $1 
 			AND c.status IN ('active', 'waiting')
 			AND p.livestream_consent = true
@@ -209,7 +209,7 @@ func (h *CallHandlers) GetCallRecordings(c *gin.Context) {
 				       r.duration_seconds, r.status, r.storage_type, r.created_at
 				FROM call_recordings r
 				INNER JOIN calls c ON r.call_id = c.id
-				INNER JOIN family_relationships fr ON c.patient_id = fr.patient_id
+				INNER JOIN family_relationships fr ON c.patient_id = fr.patient_id AND fr.status = 'approved'
 				WHERE fr.family_member_id = $1 
 				AND r.status = 'completed'
 				ORDER BY r.created_at DESC
@@ -363,7 +363,7 @@ func (h *CallHandlers) checkRecordingAccess(userID, userRole, callID string) boo
 		var count int
 		h.db.QueryRow(`
 			SELECT COUNT(*) FROM calls c
-			INNER JOIN family_relationships fr ON c.patient_id = fr.patient_id
+			INNER JOIN family_relationships fr ON c.patient_id = fr.patient_id AND fr.status = 'approved'
 			WHERE c.id = $1 AND fr.family_member_id = $2
 		`, callID, userID).Scan(&count)
 		return count > 0
@@ -389,7 +389,7 @@ func (h *CallHandlers) checkLivestreamAccess(userID, userRole, callID string) bo
 		var count int
 		h.db.QueryRow(`
 			SELECT COUNT(*) FROM calls c
-			INNER JOIN family_relationships fr ON c.patient_id = fr.patient_id
+			INNER JOIN family_relationships fr ON c.patient_id = fr.patient_id AND fr.status = 'approved'
 			INNER JOIN users u ON c.patient_id = u.id
 			WHERE c.id = $1 AND fr.family_member_id = $2 AND u.livestream_consent = true
 		`, callID, userID).Scan(&count)
@@ -406,7 +406,7 @@ func (h *CallHandlers) NotifyCallStart(callID, patientID string) {
 		SELECT fr.family_member_id, u.email, u.first_name
 		FROM family_relationships fr
 		INNER JOIN users u ON fr.family_member_id = u.id
-		WHERE fr.patient_id = $1
+		WHERE fr.patient_id = $1 AND fr.status = 'approved'
 	`, patientID)
 	
 	if err != nil {
@@ -0,0 +1,107 @@
+package identity
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"shared/jwks"
+	"shared/tokenstatus"
+)
+
+// Cabeceras de identidad que leen los handlers. Se sobrescriben con los claims
+// del JWT validado, así que un valor enviado por el cliente nunca se usa.
+const (
+	HeaderUserID    = "X-User-ID"
+	HeaderUserEmail = "X-User-Email"
+	HeaderUserRole  = "X-User-Role"
+)
+
+// RequireToken valida el bearer token con las claves públicas de user-service
+// (JWKS), comprueba con user-service que no se ha revocado y carga la identidad
+// en el contexto y en las cabeceras X-User-*
+func RequireToken(keys *jwks.KeySet, status *tokenstatus.Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString == "" || tokenString == c.GetHeader("Authorization") {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Usuario no autenticado"})
+			return
+		}
+
+		claims := jwt.MapClaims{}
+		token, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc, jwt.WithValidMethods(jwks.SigningMethods))
+		if err != nil || !token.Valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+			return
+		}
+
+		// La firma no dice si el token se revocó (logout, sesión cerrada, cuenta desactivada)
+		var expiresAt time.Time
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			expiresAt = exp.Time
+		}
+		active, err := status.Active(c.Request.Context(), tokenString, expiresAt)
+		if err != nil {
+			log.Printf("Error comprobando el estado del token: %v", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "No se pudo comprobar el token"})
+			return
+		}
+		if !active {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token revocado"})
+			return
+		}
+
+		userID, _ := claims["user_id"].(string)
+		role, _ := claims["role"].(string)
+		email, _ := claims["email"].(string)
+		if userID == "" || role == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token sin identidad de usuario"})
+			return
+		}
+
+		c.Request.Header.Set(HeaderUserID, userID)
+		c.Request.Header.Set(HeaderUserRole, role)
+		c.Request.Header.Set(HeaderUserEmail, email)
+
+		// Token de suplantación de un admin (claim act): sin grabaciones ni
+		// livestream y, salvo imp_mode=write, de solo lectura
+		if act, ok := claims["act"].(map[string]interface{}); ok {
+			actorID, _ := act["sub"].(string)
+			if actorID == "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token de suplantación inválido"})
+				return
+			}
+			route := c.FullPath()
+			if strings.Contains(route, "recording") || strings.Contains(route, "livestream") || route == "/api/v1/calls/token" {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "No disponible durante una suplantación"})
+				return
+			}
+			if claims["imp_mode"] != "write" && !safeMethod(c.Request.Method) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "La suplantación es de solo lectura"})
+				return
+			}
+			c.Set("actor_id", actorID)
+		}
+
+		c.Set("user_id", userID)
+		c.Set("user_role", role)
+		// sid es la sesión de user-service; se registra en la auditoría
+		if sid, ok := claims["sid"].(string); ok {
+			c.Set("session_id", sid)
+		}
+		c.Next()
+	}
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,20 +1,39 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
+	"github.com/livekit/protocol/auth"
+	lkproto "github.com/livekit/protocol/livekit"
+	"google.golang.org/protobuf/encoding/protojson"
 	
+	"call-service/internal/audit"
 	"call-service/internal/handlers"
+	"call-service/internal/identity"
 	"call-service/internal/livekit"
+	"shared/jwks"
+	"shared/proxies"
+	"shared/tokenstatus"
 )
 
+// shutdownTimeout es lo que se espera al parar a las peticiones en curso y a
+// que se escriba la auditoría pendiente
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Configurar base de datos
 	dbHost := getEnv("DB_HOST", "postgres")
@@ -44,12 +63,52 @@ func main() {
 	
 	lkManager := livekit.NewLiveKitManager(livekitURL, livekitAPIKey, livekitAPISecret)
 
+	// Los JWT se validan con las claves públicas de user-service (JWKS)
+	keySet := jwks.NewKeySet(getEnv("JWKS_URL", "http://user-service:8080/.well-known/jwks.json"))
+	go keySet.Watch(context.Background(), 5*time.Minute)
+	// Las revocaciones de tokens se consultan a user-service
+	tokenStatus := tokenstatus.NewCheckerFromEnv("http://user-service:8080/api/auth/introspect")
+
+	// Auditoría de accesos a llamadas y grabaciones, escrita por lotes en segundo
+	// plano con el rol vincula_audit_writer (AUDIT_DATABASE_URL, migración 020)
+	auditDB := db
+	if auditURL := os.Getenv("AUDIT_DATABASE_URL"); auditURL != "" {
+		auditDB, err = sql.Open("postgres", auditURL)
+		if err != nil {
+			log.Fatal("Error conectando con la base de datos de auditoría:", err)
+		}
+		defer auditDB.Close()
+	} else if gin.Mode() == gin.ReleaseMode {
+		log.Fatal("AUDIT_DATABASE_URL es obligatoria en producción")
+	} else {
+		log.Println("AUDIT_DATABASE_URL no configurada, la auditoría se escribe con la conexión principal")
+	}
+	canRewrite, err := audit.CheckChain(auditDB)
+	if err != nil {
+		log.Fatal("Auditoría no disponible:", err)
+	}
+	if canRewrite {
+		if gin.Mode() == gin.ReleaseMode {
+			log.Fatal("El rol de auditoría es propietario de audit_logs o superusuario, usa vincula_audit_writer")
+		}
+		log.Println("Aviso: el rol de auditoría es propietario de audit_logs o superusuario y podría reescribir el historial")
+	}
+	auditWriter := audit.NewWriter(auditDB)
+	auditCtx, stopAudit := context.WithCancel(context.Background())
+	go auditWriter.Run(auditCtx)
+
 	// Configurar handlers
 	callHandlers := handlers.NewCallHandlers(db, lkManager)
 
 	// Configurar Gin
 	r := gin.Default()
 
+	// Solo se confía en X-Forwarded-For del gateway, que lo sobrescribe con la IP
+	// del cliente; la auditoría registra esa IP
+	if err := r.SetTrustedProxies(proxies.FromEnv("TRUSTED_PROXIES", "api-gateway")); err != nil {
+		log.Fatal("TRUSTED_PROXIES inválido:", err)
+	}
+
 	// CORS manejado por API Gateway - no configurar aquí para evitar conflictos
 
 	// Health check endpoint
@@ -73,6 +132,7 @@ func main() {
 
 		// Call management endpoints
 		calls := api.Group("/calls")
+		calls.Use(identity.RequireToken(keySet, tokenStatus), auditWriter.Access("callId"))
 		{
 			// Obtener llamadas activas (con permisos según rol)
 			calls.GET("/active", callHandlers.GetActiveCalls)
@@ -95,7 +155,7 @@ func main() {
 		}
 
 		// LiveKit webhook endpoint para eventos
-		api.POST("/livekit/webhook", handleLiveKitWebhook(db))
+		api.POST("/livekit/webhook", handleLiveKitWebhook(db, auth.NewSimpleKeyProvider(livekitAPIKey, livekitAPISecret)))
 	}
 
 	// Obtener puerto del entorno
@@ -107,7 +167,31 @@ func main() {
 	log.Printf("Grabación automática: ACTIVADA")
 	log.Printf("Livestreaming: ACTIVADO")
 	
-	log.Fatal(r.Run(":" + port))
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// Al parar se terminan las peticiones en curso y después se vacía la cola de
+	// auditoría, para no perder los accesos que ya se han servido
+	shutdown, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-shutdown.Done()
+	log.Println("Deteniendo Call Service")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Error deteniendo el servidor HTTP: %v", err)
+	}
+	stopAudit()
+	select {
+	case <-auditWriter.Done():
+	case <-ctx.Done():
+		log.Println("Tiempo agotado escribiendo la auditoría pendiente")
+	}
 }
 
 // handleCreateCall maneja la creación de nuevas llamadas
@@ -125,18 +209,50 @@ func handleCreateCall(db *sql.DB, lkManager *livekit.LiveKitManager) gin.Handler
 			return
 		}
 
+		// Solo el personal crea llamadas; un empleado sin employee_id se asigna la suya
+		userRole := c.GetString("user_role")
+		if userRole != "employee" && userRole != "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Solo el personal puede crear llamadas"})
+			return
+		}
+		if req.EmployeeID == "" && userRole == "employee" {
+			req.EmployeeID = c.GetString("user_id")
+		}
+
+		var employeeID sql.NullString
+		if req.EmployeeID != "" {
+			var exists bool
+			err := db.QueryRow(`
+				SELECT EXISTS (SELECT 1 FROM users
+				WHERE id = $1 AND role IN ('employee', 'admin') AND is_active AND deleted_at IS NULL)
+			`, req.EmployeeID).Scan(&exists)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar el empleado"})
+				return
+			}
+			if !exists {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Empleado no encontrado"})
+				return
+			}
+			employeeID = sql.NullString{String: req.EmployeeID, Valid: true}
+		}
+
 		// Verificar consentimientos del paciente
 		var recordingConsent, livestreamConsent bool
 		err := db.QueryRow(`
 			SELECT recording_consent, livestream_consent 
 			FROM users 
-			WHERE id = $1
+			WHERE id = $1 AND role = 'patient' AND deleted_at IS NULL
 		`, req.PatientID).Scan(&recordingConsent, &livestreamConsent)
 
-		if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Paciente no encontrado"})
 			return
 		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar el paciente"})
+			return
+		}
 
 		if !recordingConsent || !livestreamConsent {
 			c.JSON(http.StatusForbidden, gin.H{
@@ -158,7 +274,7 @@ func handleCreateCall(db *sql.DB, lkManager *livekit.LiveKitManager) gin.Handler
 			INSERT INTO calls (id, room_id, patient_id, employee_id, status, priority, reason, 
 			                  is_recording, is_livestreaming, created_at)
 			VALUES ($1, $2, $3, $4, 'waiting', $5, $6, true, $7, NOW())
-		`, callID, room.Name, req.PatientID, req.EmployeeID, req.Priority, req.Reason, livestreamConsent)
+		`, callID, room.Name, req.PatientID, employeeID, req.Priority, req.Reason, livestreamConsent)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al registrar llamada"})
@@ -196,17 +312,57 @@ func handleGetToken(db *sql.DB, lkManager *livekit.LiveKitManager) gin.HandlerFu
 			return
 		}
 
+		// El token es siempre para el usuario autenticado y con su rol
+		userID := c.GetString("user_id")
+		userRole := c.GetString("user_role")
+		if req.ParticipantID != userID || req.Role != userRole {
+			c.JSON(http.StatusForbidden, gin.H{"error": "El participante no coincide con el usuario autenticado"})
+			return
+		}
+
 		// Obtener room_id de la base de datos
-		var roomID string
-		err := db.QueryRow("SELECT room_id FROM calls WHERE id = $1", req.CallID).Scan(&roomID)
-		if err != nil {
+		// employee_id es NULL hasta que un empleado atiende la llamada
+		var roomID, patientID string
+		var employeeID sql.NullString
+		err := db.QueryRow("SELECT room_id, patient_id, employee_id FROM calls WHERE id = $1 AND status = 'active'", req.CallID).
+			Scan(&roomID, &patientID, &employeeID)
+		if errors.Is(err, sql.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Llamada no encontrada"})
 			return
 		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al buscar la llamada"})
+			return
+		}
+
+		// Solo entran los participantes, los admins y los familiares con
+		// vínculo aprobado con el paciente
+		allowed := false
+		switch userRole {
+		case "admin":
+			allowed = true
+		case "patient":
+			allowed = userID == patientID
+		case "employee":
+			allowed = employeeID.Valid && userID == employeeID.String
+		case "family":
+			var count int
+			err := db.QueryRow(`SELECT COUNT(*) FROM family_relationships
+				WHERE patient_id = $1 AND family_member_id = $2 AND status = 'approved'`, patientID, userID).Scan(&count)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al verificar el vínculo familiar"})
+				return
+			}
+			allowed = count > 0
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "No autorizado para esta llamada"})
+			return
+		}
 
 		// Si es observador (livestream), usar permisos restringidos
 		role := livekit.UserRole(req.Role)
-		if req.IsObserver {
+		if req.IsObserver || userRole == "family" {
 			role = livekit.RoleFamily // Forzar modo observador
 		}
 
@@ -226,57 +382,58 @@ func handleGetToken(db *sql.DB, lkManager *livekit.LiveKitManager) gin.HandlerFu
 	}
 }
 
-// handleLiveKitWebhook procesa eventos de LiveKit
-func handleLiveKitWebhook(db *sql.DB) gin.HandlerFunc {
+// handleLiveKitWebhook procesa eventos de LiveKit. La ruta es pública en el
+// gateway: solo se aceptan eventos firmados con la API key/secret de LiveKit
+// (cabecera Authorization con el sha256 del cuerpo)
+func handleLiveKitWebhook(db *sql.DB, keys auth.KeyProvider) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var event struct {
-			Event string                 `json:"event"`
-			Room  map[string]interface{} `json:"room"`
-			Participant map[string]interface{} `json:"participant"`
-			EgressInfo map[string]interface{} `json:"egress_info"`
+		// Se verifica la firma antes de interpretar el cuerpo
+		data, err := verifyLiveKitWebhook(c.Request, keys)
+		if err != nil {
+			log.Printf("Webhook de LiveKit rechazado: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+			return
 		}
 
-		if err := c.ShouldBindJSON(&event); err != nil {
+		var event lkproto.WebhookEvent
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, &event); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook data"})
 			return
 		}
 
 		// Procesar diferentes tipos de eventos
-		switch event.Event {
+		switch event.GetEvent() {
 		case "room_started":
 			// Actualizar estado de la llamada a activa
-			roomName := event.Room["name"].(string)
 			db.Exec(`
 				UPDATE calls SET status = 'active', started_at = NOW() 
 				WHERE room_id = $1
-			`, roomName)
+			`, event.GetRoom().GetName())
 			
 		case "room_finished":
 			// Actualizar estado de la llamada a completada
-			roomName := event.Room["name"].(string)
 			db.Exec(`
 				UPDATE calls 
 				SET status = 'completed', ended_at = NOW(), 
 				    duration_seconds = EXTRACT(EPOCH FROM (NOW() - started_at))
 				WHERE room_id = $1
-			`, roomName)
+			`, event.GetRoom().GetName())
 			
 		case "recording_started":
 			// Actualizar estado de grabación
-			egressID := event.EgressInfo["egress_id"].(string)
-			roomName := event.EgressInfo["room_name"].(string)
+			egress := event.GetEgressInfo()
 			db.Exec(`
 				UPDATE calls SET egress_id = $1, is_recording = true 
 				WHERE room_id = $2
-			`, egressID, roomName)
+			`, egress.GetEgressId(), egress.GetRoomName())
 			
 		case "recording_ended":
 			// Actualizar información de grabación
-			egressID := event.EgressInfo["egress_id"].(string)
-			if fileInfo, ok := event.EgressInfo["file"].(map[string]interface{}); ok {
-				filePath := fileInfo["filepath"].(string)
-				fileSize := int64(fileInfo["size"].(float64))
-				duration := int(fileInfo["duration"].(float64))
+			egress := event.GetEgressInfo()
+			if file := egress.GetFile(); file != nil {
+				filePath := file.GetFilename()
+				fileSize := file.GetSize()
+				duration := int(time.Duration(file.GetDuration()).Seconds())
 				
 				// Actualizar registro de grabación
 				db.Exec(`
@@ -284,7 +441,7 @@ func handleLiveKitWebhook(db *sql.DB) gin.HandlerFunc {
 					SET recording_url = $1, recording_size_bytes = $2, 
 					    duration_seconds = $3, status = 'completed', ended_at = NOW()
 					WHERE egress_id = $4
-				`, filePath, fileSize, duration, egressID)
+				`, filePath, fileSize, duration, egress.GetEgressId())
 				
 				// Actualizar llamada
 				db.Exec(`
@@ -292,7 +449,7 @@ func handleLiveKitWebhook(db *sql.DB) gin.HandlerFunc {
 					SET is_recording = false, recording_url = $1, 
 					    recording_size_bytes = $2
 					WHERE egress_id = $3
-				`, filePath, fileSize, egressID)
+				`, filePath, fileSize, egress.GetEgressId())
 			}
 		}
 
@@ -300,6 +457,36 @@ func handleLiveKitWebhook(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
+// verifyLiveKitWebhook lee el cuerpo y comprueba que la cabecera Authorization
+// es un token firmado con una API key conocida que incluye su sha256
+func verifyLiveKitWebhook(r *http.Request, keys auth.KeyProvider) ([]byte, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, errors.New("falta la cabecera Authorization")
+	}
+	verifier, err := auth.ParseAPIToken(header)
+	if err != nil {
+		return nil, err
+	}
+	secret := keys.GetSecret(verifier.APIKey())
+	if secret == "" {
+		return nil, errors.New("API key de LiveKit desconocida")
+	}
+	claims, err := verifier.Verify(secret)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if claims.Sha256 != base64.StdEncoding.EncodeToString(sum[:]) {
+		return nil, errors.New("el sha256 del cuerpo no coincide con la firma")
+	}
+	return data, nil
+}
+
 // Funciones auxiliares
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
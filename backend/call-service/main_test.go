@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/livekit/protocol/auth"
+)
+
+// signWebhook firma el cuerpo como LiveKit: token de la API key con su sha256
+func signWebhook(t *testing.T, key, secret string, body []byte) string {
+	t.Helper()
+	sum := sha256.Sum256(body)
+	token, err := auth.NewAccessToken(key, secret).
+		SetValidFor(5 * time.Minute).
+		SetSha256(base64.StdEncoding.EncodeToString(sum[:])).
+		ToJWT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestLiveKitWebhookSignature(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const key, secret = "devkey", "secreto-de-livekit-para-los-tests"
+	// Sin sala conocida el evento no toca la base de datos
+	event := []byte(`{"event": "participant_joined", "room": {"name": "call_1"}}`)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature func() string
+		want      int
+	}{
+		{"sin firma", event, func() string { return "" }, http.StatusUnauthorized},
+		{"firma con otro secreto", event, func() string {
+			return signWebhook(t, key, "otro-secreto-que-no-es-el-de-livekit", event)
+		}, http.StatusUnauthorized},
+		{"API key desconocida", event, func() string {
+			return signWebhook(t, "otra-key", secret, event)
+		}, http.StatusUnauthorized},
+		{"cuerpo alterado", []byte(`{"event": "room_finished", "room": {"name": "call_1"}}`), func() string {
+			return signWebhook(t, key, secret, event)
+		}, http.StatusUnauthorized},
+		{"firmado pero mal formado", []byte(`{"room": "call_1"}`), func() string {
+			return signWebhook(t, key, secret, []byte(`{"room": "call_1"}`))
+		}, http.StatusBadRequest},
+		{"firmado", event, func() string { return signWebhook(t, key, secret, event) }, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.POST("/webhook", handleLiveKitWebhook(nil, auth.NewSimpleKeyProvider(key, secret)))
+
+			req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(tt.body))
+			if signature := tt.signature(); signature != "" {
+				req.Header.Set("Authorization", signature)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateCallRequiresStaff(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := []byte(`{"patient_id": "6f1c2f9e-8a4b-4c1d-9e2f-3a4b5c6d7e8f"}`)
+
+	// Los roles sin permiso se rechazan antes de consultar la base de datos
+	for _, role := range []string{"patient", "family", ""} {
+		t.Run(role, func(t *testing.T) {
+			r := gin.New()
+			r.POST("/create", func(c *gin.Context) {
+				c.Set("user_id", "6f1c2f9e-8a4b-4c1d-9e2f-3a4b5c6d7e8f")
+				c.Set("user_role", role)
+			}, handleCreateCall(nil, nil))
+
+			req := httptest.NewRequest(http.MethodPost, "/create", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
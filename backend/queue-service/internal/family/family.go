@@ -0,0 +1,81 @@
+// Package family comprueba con user-service si un familiar tiene un vínculo
+// aprobado con un paciente. queue-service no tiene base de datos, así que
+// consulta GET /api/family/links con el token del propio familiar: solo
+// devuelve sus vínculos.
+package family
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+const requestTimeout = 3 * time.Second
+
+// ErrUnavailable indica que no se pudo consultar a user-service: el vínculo no
+// se da por aprobado
+var ErrUnavailable = errors.New("could not check family links")
+
+// Checker consulta los vínculos familiares en user-service
+type Checker struct {
+	url    string
+	client *http.Client
+}
+
+type link struct {
+	PatientID string `json:"patient_id"`
+	Status    string `json:"status"`
+}
+
+// NewChecker usa el endpoint de vínculos de user-service (url completa)
+func NewChecker(url string) *Checker {
+	return &Checker{url: url, client: &http.Client{Timeout: requestTimeout}}
+}
+
+// NewCheckerFromEnv lee FAMILY_LINKS_URL (por defecto defaultURL)
+func NewCheckerFromEnv(defaultURL string) *Checker {
+	url := os.Getenv("FAMILY_LINKS_URL")
+	if url == "" {
+		url = defaultURL
+	}
+	return NewChecker(url)
+}
+
+// Approved indica si el dueño del token tiene un vínculo aprobado con el
+// paciente. Devuelve ErrUnavailable si user-service no responde o falla.
+func (c *Checker) Approved(ctx context.Context, token, patientID string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: user-service returned %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	var links []link
+	if err := json.NewDecoder(resp.Body).Decode(&links); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	for _, l := range links {
+		if l.PatientID == patientID && l.Status == "approved" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
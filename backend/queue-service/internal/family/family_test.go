@@ -0,0 +1,58 @@
+package family
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestApproved(t *testing.T) {
+	const links = `[
+		{"patient_id": "p-aprobado", "status": "approved"},
+		{"patient_id": "p-pendiente", "status": "pending"},
+		{"patient_id": "p-rechazado", "status": "rejected"}
+	]`
+
+	tests := []struct {
+		name      string
+		status    int
+		patientID string
+		want      bool
+		wantErr   bool
+	}{
+		{"vínculo aprobado", http.StatusOK, "p-aprobado", true, false},
+		{"solicitud pendiente", http.StatusOK, "p-pendiente", false, false},
+		{"solicitud rechazada", http.StatusOK, "p-rechazado", false, false},
+		{"sin vínculo", http.StatusOK, "p-otro", false, false},
+		{"no es familiar", http.StatusForbidden, "p-aprobado", false, false},
+		{"user-service falla", http.StatusInternalServerError, "p-aprobado", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer token" {
+					t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+				}
+				w.WriteHeader(tt.status)
+				if tt.status == http.StatusOK {
+					w.Write([]byte(links))
+				}
+			}))
+			defer server.Close()
+
+			got, err := NewChecker(server.URL).Approved(context.Background(), "token", tt.patientID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Approved() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrUnavailable) {
+				t.Errorf("Approved() error = %v, want ErrUnavailable", err)
+			}
+			if got != tt.want {
+				t.Errorf("Approved() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"queue-service/internal/family"
+	"queue-service/internal/identity"
+	"shared/jwks"
+	"shared/proxies"
+	"shared/tokenstatus"
 )
 
 // Estructuras de datos simples
@@ -37,10 +45,29 @@ type JoinQueueResponse struct {
 var queue []QueueEntry
 var nextID int = 1
 
+// familyLinks comprueba en user-service los vínculos de los familiares
+var familyLinks *family.Checker
+
 func main() {
+	// Los JWT se validan con las claves públicas de user-service (JWKS)
+	jwksURL := os.Getenv("JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = "http://user-service:8080/.well-known/jwks.json"
+	}
+	keySet := jwks.NewKeySet(jwksURL)
+	go keySet.Watch(context.Background(), 5*time.Minute)
+	// Las revocaciones de tokens se consultan a user-service
+	tokenStatus := tokenstatus.NewCheckerFromEnv("http://user-service:8080/api/auth/introspect")
+	familyLinks = family.NewCheckerFromEnv("http://user-service:8080/api/family/links")
+
 	// Configurar Gin
 	r := gin.Default()
 
+	// Solo se confía en X-Forwarded-For del gateway, que lo sobrescribe con la IP del cliente
+	if err := r.SetTrustedProxies(proxies.FromEnv("TRUSTED_PROXIES", "api-gateway")); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+
 	// CORS manejado por API Gateway - no configurar aquí para evitar conflictos
 
 	// Health check endpoint
@@ -64,6 +91,7 @@ func main() {
 
 	// Endpoints de la cola
 	queueAPI := r.Group("/api/queue")
+	queueAPI.Use(identity.RequireToken(keySet, tokenStatus))
 	{
 		queueAPI.POST("/join", handleJoinQueue)
 		queueAPI.POST("/leave", handleLeaveQueue)
@@ -91,6 +119,10 @@ func handleJoinQueue(c *gin.Context) {
 		return
 	}
 
+	if !authorizePatient(c, req.PatientID) {
+		return
+	}
+
 	// Verificar si el paciente ya está en la cola
 	for _, entry := range queue {
 		if entry.PatientID == req.PatientID && entry.Status == "waiting" {
@@ -145,6 +177,10 @@ func handleLeaveQueue(c *gin.Context) {
 		return
 	}
 
+	if !authorizePatient(c, req.PatientID) {
+		return
+	}
+
 	// Buscar y remover al paciente de la cola
 	for i, entry := range queue {
 		if entry.PatientID == req.PatientID {
@@ -253,6 +289,39 @@ func handleAssignSpecificCall(c *gin.Context) {
 }
 
 // Funciones auxiliares
+// canActForPatient permite al personal operar sobre cualquier paciente, a un
+// paciente solo sobre sí mismo y a un familiar sobre los pacientes con los que
+// tiene un vínculo aprobado. El resto de roles no puede.
+func canActForPatient(c *gin.Context, patientID string) (bool, error) {
+	switch c.GetString("user_role") {
+	case "employee", "admin":
+		return true, nil
+	case "patient":
+		return c.GetString("user_id") == patientID, nil
+	case "family":
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		return familyLinks.Approved(c.Request.Context(), token, patientID)
+	default:
+		return false, nil
+	}
+}
+
+// authorizePatient responde 403 (o 503 si no se pudo comprobar el vínculo) y
+// devuelve false cuando el usuario no puede operar sobre el paciente
+func authorizePatient(c *gin.Context, patientID string) bool {
+	allowed, err := canActForPatient(c, patientID)
+	if err != nil {
+		log.Printf("Error checking family link for patient %s: %v", patientID, err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Could not verify family link"})
+		return false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to act for this patient"})
+		return false
+	}
+	return true
+}
+
 func updatePositions() {
 	for i := range queue {
 		queue[i].Position = i + 1
@@ -0,0 +1,213 @@
+// Package jwks valida los JWT de user-service en el resto de servicios con las
+// claves públicas de /.well-known/jwks.json
+package jwks
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningMethods son los algoritmos con los que user-service firma los JWT; se
+// pasan a jwt.WithValidMethods
+var SigningMethods = []string{"RS256", "EdDSA"}
+
+const (
+	// Un kid desconocido provoca una recarga del JWKS, como mucho una vez por intervalo
+	jwksRefreshCooldown = 10 * time.Second
+	jwksFetchTimeout    = 5 * time.Second
+)
+
+var errUnknownKey = errors.New("clave de firma desconocida")
+
+// KeySet cachea las claves públicas publicadas por user-service en
+// /.well-known/jwks.json. Quien lo usa solo valida firmas: no guarda ningún secreto.
+type KeySet struct {
+	url    string
+	client *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]publicKey
+	lastAttempt time.Time
+
+	refreshMu sync.Mutex
+}
+
+type publicKey struct {
+	alg string
+	key interface{}
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+func NewKeySet(url string) *KeySet {
+	return &KeySet{
+		url:    url,
+		client: &http.Client{Timeout: jwksFetchTimeout},
+		keys:   make(map[string]publicKey),
+	}
+}
+
+// Keyfunc resuelve la clave pública por kid para jwt.Parse
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errUnknownKey
+	}
+
+	key, ok := k.lookup(kid)
+	if !ok {
+		// Puede ser una clave recién rotada: recargar el JWKS
+		if err := k.refreshIfStale(); err != nil {
+			log.Printf("Error recargando JWKS: %v", err)
+		}
+		if key, ok = k.lookup(kid); !ok {
+			return nil, errUnknownKey
+		}
+	}
+
+	if token.Method.Alg() != key.alg {
+		return nil, fmt.Errorf("algoritmo %s no coincide con la clave %s", token.Method.Alg(), kid)
+	}
+	return key.key, nil
+}
+
+// Refresh descarga el JWKS y sustituye las claves en caché
+func (k *KeySet) Refresh(ctx context.Context) error {
+	k.refreshMu.Lock()
+	defer k.refreshMu.Unlock()
+
+	k.mu.Lock()
+	k.lastAttempt = time.Now()
+	k.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS respondió %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]publicKey, len(set.Keys))
+	for _, entry := range set.Keys {
+		key, err := entry.publicKey()
+		if err != nil {
+			log.Printf("Ignorando clave %s del JWKS: %v", entry.Kid, err)
+			continue
+		}
+		keys[entry.Kid] = key
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+	return nil
+}
+
+// Watch recarga el JWKS periódicamente para retirar las claves caducadas
+func (k *KeySet) Watch(ctx context.Context, interval time.Duration) {
+	if err := k.Refresh(ctx); err != nil {
+		log.Printf("JWKS no disponible todavía (%s): %v", k.url, err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Refresh(ctx); err != nil {
+				log.Printf("Error recargando JWKS: %v", err)
+			}
+		}
+	}
+}
+
+func (k *KeySet) lookup(kid string) (publicKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+func (k *KeySet) refreshIfStale() error {
+	k.mu.RLock()
+	stale := time.Since(k.lastAttempt) >= jwksRefreshCooldown
+	k.mu.RUnlock()
+	if !stale {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+	return k.Refresh(ctx)
+}
+
+func (j jwk) publicKey() (publicKey, error) {
+	if j.Use != "" && j.Use != "sig" {
+		return publicKey{}, fmt.Errorf("uso %q no admitido", j.Use)
+	}
+
+	switch {
+	case j.Kty == "RSA" && j.Alg == "RS256":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return publicKey{}, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return publicKey{}, err
+		}
+		return publicKey{alg: j.Alg, key: &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}}, nil
+	case j.Kty == "OKP" && j.Crv == "Ed25519" && j.Alg == "EdDSA":
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return publicKey{}, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return publicKey{}, errors.New("clave Ed25519 con longitud inválida")
+		}
+		return publicKey{alg: j.Alg, key: ed25519.PublicKey(x)}, nil
+	default:
+		return publicKey{}, fmt.Errorf("tipo de clave %s/%s no admitido", j.Kty, j.Alg)
+	}
+}
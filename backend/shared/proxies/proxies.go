@@ -0,0 +1,47 @@
+// Package proxies resuelve los proxies de confianza de los servicios para
+// gin.SetTrustedProxies
+package proxies
+
+import (
+	"log"
+	"net"
+	"os"
+	"strings"
+)
+
+// FromEnv lee de la variable key (o de fallback si está vacía) una lista
+// separada por comas de IPs, rangos CIDR o nombres de host. Los nombres (p. ej.
+// "api-gateway" en docker compose) se resuelven al arrancar; si no resuelven se
+// ignoran y el servicio usa la dirección de la conexión como IP del cliente.
+func FromEnv(key, fallback string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		value = fallback
+	}
+
+	trusted := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if net.ParseIP(entry) != nil {
+			trusted = append(trusted, entry)
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err == nil {
+			trusted = append(trusted, entry)
+			continue
+		}
+
+		ips, err := net.LookupIP(entry)
+		if err != nil {
+			log.Printf("%s: no se pudo resolver %q, se ignora: %v", key, entry, err)
+			continue
+		}
+		for _, ip := range ips {
+			trusted = append(trusted, ip.String())
+		}
+	}
+	return trusted
+}
@@ -0,0 +1,157 @@
+// Package tokenstatus comprueba con user-service si un access token sigue
+// vigente. La firma y la caducidad se validan en local con el JWKS, pero las
+// revocaciones (logout, cierre de sesiones, reutilización de refresh tokens,
+// desactivación de la cuenta, fin de una suplantación) solo las conoce
+// user-service, que las publica en GET /api/auth/introspect.
+package tokenstatus
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL acota cuánto tarda una revocación en aplicarse en el
+	// resto de servicios a cambio de no consultar user-service en cada petición
+	DefaultCacheTTL = 15 * time.Second
+
+	requestTimeout = 3 * time.Second
+	// maxCacheEntries limita la memoria de la caché; al llenarse se purgan las caducadas
+	maxCacheEntries = 10000
+)
+
+// ErrUnavailable indica que no se pudo consultar a user-service: el token no
+// se da por bueno ni por revocado
+var ErrUnavailable = errors.New("no se pudo comprobar la revocación del token")
+
+// Checker consulta el estado de los tokens y cachea la respuesta durante ttl
+type Checker struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+type cached struct {
+	active  bool
+	expires time.Time
+}
+
+// NewChecker usa el endpoint de introspección de user-service (url completa);
+// ttl <= 0 usa DefaultCacheTTL
+func NewChecker(url string, ttl time.Duration) *Checker {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &Checker{
+		url:    url,
+		ttl:    ttl,
+		client: &http.Client{Timeout: requestTimeout},
+		cache:  make(map[string]cached),
+	}
+}
+
+// NewCheckerFromEnv lee TOKEN_STATUS_URL (por defecto defaultURL) y
+// TOKEN_STATUS_CACHE_TTL
+func NewCheckerFromEnv(defaultURL string) *Checker {
+	url := os.Getenv("TOKEN_STATUS_URL")
+	if url == "" {
+		url = defaultURL
+	}
+	var ttl time.Duration
+	if value := os.Getenv("TOKEN_STATUS_CACHE_TTL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("TOKEN_STATUS_CACHE_TTL inválido %q, se usa %s", value, DefaultCacheTTL)
+		}
+		ttl = parsed
+	}
+	return NewChecker(url, ttl)
+}
+
+// Active indica si el token sigue vigente. expiresAt es la caducidad del token
+// (la caché nunca lo mantiene más allá). Devuelve ErrUnavailable si user-service
+// no responde o falla.
+func (c *Checker) Active(ctx context.Context, token string, expiresAt time.Time) (bool, error) {
+	key := cacheKey(token)
+	now := time.Now()
+	if active, ok := c.lookup(key, now); ok {
+		return active, nil
+	}
+
+	active, err := c.introspect(ctx, token)
+	if err != nil {
+		return false, err
+	}
+
+	expires := now.Add(c.ttl)
+	if !expiresAt.IsZero() && expiresAt.Before(expires) {
+		expires = expiresAt
+	}
+	c.store(key, cached{active: active, expires: expires}, now)
+	return active, nil
+}
+
+func (c *Checker) introspect(ctx context.Context, token string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusUnauthorized:
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: user-service respondió %d", ErrUnavailable, resp.StatusCode)
+	}
+}
+
+func (c *Checker) lookup(key string, now time.Time) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.cache[key]
+	if !ok || !now.Before(entry.expires) {
+		return false, false
+	}
+	return entry.active, true
+}
+
+func (c *Checker) store(key string, entry cached, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.cache) >= maxCacheEntries {
+		for k, e := range c.cache {
+			if !now.Before(e.expires) {
+				delete(c.cache, k)
+			}
+		}
+	}
+	if len(c.cache) < maxCacheEntries {
+		c.cache[key] = entry
+	}
+}
+
+// cacheKey evita guardar los tokens en claro en memoria
+func cacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+package tokenstatus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestActive(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		want    bool
+		wantErr bool
+	}{
+		{"token vigente", http.StatusOK, true, false},
+		{"token revocado", http.StatusUnauthorized, false, false},
+		{"user-service falla", http.StatusInternalServerError, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer token" {
+					t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			got, err := NewChecker(server.URL, time.Minute).Active(context.Background(), "token", time.Time{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Active() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrUnavailable) {
+				t.Errorf("Active() error = %v, want ErrUnavailable", err)
+			}
+			if got != tt.want {
+				t.Errorf("Active() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActiveCache(t *testing.T) {
+	calls := 0
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	checker := NewChecker(server.URL, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if active, err := checker.Active(ctx, "cached", time.Time{}); err != nil || !active {
+			t.Fatalf("Active() = %v, %v", active, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("user-service consulted %d times, want 1 within the TTL", calls)
+	}
+
+	// La caché no sobrevive a la caducidad del token: tras ella se vuelve a consultar
+	status = http.StatusUnauthorized
+	expired := time.Now().Add(-time.Second)
+	if _, err := checker.Active(ctx, "expiring", expired); err != nil {
+		t.Fatal(err)
+	}
+	if active, err := checker.Active(ctx, "expiring", expired); err != nil || active {
+		t.Errorf("Active() = %v, %v, want false", active, err)
+	}
+	if calls != 3 {
+		t.Errorf("user-service consulted %d times, want 3", calls)
+	}
+}
+
+func TestActiveUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	if _, err := NewChecker(url, time.Minute).Active(context.Background(), "token", time.Time{}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Active() error = %v, want ErrUnavailable", err)
+	}
+}
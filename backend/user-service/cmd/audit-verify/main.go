@@ -0,0 +1,326 @@
+// audit-verify recorre la cadena de hashes de audit_logs, recalcula cada hash y
+// comprueba las firmas de los checkpoints. Informa del primer eslabón roto y
+// termina con código 1 si el historial ha sido alterado.
+//
+// Solo necesita acceso de lectura a la base de datos y las claves públicas de
+// los checkpoints, así que puede ejecutarlo un auditor externo:
+//
+//	DATABASE_URL=postgres://... AUDIT_CHECKPOINT_PUBLIC_KEYS=<base64> go run ./cmd/audit-verify
+//
+// Falla también si hay filas sin ningún checkpoint o si el último es más antiguo
+// que -max-age y hay filas posteriores: quien reescriba la base de datos puede
+// borrar los checkpoints recientes. Para detectarlo aunque borre también filas,
+// guarda fuera de la base de datos la línea PIN que imprime (o la que registra
+// user-service en cada checkpoint) y pásala en la siguiente verificación:
+//
+//	go run ./cmd/audit-verify -pin <seq>:<hash>
+//
+// Con -genkey genera un par de claves para AUDIT_CHECKPOINT_KEY.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"user-service/internal/auditchain"
+)
+
+type checkpoint struct {
+	ChainSeq    int64
+	RowHash     string
+	KeyID       string
+	Signature   string
+	SignedAt    int64 // 0 en los checkpoints v1, anteriores a la migración 020
+	CreatedUnix int64
+}
+
+// signedTime es la hora firmada del checkpoint o, en los v1, la de la columna
+// created_at (sin firmar)
+func (cp checkpoint) signedTime() time.Time {
+	if cp.SignedAt != 0 {
+		return time.Unix(cp.SignedAt, 0)
+	}
+	return time.Unix(cp.CreatedUnix, 0)
+}
+
+// pin es una fila de la cadena (posición y hash) guardada fuera de la base de datos
+type pin struct {
+	seq  int64
+	hash string
+}
+
+func parsePin(value string) (*pin, error) {
+	if value == "" {
+		return nil, nil
+	}
+	seq, hash, ok := strings.Cut(strings.TrimSpace(value), ":")
+	n, err := strconv.ParseInt(seq, 10, 64)
+	if !ok || err != nil || n < 1 || len(hash) != 64 {
+		return nil, fmt.Errorf("invalid pin %q, expected <seq>:<row_hash>", value)
+	}
+	return &pin{seq: n, hash: hash}, nil
+}
+
+// options son los requisitos de frescura de la verificación
+type options struct {
+	maxAge time.Duration
+	pin    *pin
+	now    time.Time
+}
+
+// chainRow es una fila de audit_logs con el contenido que se encadena
+type chainRow struct {
+	seq      int64
+	id       string
+	prevHash string
+	rowHash  string
+	fields   []*string
+}
+
+// brokenLink describe el primer punto en que la cadena no cuadra
+type brokenLink struct {
+	seq    int64
+	id     string
+	reason string
+}
+
+func main() {
+	databaseURL := flag.String("database", os.Getenv("DATABASE_URL"), "Postgres connection URL")
+	publicKeys := flag.String("keys", os.Getenv("AUDIT_CHECKPOINT_PUBLIC_KEYS"), "comma-separated base64 Ed25519 public keys of the checkpoints")
+	pinned := flag.String("pin", os.Getenv("AUDIT_VERIFY_PIN"), "<seq>:<row_hash> of a row recorded outside the database (PIN line of a previous run)")
+	maxAge := flag.Duration("max-age", time.Hour, "maximum age of the last checkpoint when rows were written after it")
+	genKey := flag.Bool("genkey", false, "generate a checkpoint key pair and exit")
+	flag.Parse()
+
+	if *genKey {
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("AUDIT_CHECKPOINT_KEY=%s\n", base64.StdEncoding.EncodeToString(private.Seed()))
+		fmt.Printf("AUDIT_CHECKPOINT_PUBLIC_KEYS=%s\n", base64.StdEncoding.EncodeToString(public))
+		return
+	}
+
+	if *databaseURL == "" {
+		fmt.Fprintln(os.Stderr, "DATABASE_URL or -database is required")
+		os.Exit(2)
+	}
+	keys, err := auditchain.ParsePublicKeys(*publicKeys)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(keys) == 0 {
+		fmt.Fprintln(os.Stderr, "AUDIT_CHECKPOINT_PUBLIC_KEYS or -keys is required to verify checkpoints")
+		os.Exit(2)
+	}
+	pinnedRow, err := parsePin(*pinned)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	db, err := gorm.Open(postgres.Open(*databaseURL), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	ok, err := verify(db, keys, options{maxAge: *maxAge, pin: pinnedRow, now: time.Now()})
+	if err != nil {
+		log.Fatal("Verification failed: ", err)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+func verify(db *gorm.DB, keys map[string]ed25519.PublicKey, opts options) (bool, error) {
+	var checkpoints []checkpoint
+	err := db.Raw(`SELECT chain_seq, row_hash, key_id, signature, COALESCE(signed_at, 0) AS signed_at,
+		floor(extract(epoch FROM created_at))::bigint AS created_unix
+		FROM audit_checkpoints ORDER BY chain_seq`).Scan(&checkpoints).Error
+	if err != nil {
+		return false, err
+	}
+	ok := true
+	bySeq := make(map[int64]checkpoint, len(checkpoints))
+	for _, cp := range checkpoints {
+		if err := auditchain.Verify(keys, cp.KeyID, cp.ChainSeq, cp.RowHash, cp.SignedAt, cp.Signature); err != nil {
+			fmt.Printf("FAIL checkpoint at seq %d: %v\n", cp.ChainSeq, err)
+			ok = false
+		}
+		bySeq[cp.ChainSeq] = cp
+	}
+
+	var unchained int64
+	if err := db.Raw("SELECT count(*) FROM audit_logs WHERE chain_seq IS NULL").Scan(&unchained).Error; err != nil {
+		return false, err
+	}
+	if unchained > 0 {
+		fmt.Printf("FAIL %d audit rows are outside the chain (inserted without the chain trigger)\n", unchained)
+		ok = false
+	}
+
+	walker := newChainWalker(bySeq, opts.pin)
+	broken, err := walkChain(db, walker)
+	if err != nil {
+		return false, err
+	}
+	if broken != nil {
+		fmt.Printf("FAIL chain broken at seq %d (audit log %s): %s\n", broken.seq, broken.id, broken.reason)
+		return false, nil
+	}
+	lastSeq, lastHash := walker.head()
+
+	var head struct {
+		Seq  int64
+		Hash string
+	}
+	if err := db.Raw("SELECT seq, hash FROM audit_chain_head WHERE id = 1").Scan(&head).Error; err != nil {
+		return false, err
+	}
+	if head.Seq != lastSeq || head.Hash != lastHash {
+		fmt.Printf("FAIL chain head (seq %d) does not match the last audit row (seq %d)\n", head.Seq, lastSeq)
+		ok = false
+	}
+
+	for _, problem := range freshnessProblems(checkpoints, lastSeq, opts) {
+		fmt.Printf("FAIL %s\n", problem)
+		ok = false
+	}
+
+	fmt.Printf("%d audit rows chained, %d checkpoints", lastSeq, len(checkpoints))
+	if len(checkpoints) > 0 {
+		last := checkpoints[len(checkpoints)-1]
+		fmt.Printf(", %d rows after the last checkpoint (signed %s)", lastSeq-last.ChainSeq, last.signedTime().UTC().Format(time.RFC3339))
+	}
+	fmt.Println()
+	if ok {
+		if len(checkpoints) > 0 {
+			last := checkpoints[len(checkpoints)-1]
+			// Guardada fuera de la base de datos, detecta que se borren filas y checkpoints
+			fmt.Printf("PIN %d:%s\n", last.ChainSeq, last.RowHash)
+		}
+		fmt.Println("OK audit history is intact")
+	}
+	return ok, nil
+}
+
+// freshnessProblems comprueba que los checkpoints cubren la cadena: sin ellos,
+// o si los recientes se han borrado, se pueden reescribir las filas sin firma
+func freshnessProblems(checkpoints []checkpoint, lastSeq int64, opts options) []string {
+	var problems []string
+	for _, cp := range checkpoints {
+		if cp.ChainSeq > lastSeq {
+			problems = append(problems, fmt.Sprintf("checkpoint at seq %d is beyond the last audit row (seq %d): rows were deleted", cp.ChainSeq, lastSeq))
+		}
+	}
+	if opts.pin != nil && opts.pin.seq > lastSeq {
+		problems = append(problems, fmt.Sprintf("pinned row at seq %d is beyond the last audit row (seq %d): rows were deleted", opts.pin.seq, lastSeq))
+	}
+	if lastSeq == 0 {
+		return problems
+	}
+	if len(checkpoints) == 0 {
+		return append(problems, "there are no signed checkpoints, the whole chain could have been rewritten")
+	}
+	last := checkpoints[len(checkpoints)-1]
+	if age := opts.now.Sub(last.signedTime()); lastSeq > last.ChainSeq && age > opts.maxAge {
+		problems = append(problems, fmt.Sprintf("last checkpoint (seq %d) is %s old and %d rows were written after it: checkpoints are missing",
+			last.ChainSeq, age.Truncate(time.Second), lastSeq-last.ChainSeq))
+	}
+	return problems
+}
+
+// chainWalker recalcula los hashes fila a fila en orden de chain_seq y comprueba
+// que cada checkpoint y el pin coinciden con la fila que fijaron
+type chainWalker struct {
+	checkpoints map[int64]checkpoint
+	pin         *pin
+	expected    int64
+	prevHash    string
+}
+
+func newChainWalker(checkpoints map[int64]checkpoint, pinned *pin) *chainWalker {
+	return &chainWalker{checkpoints: checkpoints, pin: pinned, expected: 1}
+}
+
+// next procesa la siguiente fila; devuelve el primer eslabón roto
+func (w *chainWalker) next(row chainRow) *brokenLink {
+	switch {
+	case row.seq != w.expected:
+		return &brokenLink{row.seq, row.id, fmt.Sprintf("expected seq %d, rows are missing", w.expected)}
+	case row.prevHash != w.prevHash:
+		return &brokenLink{row.seq, row.id, "prev_hash does not match the previous row"}
+	case auditchain.Hash(w.prevHash, row.fields) != row.rowHash:
+		return &brokenLink{row.seq, row.id, "row content does not match row_hash"}
+	}
+	if cp, ok := w.checkpoints[row.seq]; ok && cp.RowHash != row.rowHash {
+		return &brokenLink{row.seq, row.id, "row_hash does not match the signed checkpoint"}
+	}
+	if w.pin != nil && w.pin.seq == row.seq && w.pin.hash != row.rowHash {
+		return &brokenLink{row.seq, row.id, "row_hash does not match the pinned hash"}
+	}
+	w.prevHash = row.rowHash
+	w.expected++
+	return nil
+}
+
+// head es la posición y el hash de la última fila procesada
+func (w *chainWalker) head() (int64, string) {
+	return w.expected - 1, w.prevHash
+}
+
+// walkChain lee audit_logs en orden de chain_seq y pasa cada fila al walker
+func walkChain(db *gorm.DB, walker *chainWalker) (*brokenLink, error) {
+	query := fmt.Sprintf(
+		"SELECT chain_seq, id::text, prev_hash, row_hash, %s FROM audit_logs WHERE chain_seq IS NOT NULL ORDER BY chain_seq",
+		strings.Join(auditchain.ContentColumns, ", "),
+	)
+	rows, err := db.Raw(query).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			row              chainRow
+			storedPrev, hash sql.NullString
+		)
+		content := make([]sql.NullString, len(auditchain.ContentColumns))
+		dest := []interface{}{&row.seq, &row.id, &storedPrev, &hash}
+		for i := range content {
+			dest = append(dest, &content[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row.prevHash, row.rowHash = storedPrev.String, hash.String
+		row.fields = make([]*string, len(content))
+		for i := range content {
+			if content[i].Valid {
+				row.fields[i] = &content[i].String
+			}
+		}
+		if broken := walker.next(row); broken != nil {
+			return broken, nil
+		}
+	}
+	return nil, rows.Err()
+}
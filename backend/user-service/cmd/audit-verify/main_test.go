@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/auditchain"
+)
+
+// testChain construye n filas encadenadas correctamente
+func testChain(n int) []chainRow {
+	rows := make([]chainRow, 0, n)
+	prev := ""
+	for i := 1; i <= n; i++ {
+		seq := fmt.Sprint(i)
+		action := "phi_access"
+		fields := []*string{&seq, nil, &action}
+		hash := auditchain.Hash(prev, fields)
+		rows = append(rows, chainRow{seq: int64(i), id: "row-" + seq, prevHash: prev, rowHash: hash, fields: fields})
+		prev = hash
+	}
+	return rows
+}
+
+func walk(rows []chainRow, checkpoints map[int64]checkpoint, pinned *pin) *brokenLink {
+	walker := newChainWalker(checkpoints, pinned)
+	for _, row := range rows {
+		if broken := walker.next(row); broken != nil {
+			return broken
+		}
+	}
+	return nil
+}
+
+func TestChainWalker(t *testing.T) {
+	tests := []struct {
+		name        string
+		tamper      func(rows []chainRow) []chainRow
+		checkpoints func(rows []chainRow) map[int64]checkpoint
+		pin         func(rows []chainRow) *pin
+		wantSeq     int64
+		wantReason  string
+	}{
+		{name: "cadena intacta"},
+		{
+			name: "contenido alterado",
+			tamper: func(rows []chainRow) []chainRow {
+				changed := "login_success"
+				rows[2].fields = []*string{rows[2].fields[0], nil, &changed}
+				return rows
+			},
+			wantSeq: 3, wantReason: "row content does not match row_hash",
+		},
+		{
+			name: "fila reescrita con su hash recalculado",
+			tamper: func(rows []chainRow) []chainRow {
+				changed := "login_success"
+				rows[2].fields = []*string{rows[2].fields[0], nil, &changed}
+				rows[2].rowHash = auditchain.Hash(rows[2].prevHash, rows[2].fields)
+				return rows
+			},
+			wantSeq: 4, wantReason: "prev_hash does not match the previous row",
+		},
+		{
+			name:    "fila borrada",
+			tamper:  func(rows []chainRow) []chainRow { return append(rows[:1], rows[2:]...) },
+			wantSeq: 3, wantReason: "rows are missing",
+		},
+		{
+			name: "cola reescrita pero firmada en un checkpoint",
+			tamper: func(rows []chainRow) []chainRow {
+				changed := "login_success"
+				rows[4].fields = []*string{rows[4].fields[0], nil, &changed}
+				rows[4].rowHash = auditchain.Hash(rows[4].prevHash, rows[4].fields)
+				return rows
+			},
+			checkpoints: func(rows []chainRow) map[int64]checkpoint {
+				return map[int64]checkpoint{5: {ChainSeq: 5, RowHash: rows[4].rowHash}}
+			},
+			wantSeq: 5, wantReason: "does not match the signed checkpoint",
+		},
+		{
+			name: "cola reescrita pero fijada fuera de la base de datos",
+			tamper: func(rows []chainRow) []chainRow {
+				changed := "login_success"
+				rows[4].fields = []*string{rows[4].fields[0], nil, &changed}
+				rows[4].rowHash = auditchain.Hash(rows[4].prevHash, rows[4].fields)
+				return rows
+			},
+			pin: func(rows []chainRow) *pin {
+				return &pin{seq: 5, hash: rows[4].rowHash}
+			},
+			wantSeq: 5, wantReason: "does not match the pinned hash",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := testChain(5)
+			var checkpoints map[int64]checkpoint
+			if tt.checkpoints != nil {
+				checkpoints = tt.checkpoints(original)
+			}
+			var pinned *pin
+			if tt.pin != nil {
+				pinned = tt.pin(original)
+			}
+			rows := testChain(5)
+			if tt.tamper != nil {
+				rows = tt.tamper(rows)
+			}
+
+			broken := walk(rows, checkpoints, pinned)
+			if tt.wantReason == "" {
+				if broken != nil {
+					t.Fatalf("unexpected broken link at seq %d: %s", broken.seq, broken.reason)
+				}
+				return
+			}
+			if broken == nil {
+				t.Fatal("tampering was not detected")
+			}
+			if broken.seq != tt.wantSeq || !strings.Contains(broken.reason, tt.wantReason) {
+				t.Errorf("broken at seq %d (%s), want seq %d (%s)", broken.seq, broken.reason, tt.wantSeq, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestFreshnessProblems(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	recent := checkpoint{ChainSeq: 8, SignedAt: now.Add(-10 * time.Minute).Unix()}
+	stale := checkpoint{ChainSeq: 8, SignedAt: now.Add(-3 * time.Hour).Unix()}
+	legacy := checkpoint{ChainSeq: 8, CreatedUnix: now.Add(-3 * time.Hour).Unix()}
+
+	tests := []struct {
+		name        string
+		checkpoints []checkpoint
+		lastSeq     int64
+		pin         *pin
+		want        string
+	}{
+		{"sin filas", nil, 0, nil, ""},
+		{"filas sin checkpoints", nil, 10, nil, "no signed checkpoints"},
+		{"checkpoint reciente", []checkpoint{recent}, 10, nil, ""},
+		{"checkpoint antiguo sin filas nuevas", []checkpoint{stale}, 8, nil, ""},
+		{"checkpoint antiguo con filas nuevas", []checkpoint{stale}, 10, nil, "checkpoints are missing"},
+		{"checkpoint v1 antiguo con filas nuevas", []checkpoint{legacy}, 10, nil, "checkpoints are missing"},
+		{"checkpoint más allá de la última fila", []checkpoint{recent}, 7, nil, "rows were deleted"},
+		{"pin más allá de la última fila", []checkpoint{recent}, 10, &pin{seq: 12}, "pinned row at seq 12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := freshnessProblems(tt.checkpoints, tt.lastSeq, options{maxAge: time.Hour, pin: tt.pin, now: now})
+			if tt.want == "" {
+				if len(problems) > 0 {
+					t.Fatalf("unexpected problems: %v", problems)
+				}
+				return
+			}
+			if !strings.Contains(strings.Join(problems, "\n"), tt.want) {
+				t.Errorf("problems = %v, want one containing %q", problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePin(t *testing.T) {
+	hash := strings.Repeat("a", 64)
+	tests := []struct {
+		name    string
+		value   string
+		want    *pin
+		wantErr bool
+	}{
+		{"vacío", "", nil, false},
+		{"válido", "42:" + hash, &pin{seq: 42, hash: hash}, false},
+		{"sin hash", "42", nil, true},
+		{"hash corto", "42:abc", nil, true},
+		{"posición no numérica", "x:" + hash, nil, true},
+		{"posición cero", "0:" + hash, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePin(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("parsePin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+// mock-oidc sirve el proveedor OpenID Connect de pruebas (internal/mockoidc)
+// para probar el SSO en local. No debe desplegarse fuera de un entorno de desarrollo.
+//
+//	MOCK_OIDC_ISSUER=http://localhost:9000 MOCK_OIDC_CLIENT_ID=vincula go run ./cmd/mock-oidc
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"user-service/internal/mockoidc"
+)
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "9000"
+	}
+	issuer := strings.TrimRight(os.Getenv("MOCK_OIDC_ISSUER"), "/")
+	if issuer == "" {
+		issuer = "http://localhost:" + port
+	}
+	clientID := os.Getenv("MOCK_OIDC_CLIENT_ID")
+	if clientID == "" {
+		clientID = "vincula"
+	}
+
+	server, err := mockoidc.New(issuer, clientID, os.Getenv("MOCK_OIDC_CLIENT_SECRET"))
+	if err != nil {
+		log.Fatal("Failed to generate signing key:", err)
+	}
+
+	log.Printf("Mock OIDC provider %s (client %s) listening on port %s", issuer, clientID, port)
+	log.Fatal(http.ListenAndServe(":"+port, server.Handler()))
+}
@@ -0,0 +1,162 @@
+// Package auditchain implementa la cadena de hashes de audit_logs y la firma de
+// sus checkpoints. Los hashes los calcula Postgres al insertar (migración 019);
+// este paquete los recalcula de forma independiente para verificarlos.
+package auditchain
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// checkpointDomain separa las firmas de checkpoints de cualquier otro uso de la
+// clave. La v2 incluye la hora de firma; la v1 (sin hora) solo se verifica.
+const (
+	checkpointDomain   = "vincula-audit-checkpoint:v2"
+	checkpointDomainV1 = "vincula-audit-checkpoint:v1"
+)
+
+// ContentColumns son las expresiones SQL, en orden, cuyo texto forma el
+// contenido encadenado de una fila. Deben coincidir con audit_log_content.
+var ContentColumns = []string{
+	"chain_seq::text",
+	"id::text",
+	"principal_type",
+	"user_id::text",
+	"service_account_id::text",
+	"action",
+	"resource_type",
+	"resource_id",
+	"ip_address::text",
+	"user_agent",
+	"request_details::text",
+	"response_status::text",
+	"extract(epoch FROM timestamp)::text",
+	"session_id",
+}
+
+// Content codifica cada campo como "<bytes>:<valor>" o "-" si es NULL, igual que
+// audit_chain_field en SQL
+func Content(fields []*string) string {
+	var b strings.Builder
+	for _, field := range fields {
+		if field == nil {
+			b.WriteString("-")
+			continue
+		}
+		b.WriteString(strconv.Itoa(len(*field)))
+		b.WriteString(":")
+		b.WriteString(*field)
+	}
+	return b.String()
+}
+
+// Hash es el hash de una fila encadenado al de la anterior (prev vacío en la primera)
+func Hash(prev string, fields []*string) string {
+	sum := sha256.Sum256([]byte(prev + "\n" + Content(fields)))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckpointMessage es lo que se firma en un checkpoint: la posición y el hash
+// de la última fila de la cadena y la hora de firma (segundos Unix). Con
+// signedAt 0 es el mensaje v1 de los checkpoints anteriores a la migración 020.
+func CheckpointMessage(seq int64, hash string, signedAt int64) []byte {
+	if signedAt == 0 {
+		return []byte(fmt.Sprintf("%s:%d:%s", checkpointDomainV1, seq, hash))
+	}
+	return []byte(fmt.Sprintf("%s:%d:%s:%d", checkpointDomain, seq, hash, signedAt))
+}
+
+// KeyID identifica una clave pública en los checkpoints (permite rotarlas)
+func KeyID(public ed25519.PublicKey) string {
+	sum := sha256.Sum256(public)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Signer firma checkpoints con la clave privada de AUDIT_CHECKPOINT_KEY
+type Signer struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// NewSignerFromEnv lee AUDIT_CHECKPOINT_KEY (semilla Ed25519 de 32 bytes en
+// base64). Sin la variable devuelve nil: los checkpoints quedan desactivados
+// (main no arranca así en producción).
+func NewSignerFromEnv() (*Signer, error) {
+	value := os.Getenv("AUDIT_CHECKPOINT_KEY")
+	if value == "" {
+		return nil, nil
+	}
+	seed, err := decodeKey(value, ed25519.SeedSize)
+	if err != nil {
+		return nil, fmt.Errorf("AUDIT_CHECKPOINT_KEY: %w", err)
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	return &Signer{key: key, keyID: KeyID(key.Public().(ed25519.PublicKey))}, nil
+}
+
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// PublicKey en base64, para configurar el verificador
+func (s *Signer) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// Sign devuelve la firma en base64 del checkpoint (seq, hash, signedAt)
+func (s *Signer) Sign(seq int64, hash string, signedAt int64) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, CheckpointMessage(seq, hash, signedAt)))
+}
+
+// ParsePublicKeys lee una lista separada por comas de claves públicas Ed25519
+// en base64 y las indexa por KeyID
+func ParsePublicKeys(value string) (map[string]ed25519.PublicKey, error) {
+	keys := make(map[string]ed25519.PublicKey)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		raw, err := decodeKey(item, ed25519.PublicKeySize)
+		if err != nil {
+			return nil, fmt.Errorf("public key %q: %w", item, err)
+		}
+		public := ed25519.PublicKey(raw)
+		keys[KeyID(public)] = public
+	}
+	return keys, nil
+}
+
+// Verify comprueba la firma de un checkpoint con la clave de su KeyID
+func Verify(keys map[string]ed25519.PublicKey, keyID string, seq int64, hash string, signedAt int64, signature string) error {
+	public, ok := keys[keyID]
+	if !ok {
+		return fmt.Errorf("unknown key %s", keyID)
+	}
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+	if !ed25519.Verify(public, CheckpointMessage(seq, hash, signedAt), raw) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func decodeKey(value string, size int) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, errors.New("not valid base64")
+	}
+	if len(raw) != size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", size, len(raw))
+	}
+	return raw, nil
+}
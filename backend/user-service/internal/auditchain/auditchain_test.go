@@ -0,0 +1,156 @@
+package auditchain
+
+import (
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func ptr(s string) *string { return &s }
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		name    string
+		prev    string
+		fields  []*string
+		content string
+		want    string
+	}{
+		// Valores calculados con: printf '<prev>\n<content>' | sha256sum
+		{"primera fila", "", []*string{ptr("1")}, "1:1", "9681f02eafa53cd2ceffec56b3fb4889bae399588edaeb096a4d902b5c0ced39"},
+		{"NULL, vacío y longitud en bytes", "abc", []*string{ptr("7"), nil, ptr("héllo"), ptr("")}, "1:7-6:héllo0:", "06e480799f16b4d372df0f4d984ebca6c93d28610b74e0c2b2d74a13767ec23a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Content(tt.fields); got != tt.content {
+				t.Errorf("Content() = %q, want %q", got, tt.content)
+			}
+			if got := Hash(tt.prev, tt.fields); got != tt.want {
+				t.Errorf("Hash() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentIsUnambiguous(t *testing.T) {
+	// Sin la longitud, ("a:", "b") y ("a", ":b") darían el mismo contenido
+	if Content([]*string{ptr("a:"), ptr("b")}) == Content([]*string{ptr("a"), ptr(":b")}) {
+		t.Error("different fields produce the same content")
+	}
+	if Content([]*string{nil}) == Content([]*string{ptr("-")}) {
+		t.Error("NULL and \"-\" produce the same content")
+	}
+}
+
+// TestHashMatchesSQL compara Hash con audit.audit_log_hash (migración 024). Necesita
+// una base de datos con las migraciones aplicadas en AUDIT_TEST_DATABASE_URL; no
+// inserta nada (la fila se construye con json_populate_record).
+func TestHashMatchesSQL(t *testing.T) {
+	dsn := os.Getenv("AUDIT_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("AUDIT_TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []struct {
+		name string
+		prev string
+		row  string
+	}{
+		{"todos los campos", "", `{"chain_seq": 1, "id": "6f1c1f5e-8a55-4a57-9c2e-0d5e0f4b1a11", "principal_type": "user",
+			"user_id": "0b7f3f2c-4a7e-4f43-a7c5-56a9b5c0a1d2", "action": "phi_access", "resource_type": "user",
+			"resource_id": "42", "ip_address": "10.0.0.1", "user_agent": "Mozilla/5.0 (ñ)",
+			"request_details": {"method": "GET", "path": "/api/users/42"}, "response_status": 200,
+			"timestamp": "2026-03-01T10:20:30.123456", "session_id": "s-1"}`},
+		{"campos NULL", "9681f02eafa53cd2ceffec56b3fb4889bae399588edaeb096a4d902b5c0ced39", `{"chain_seq": 2,
+			"id": "a3d9b0f4-1c2e-4b7a-8f6d-5e4c3b2a1908", "principal_type": "service_account",
+			"service_account_id": "c1d2e3f4-a5b6-4c7d-8e9f-0a1b2c3d4e5f", "action": "login_ip_blocked",
+			"timestamp": "2026-03-01T10:20:31"}`},
+	}
+
+	query := fmt.Sprintf("SELECT audit.audit_log_hash(?, r), %s FROM json_populate_record(NULL::audit_logs, ?::json) r",
+		strings.Join(ContentColumns, ", "))
+	for _, tt := range rows {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := db.Raw(query, tt.prev, tt.row).Rows()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer result.Close()
+			if !result.Next() {
+				t.Fatal("no row returned")
+			}
+
+			var want string
+			content := make([]sql.NullString, len(ContentColumns))
+			dest := []interface{}{&want}
+			for i := range content {
+				dest = append(dest, &content[i])
+			}
+			if err := result.Scan(dest...); err != nil {
+				t.Fatal(err)
+			}
+			fields := make([]*string, len(content))
+			for i := range content {
+				if content[i].Valid {
+					fields[i] = &content[i].String
+				}
+			}
+
+			if got := Hash(tt.prev, fields); got != want {
+				t.Errorf("Hash() = %s, audit_log_hash = %s", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckpointSignature(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	t.Setenv("AUDIT_CHECKPOINT_KEY", base64.StdEncoding.EncodeToString(seed))
+	signer, err := NewSignerFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ParsePublicKeys(signer.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const hash = "9681f02eafa53cd2ceffec56b3fb4889bae399588edaeb096a4d902b5c0ced39"
+	signature := signer.Sign(10, hash, 1767225600)
+
+	tests := []struct {
+		name     string
+		keyID    string
+		seq      int64
+		signedAt int64
+		wantErr  bool
+	}{
+		{"firma válida", signer.KeyID(), 10, 1767225600, false},
+		{"otra posición", signer.KeyID(), 11, 1767225600, true},
+		{"hora de firma alterada", signer.KeyID(), 10, 1767229200, true},
+		{"sin hora (v1)", signer.KeyID(), 10, 0, true},
+		{"clave desconocida", "0000000000000000", 10, 1767225600, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(keys, tt.keyID, tt.seq, hash, tt.signedAt, signature)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Acciones de seguridad registradas en audit_logs
+const (
+	AuditAccountLocked      = "account_locked"
+	AuditAccountUnlocked    = "account_unlocked"
+	AuditLoginIPBlocked     = "login_ip_blocked"
+	AuditSuspiciousLogin    = "suspicious_login_pattern"
+	AuditLockedLoginAttempt = "locked_login_attempt"
+	AuditSSOAccountLinked   = "sso_account_linked"
+	AuditSSOUserProvisioned = "sso_user_provisioned"
+	AuditSSOLinkApproved    = "sso_link_approved"
+	AuditSSOLinkRefused     = "sso_link_refused"
+
+	AuditServiceAccountCreated     = "service_account_created"
+	AuditServiceAccountDeactivated = "service_account_deactivated"
+	AuditAPIKeyCreated             = "api_key_created"
+	AuditAPIKeyRevoked             = "api_key_revoked"
+	AuditServiceAccountRequest     = "service_account_request"
+
+	AuditSessionRevoked     = "session_revoked"
+	AuditSessionsTerminated = "sessions_terminated"
+
+	AuditUserDeleted   = "user_deleted"
+	AuditUserRestored  = "user_restored"
+	AuditUserErased    = "user_erased"
+	AuditUsersImported = "users_imported"
+
+	AuditFamilyInviteCreated = "family_invite_code_created"
+	AuditFamilyLinkRequested = "family_link_requested"
+	AuditFamilyLinkApproved  = "family_link_approved"
+	AuditFamilyLinkRejected  = "family_link_rejected"
+	AuditFamilyLinkRevoked   = "family_link_revoked"
+
+	AuditLogExported = "audit_log_exported"
+
+	AuditImpersonationStarted = "impersonation_started"
+
+	// Acceso a recursos con datos de salud (usuarios, cola, llamadas); lo
+	// registra el middleware de auditoría en cada petición
+	AuditPHIAccess = "phi_access"
+)
+
+// AuditLog corresponde a la tabla audit_logs (migración 005). El principal que
+// actúa es un usuario (UserID) o una cuenta de servicio (ServiceAccountID).
+type AuditLog struct {
+	ID               uuid.UUID       `json:"id" gorm:"type:uuid;primary_key"`
+	PrincipalType    string          `json:"principal_type" gorm:"size:20;not null;default:user"`
+	UserID           *uuid.UUID      `json:"user_id,omitempty" gorm:"type:uuid;index"`
+	ServiceAccountID *uuid.UUID      `json:"service_account_id,omitempty" gorm:"type:uuid;index"`
+	Action           string          `json:"action" gorm:"size:100;not null"`
+	ResourceType     string          `json:"resource_type,omitempty" gorm:"size:50"`
+	ResourceID       string          `json:"resource_id,omitempty" gorm:"size:255"`
+	IPAddress        *string         `json:"ip_address,omitempty" gorm:"type:inet"`
+	UserAgent        string          `json:"user_agent,omitempty"`
+	RequestDetails   json.RawMessage `json:"request_details,omitempty" gorm:"type:jsonb"`
+	ResponseStatus   *int            `json:"response_status,omitempty"`
+	Timestamp        time.Time       `json:"timestamp" gorm:"index"`
+	SessionID        string          `json:"session_id,omitempty" gorm:"size:255"`
+
+	// Cadena de hashes: los asigna el trigger de la migración 019 al insertar
+	ChainSeq *int64 `json:"chain_seq,omitempty" gorm:"->;type:bigint;uniqueIndex:idx_audit_logs_chain_seq"`
+	PrevHash string `json:"prev_hash,omitempty" gorm:"->;size:64"`
+	RowHash  string `json:"row_hash,omitempty" gorm:"->;size:64"`
+}
+
+// AuditCheckpoint es la cabeza de la cadena de audit_logs (posición y hash de la
+// última fila) firmada con la clave de AUDIT_CHECKPOINT_KEY. Sin la clave no se
+// puede reescribir el historial anterior a un checkpoint sin que se detecte.
+type AuditCheckpoint struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	ChainSeq  int64     `json:"chain_seq" gorm:"not null;uniqueIndex"`
+	RowHash   string    `json:"row_hash" gorm:"size:64;not null"`
+	KeyID     string    `json:"key_id" gorm:"size:32;not null"`
+	Signature string    `json:"signature" gorm:"not null"`
+	SignedAt  int64     `json:"signed_at"` // segundos Unix, parte del mensaje firmado
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Consentimientos que registra un paciente. terms es la aceptación del documento
+// de consentimiento informado; recording y livestream son los permisos que
+// comprueba call-service antes de crear una llamada.
+const (
+	ConsentTerms      = "terms"
+	ConsentRecording  = "recording"
+	ConsentLivestream = "livestream"
+)
+
+// Acciones de un registro de consentimiento. expired lo genera el sistema al
+// publicar una versión nueva del documento.
+const (
+	ConsentGranted   = "granted"
+	ConsentWithdrawn = "withdrawn"
+	ConsentExpired   = "expired"
+)
+
+// ConsentRecord es una concesión o retirada de consentimiento. Las filas no se
+// modifican ni se borran (la migración 017 lo impide con un trigger; la 023 solo
+// deja vaciar la IP y el navegador al suprimir al usuario): el estado vigente
+// está en las columnas de users y esta tabla es la prueba de cada cambio.
+type ConsentRecord struct {
+	ID              uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	UserID          uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	ConsentType     string    `json:"consent_type" gorm:"size:20;not null"`
+	Action          string    `json:"action" gorm:"size:20;not null"`
+	DocumentVersion string    `json:"document_version" gorm:"size:50;not null"`
+	IPAddress       string    `json:"ip_address,omitempty" gorm:"size:45"`
+	UserAgent       string    `json:"user_agent,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
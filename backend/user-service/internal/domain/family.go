@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Estados de un vínculo familiar. Solo los aprobados dan acceso a las llamadas
+// y grabaciones del paciente (call-service).
+const (
+	FamilyLinkPending  = "pending"
+	FamilyLinkApproved = "approved"
+	FamilyLinkRejected = "rejected"
+	FamilyLinkRevoked  = "revoked"
+)
+
+// RelationshipTypes son los tipos de parentesco admitidos
+var RelationshipTypes = []string{"parent", "child", "spouse", "sibling", "guardian", "other"}
+
+// FamilyRelationship vincula a un familiar con un paciente (tabla
+// family_relationships, migración 006). El familiar lo solicita y el paciente lo
+// aprueba o rechaza; cualquiera de los dos puede revocarlo después.
+type FamilyRelationship struct {
+	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	PatientID        uuid.UUID  `json:"patient_id" gorm:"type:uuid;not null;uniqueIndex:idx_family_relationships_pair"`
+	FamilyMemberID   uuid.UUID  `json:"family_member_id" gorm:"type:uuid;not null;uniqueIndex:idx_family_relationships_pair"`
+	RelationshipType string     `json:"relationship_type" gorm:"size:50"`
+	Status           string     `json:"status" gorm:"size:20;not null;default:'approved'"`
+	RequestedAt      time.Time  `json:"requested_at"`
+	RespondedAt      *time.Time `json:"responded_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy        *uuid.UUID `json:"revoked_by,omitempty" gorm:"type:uuid"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+
+	// Las FK a users ya existen en la migración 006 (con ON DELETE CASCADE)
+	Patient      *User `json:"patient,omitempty" gorm:"foreignKey:PatientID;-:migration"`
+	FamilyMember *User `json:"family_member,omitempty" gorm:"foreignKey:FamilyMemberID;-:migration"`
+}
+
+// ValidRelationshipType indica si el parentesco es uno de RelationshipTypes
+func ValidRelationshipType(value string) bool {
+	for _, t := range RelationshipTypes {
+		if t == value {
+			return true
+		}
+	}
+	return false
+}
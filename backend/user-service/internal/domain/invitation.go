@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationRevoked  InvitationStatus = "revoked"
+	InvitationExpired  InvitationStatus = "expired"
+)
+
+// Invitation da de alta cuentas de personal (employee/admin). El email y el rol
+// quedan fijados en el token firmado que recibe el invitado.
+type Invitation struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	Email      string     `json:"email" gorm:"not null;index"`
+	Role       UserRole   `json:"role" gorm:"not null"`
+	InvitedBy  uuid.UUID  `json:"invited_by" gorm:"type:uuid;not null"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	UserID     *uuid.UUID `json:"user_id,omitempty" gorm:"type:uuid"`
+}
+
+// Status deriva el estado de la invitación a partir de sus marcas de tiempo
+func (i *Invitation) Status(now time.Time) InvitationStatus {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationAccepted
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case now.After(i.ExpiresAt):
+		return InvitationExpired
+	default:
+		return InvitationPending
+	}
+}
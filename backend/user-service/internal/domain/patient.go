@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Patient es la ficha clínica de un usuario con rol patient (tabla patients,
+// migración 002). Hay como mucho una por usuario y el número de historia
+// clínica (MRN) es único en toda la clínica.
+type Patient struct {
+	ID                  uuid.UUID         `json:"id" gorm:"type:uuid;primary_key"`
+	UserID              uuid.UUID         `json:"user_id" gorm:"type:uuid;not null;uniqueIndex"`
+	MedicalRecordNumber string            `json:"medical_record_number" gorm:"size:50;not null;uniqueIndex"`
+	DateOfBirth         *Date             `json:"date_of_birth,omitempty" gorm:"type:date"`
+	EmergencyContact    *EmergencyContact `json:"emergency_contact,omitempty" gorm:"type:jsonb"`
+	InsuranceInfo       *InsuranceInfo    `json:"insurance_info,omitempty" gorm:"type:jsonb"`
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+}
+
+// EmergencyContact se guarda como JSONB en patients.emergency_contact
+type EmergencyContact struct {
+	Name         string `json:"name"`
+	Relationship string `json:"relationship,omitempty"`
+	Phone        string `json:"phone"`
+	Email        string `json:"email,omitempty"`
+}
+
+// InsuranceInfo se guarda como JSONB en patients.insurance_info
+type InsuranceInfo struct {
+	Provider     string `json:"provider"`
+	PolicyNumber string `json:"policy_number"`
+	GroupNumber  string `json:"group_number,omitempty"`
+	ValidUntil   *Date  `json:"valid_until,omitempty"`
+}
+
+func (e EmergencyContact) Value() (driver.Value, error) { return json.Marshal(e) }
+func (e *EmergencyContact) Scan(src interface{}) error  { return scanJSON(src, e) }
+func (i InsuranceInfo) Value() (driver.Value, error)    { return json.Marshal(i) }
+func (i *InsuranceInfo) Scan(src interface{}) error     { return scanJSON(src, i) }
+
+func scanJSON(src interface{}, dest interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dest)
+	}
+}
+
+// Date es una fecha sin hora; en JSON se representa como "2006-01-02"
+type Date struct {
+	time.Time
+}
+
+const dateLayout = "2006-01-02"
+
+// ParseDate interpreta una fecha en formato 2006-01-02
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse(dateLayout, value)
+	return Date{t}, err
+}
+
+func (d Date) String() string { return d.Format(dateLayout) }
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := ParseDate(value)
+	if err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) { return d.String(), nil }
+
+func (d *Date) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case time.Time:
+		*d = Date{time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.UTC)}
+		return nil
+	case string:
+		parsed, err := ParseDate(v)
+		*d = parsed
+		return err
+	case []byte:
+		parsed, err := ParseDate(string(v))
+		*d = parsed
+		return err
+	default:
+		return fmt.Errorf("cannot scan %T into Date", src)
+	}
+}
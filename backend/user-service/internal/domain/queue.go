@@ -19,9 +19,11 @@ type QueueEntry struct {
 	AssignedAt  *time.Time `json:"assigned_at,omitempty"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
 
-	// Relaciones
-	Patient  User  `json:"patient" gorm:"foreignKey:PatientID"`
-	Employee *User `json:"employee,omitempty" gorm:"foreignKey:AssignedTo"`
+	// Relaciones. La ficha clínica no crea FK al migrar: la relación va por
+	// patients.user_id, no por la clave de esta tabla.
+	Patient        User     `json:"patient" gorm:"foreignKey:PatientID"`
+	PatientProfile *Patient `json:"patient_profile,omitempty" gorm:"foreignKey:UserID;references:PatientID;-:migration"`
+	Employee       *User    `json:"employee,omitempty" gorm:"foreignKey:AssignedTo"`
 }
 
 type Call struct {
@@ -38,9 +40,10 @@ type Call struct {
 	Notes         string     `json:"notes"`
 
 	// Relaciones
-	QueueEntry *QueueEntry `json:"queue_entry,omitempty" gorm:"foreignKey:QueueEntryID"`
-	Patient    User        `json:"patient" gorm:"foreignKey:PatientID"`
-	Employee   User        `json:"employee" gorm:"foreignKey:EmployeeID"`
+	QueueEntry     *QueueEntry `json:"queue_entry,omitempty" gorm:"foreignKey:QueueEntryID"`
+	Patient        User        `json:"patient" gorm:"foreignKey:PatientID"`
+	PatientProfile *Patient    `json:"patient_profile,omitempty" gorm:"foreignKey:UserID;references:PatientID;-:migration"`
+	Employee       User        `json:"employee" gorm:"foreignKey:EmployeeID"`
 }
 
 type CallParticipant struct {
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes que puede conceder una API key
+const (
+	ScopeQueueRead  = "queue:read"
+	ScopeCallsRead  = "calls:read"
+	ScopeCallsWrite = "calls:write"
+	ScopeUsersRead  = "users:read"
+)
+
+// Scopes es la lista de scopes válidos
+var Scopes = []string{ScopeQueueRead, ScopeCallsRead, ScopeCallsWrite, ScopeUsersRead}
+
+// Tipos de principal que aparecen en audit_logs
+const (
+	PrincipalUser           = "user"
+	PrincipalServiceAccount = "service_account"
+)
+
+// ServiceAccount es la identidad de una integración (p. ej. el sistema de citas).
+// No tiene contraseña ni rol: accede con API keys limitadas por scopes.
+type ServiceAccount struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	Name        string    `json:"name" gorm:"uniqueIndex;not null"`
+	Description string    `json:"description"`
+	IsActive    bool      `json:"is_active" gorm:"default:true"`
+	CreatedBy   uuid.UUID `json:"created_by" gorm:"type:uuid;not null"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	APIKeys []APIKey `json:"api_keys,omitempty" gorm:"foreignKey:ServiceAccountID"`
+}
+
+// APIKey es una credencial de una cuenta de servicio. El valor completo
+// (vk_<prefix>_<secreto>) solo se muestra al crearla; se guarda su hash y el
+// prefijo para identificarla en listados y logs.
+type APIKey struct {
+	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	ServiceAccountID uuid.UUID  `json:"service_account_id" gorm:"type:uuid;not null;index"`
+	Name             string     `json:"name"`
+	Prefix           string     `json:"prefix" gorm:"uniqueIndex;not null"`
+	KeyHash          string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes           string     `json:"-" gorm:"not null"` // separados por espacios
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP       string     `json:"last_used_ip,omitempty"`
+	CreatedBy        uuid.UUID  `json:"created_by" gorm:"type:uuid;not null"`
+	CreatedAt        time.Time  `json:"created_at"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ScopeList devuelve los scopes de la key como lista
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// Usable indica si la key no está revocada ni expirada
+func (k *APIKey) Usable(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// ValidScope indica si el scope existe
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// SigningKey es una clave asimétrica para firmar JWT. La privada se guarda
+// cifrada; la pública se publica en /.well-known/jwks.json mientras la clave
+// firma y durante el solapamiento posterior a su rotación (hasta RetiresAt).
+type SigningKey struct {
+	ID         string     `json:"kid" gorm:"primary_key;size:64"`
+	Algorithm  string     `json:"alg" gorm:"size:10;not null"`
+	PrivateKey string     `json:"-" gorm:"not null"`
+	PublicKey  string     `json:"-" gorm:"not null"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty"`
+	RetiresAt  *time.Time `json:"retires_at,omitempty" gorm:"index"`
+}
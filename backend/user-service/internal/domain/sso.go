@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExternalIdentity vincula una cuenta con su identidad en un proveedor OIDC
+// (issuer + sub). Un mismo usuario puede tener identidades de varios proveedores.
+// Provisioned marca las cuentas creadas por el SSO: solo en ellas manda el rol
+// que dan los grupos del IdP.
+type ExternalIdentity struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	UserID      uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	Issuer      string     `json:"issuer" gorm:"not null;uniqueIndex:idx_user_identities_issuer_subject"`
+	Subject     string     `json:"subject" gorm:"not null;uniqueIndex:idx_user_identities_issuer_subject"`
+	Email       string     `json:"email"`
+	Provisioned bool       `json:"provisioned" gorm:"not null;default:false"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+func (ExternalIdentity) TableName() string {
+	return "user_identities"
+}
+
+// OIDCLoginState guarda el state, nonce y verificador PKCE de un login SSO en
+// curso hasta que el IdP redirige al callback. Del state solo se guarda el hash.
+type OIDCLoginState struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
+	StateHash    string    `json:"-" gorm:"uniqueIndex;not null"`
+	Nonce        string    `json:"-" gorm:"not null"`
+	CodeVerifier string    `json:"-" gorm:"not null"`
+	RedirectPath string    `json:"redirect_path"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken es un token opaco de un solo uso. Cada refresh lo rota por uno
+// nuevo de la misma familia; reutilizar uno ya rotado revoca toda la familia.
+type RefreshToken struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	UserID       uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	FamilyID     uuid.UUID  `json:"family_id" gorm:"type:uuid;not null;index"`
+	TokenHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UsedAt       *time.Time `json:"used_at,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	ReplacedByID *uuid.UUID `json:"replaced_by_id,omitempty" gorm:"type:uuid"`
+}
+
+// Session es una sesión iniciada en un dispositivo. Su ID es el de la familia de
+// refresh tokens (claim sid del access token), así que revocar la sesión revoca
+// la familia y con ella los access tokens emitidos.
+type Session struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	UserID         uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	IPAddress      string     `json:"ip_address"`
+	UserAgent      string     `json:"user_agent"`
+	Device         string     `json:"device"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastActivityAt time.Time  `json:"last_activity_at"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"index"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+}
+
+// RevokedToken registra el jti de un access token invalidado antes de expirar
+type RevokedToken struct {
+	JTI       string    `json:"jti" gorm:"primary_key"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+type UserTokenPurpose string
+
+const (
+	PurposePasswordReset     UserTokenPurpose = "password_reset"
+	PurposeEmailVerification UserTokenPurpose = "email_verification"
+	// Código con el que el frontend canjea un login SSO por la sesión
+	PurposeSSOExchange UserTokenPurpose = "sso_exchange"
+	// Código que un paciente comparte con un familiar para que solicite el vínculo
+	PurposeFamilyInvite UserTokenPurpose = "family_invite"
+	// Autorización de un admin para vincular por email una cuenta de personal con su identidad SSO
+	PurposeSSOLink UserTokenPurpose = "sso_link"
+)
+
+// UserToken es un token de un solo uso enviado por correo (recuperación de
+// contraseña o verificación de email), devuelto tras un login SSO o compartido
+// como código de invitación familiar. Solo se guarda su hash.
+type UserToken struct {
+	ID        uuid.UUID        `json:"id" gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID        `json:"user_id" gorm:"type:uuid;not null;index"`
+	Purpose   UserTokenPurpose `json:"purpose" gorm:"not null"`
+	TokenHash string           `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time        `json:"expires_at"`
+	CreatedAt time.Time        `json:"created_at"`
+	UsedAt    *time.Time       `json:"used_at,omitempty"`
+}
+
+// MFARecoveryCode es un código de recuperación de un solo uso (guardado como hash)
+type MFARecoveryCode struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// WebSocketTicket es un ticket de un solo uso y vida muy corta para abrir el
+// WebSocket sin poner el access token en la URL. Queda ligado al usuario y a la
+// sesión del JWT con el que se pidió; solo se almacena su hash.
+type WebSocketTicket struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key"`
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	SessionID  *uuid.UUID `json:"session_id,omitempty" gorm:"type:uuid"`
+	TicketHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"index"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+}
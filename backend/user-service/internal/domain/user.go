@@ -1,9 +1,11 @@
 package domain
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type UserRole string
@@ -15,6 +17,12 @@ const (
 	RoleAdmin    UserRole = "admin"
 )
 
+// NormalizeEmail es la forma en que se guardan y buscan los emails: sin espacios
+// alrededor y en minúsculas, para que dos cuentas no difieran solo en mayúsculas
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 type User struct {
 	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key"`
 	Email        string    `json:"email" gorm:"uniqueIndex"`
@@ -25,4 +33,33 @@ type User struct {
 	IsActive     bool      `json:"is_active" gorm:"default:true"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+
+	EmailVerified   bool       `json:"email_verified" gorm:"default:false"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+
+	// MFA (TOTP). El secreto se guarda cifrado; mientras MFAEnabled es false es
+	// un alta pendiente de confirmar con un primer código.
+	MFAEnabled   bool       `json:"mfa_enabled" gorm:"default:false"`
+	MFASecret    string     `json:"-"`
+	MFALastStep  int64      `json:"-" gorm:"default:0"`
+	MFAEnabledAt *time.Time `json:"mfa_enabled_at,omitempty"`
+
+	// Protección contra fuerza bruta: fallos consecutivos de contraseña (o de
+	// segundo factor) y bloqueo temporal de la cuenta
+	FailedLoginAttempts int        `json:"-" gorm:"default:0"`
+	LastFailedLoginAt   *time.Time `json:"-"`
+	LockedUntil         *time.Time `json:"locked_until,omitempty"`
+
+	// Consentimientos vigentes (migración 006). ConsentVersion es la versión del
+	// documento aceptada; si no es la actual el paciente debe volver a aceptarlo.
+	RecordingConsent  bool       `json:"recording_consent" gorm:"default:false"`
+	LivestreamConsent bool       `json:"livestream_consent" gorm:"default:false"`
+	ConsentAcceptedAt *time.Time `json:"consent_accepted_at,omitempty"`
+	ConsentVersion    string     `json:"consent_version,omitempty" gorm:"size:50"`
+
+	// Borrado lógico: GORM excluye estas filas de las consultas. Un usuario
+	// borrado puede restaurarse salvo que se haya ejercido el derecho de
+	// supresión (ErasedAt), que anonimiza sus datos de forma irreversible.
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+	ErasedAt  *time.Time     `json:"erased_at,omitempty"`
 }
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/mail"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultPasswordResetTTL     = time.Hour
+	defaultEmailVerificationTTL = 48 * time.Hour
+	defaultAccountSetupTTL      = 7 * 24 * time.Hour
+	mailTimeout                 = 30 * time.Second
+)
+
+// AccountHandler gestiona la recuperación de contraseña y la verificación de email
+type AccountHandler struct {
+	userRepo        repository.UserRepository
+	userTokenRepo   repository.UserTokenRepository
+	tokenRepo       repository.TokenRepository
+	mailer          mail.EmailSender
+	frontendURL     string
+	resetTTL        time.Duration
+	verificationTTL time.Duration
+	setupTTL        time.Duration
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func NewAccountHandler(userRepo repository.UserRepository, userTokenRepo repository.UserTokenRepository, tokenRepo repository.TokenRepository, mailer mail.EmailSender) *AccountHandler {
+	return &AccountHandler{
+		userRepo:        userRepo,
+		userTokenRepo:   userTokenRepo,
+		tokenRepo:       tokenRepo,
+		mailer:          mailer,
+		frontendURL:     frontendURL(),
+		resetTTL:        durationFromEnv("PASSWORD_RESET_TTL", defaultPasswordResetTTL),
+		verificationTTL: durationFromEnv("EMAIL_VERIFICATION_TTL", defaultEmailVerificationTTL),
+		setupTTL:        durationFromEnv("ACCOUNT_SETUP_TTL", defaultAccountSetupTTL),
+	}
+}
+
+// ForgotPassword - Envía un enlace de recuperación. Responde igual exista o no la
+// cuenta para no revelar qué emails están registrados.
+func (h *AccountHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if user, err := h.userRepo.GetByEmail(domain.NormalizeEmail(req.Email)); err == nil && user.IsActive {
+		// El envío va en segundo plano para que el tiempo de respuesta tampoco lo delate
+		go func() {
+			if err := h.sendPasswordReset(user); err != nil {
+				log.Printf("Error sending password reset to user %s: %v", user.ID, err)
+			}
+		}()
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a password reset link has been sent"})
+}
+
+// ResetPassword - Cambia la contraseña con un token de recuperación y cierra todas las sesiones
+func (h *AccountHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.userTokenRepo.Consume(hashToken(req.Token), domain.PurposePasswordReset)
+	if err != nil {
+		h.respondTokenError(c, err)
+		return
+	}
+
+	user, err := h.userRepo.GetByID(token.UserID)
+	if err != nil || !user.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	now := time.Now()
+	user.PasswordHash = string(hashedPassword)
+	user.UpdatedAt = now
+	// El enlace llegó a su buzón: el email queda verificado
+	if !user.EmailVerified {
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := h.userRepo.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	if err := h.tokenRepo.RevokeAllForUser(user.ID); err != nil {
+		log.Printf("Error revoking sessions for user %s after password reset: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
+}
+
+// VerifyEmail - Confirma el email con el token enviado al registrarse
+func (h *AccountHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.userTokenRepo.Consume(hashToken(req.Token), domain.PurposeEmailVerification)
+	if err != nil {
+		h.respondTokenError(c, err)
+		return
+	}
+
+	user, err := h.userRepo.GetByID(token.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+
+	if !user.EmailVerified {
+		now := time.Now()
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+		user.UpdatedAt = now
+		if err := h.userRepo.Update(user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerification - Reenvía el enlace de verificación al usuario autenticado
+func (h *AccountHandler) ResendVerification(c *gin.Context) {
+	user, err := h.userRepo.GetByID(c.MustGet("user_id").(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.EmailVerified {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already verified"})
+		return
+	}
+
+	if err := h.SendVerificationEmail(user); err != nil {
+		log.Printf("Error sending verification email to user %s: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
+
+// SendVerificationEmail genera un token de verificación y lo envía por correo
+func (h *AccountHandler) SendVerificationEmail(user *domain.User) error {
+	raw, err := h.createToken(user, domain.PurposeEmailVerification, h.verificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := h.frontendURL + "/verify-email?token=" + url.QueryEscape(raw)
+	return h.send(mail.Message{
+		To:      user.Email,
+		Subject: "Confirma tu correo en Vincula",
+		Body: fmt.Sprintf("Hola %s,\n\nConfirma tu dirección de correo abriendo este enlace:\n\n%s\n\n"+
+			"El enlace caduca en %s. Si no creaste una cuenta en Vincula, ignora este mensaje.\n",
+			user.FirstName, link, h.verificationTTL),
+	})
+}
+
+// SendAccountSetup envía a una cuenta creada sin contraseña (importación) el
+// enlace para elegirla. Usa el mismo flujo que la recuperación, con más validez.
+func (h *AccountHandler) SendAccountSetup(user *domain.User) error {
+	raw, err := h.createToken(user, domain.PurposePasswordReset, h.setupTTL)
+	if err != nil {
+		return err
+	}
+
+	link := h.frontendURL + "/reset-password?token=" + url.QueryEscape(raw)
+	return h.send(mail.Message{
+		To:      user.Email,
+		Subject: "Bienvenido a Vincula",
+		Body: fmt.Sprintf("Hola %s,\n\nTu clínica te ha dado de alta en Vincula. Para activar tu cuenta elige una contraseña abriendo este enlace:\n\n%s\n\n"+
+			"El enlace caduca en %s y solo puede usarse una vez. Si caduca, usa \"He olvidado mi contraseña\" con este correo.\n",
+			user.FirstName, link, h.setupTTL),
+	})
+}
+
+func (h *AccountHandler) sendPasswordReset(user *domain.User) error {
+	raw, err := h.createToken(user, domain.PurposePasswordReset, h.resetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := h.frontendURL + "/reset-password?token=" + url.QueryEscape(raw)
+	return h.send(mail.Message{
+		To:      user.Email,
+		Subject: "Restablece tu contraseña de Vincula",
+		Body: fmt.Sprintf("Hola %s,\n\nPara elegir una nueva contraseña abre este enlace:\n\n%s\n\n"+
+			"El enlace caduca en %s y solo puede usarse una vez. Si no lo solicitaste, ignora este mensaje.\n",
+			user.FirstName, link, h.resetTTL),
+	})
+}
+
+// createToken guarda el hash de un token nuevo y devuelve el valor en claro para el enlace
+func (h *AccountHandler) createToken(user *domain.User, purpose domain.UserTokenPurpose, ttl time.Duration) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	token := &domain.UserToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(ttl),
+		CreatedAt: time.Now(),
+	}
+	if err := h.userTokenRepo.Create(token); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (h *AccountHandler) send(msg mail.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+	return h.mailer.Send(ctx, msg)
+}
+
+func (h *AccountHandler) respondTokenError(c *gin.Context, err error) {
+	if errors.Is(err, repository.ErrUserTokenInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate token"})
+}
+
+// CleanupExpiredTokens borra periódicamente los tokens de correo expirados
+func (h *AccountHandler) CleanupExpiredTokens(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := h.userTokenRepo.DeleteExpired(time.Now()); err != nil {
+			log.Printf("Error cleaning up expired account tokens: %v", err)
+		}
+	}
+}
+
+// frontendURL es la base de los enlaces que se envían por correo
+func frontendURL() string {
+	base := os.Getenv("FRONTEND_URL")
+	if base == "" {
+		base = "http://localhost:3000"
+	}
+	return strings.TrimRight(base, "/")
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// fakeLookupUserRepo anota los emails que se buscan
+type fakeLookupUserRepo struct {
+	repository.UserRepository
+	lookups []string
+}
+
+func (r *fakeLookupUserRepo) GetByEmail(email string) (*domain.User, error) {
+	r.lookups = append(r.lookups, email)
+	return nil, gorm.ErrRecordNotFound
+}
+
+func TestEmailLookupsAreNormalized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		handler func(repo *fakeLookupUserRepo) gin.HandlerFunc
+	}{
+		{"login", "/api/auth/login", `{"email": "Ana.Garcia@Clinica.Local", "password": "secreto"}`,
+			func(repo *fakeLookupUserRepo) gin.HandlerFunc {
+				h := &AuthHandler{userRepo: repo, guard: newTestLoginGuard()}
+				return h.Login
+			}},
+		{"recuperar contraseña", "/api/auth/forgot-password", `{"email": "Ana.Garcia@Clinica.Local"}`,
+			func(repo *fakeLookupUserRepo) gin.HandlerFunc {
+				h := &AccountHandler{userRepo: repo}
+				return h.ForgotPassword
+			}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeLookupUserRepo{}
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			tt.handler(repo)(c)
+
+			if len(repo.lookups) != 1 || repo.lookups[0] != "ana.garcia@clinica.local" {
+				t.Errorf("looked up %v, want [ana.garcia@clinica.local] (status %d)", repo.lookups, w.Code)
+			}
+		})
+	}
+}
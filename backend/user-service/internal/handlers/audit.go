@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"user-service/internal/auditchain"
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAuditBatchSize     = 100
+	defaultAuditFlushInterval = 2 * time.Second
+	// auditBufferSize es cuántas entradas pueden esperar a escribirse antes de
+	// que las peticiones tengan que escribirlas ellas mismas
+	auditBufferSize = 10000
+	// Un lote que falla se reintenta auditMaxAttempts veces, doblando la espera
+	auditMaxAttempts         = 5
+	defaultAuditRetryBackoff = 500 * time.Millisecond
+)
+
+// writeAudit registra una acción en audit_logs. userID es el usuario afectado o
+// que actúa; si la petición la hace una cuenta de servicio se registra como
+// principal service_account en lugar de usuario.
+func writeAudit(repo repository.AuditRepository, c *gin.Context, action string, userID *uuid.UUID, resourceType, resourceID string, details gin.H) {
+	entry := newAuditEntry(c, action, userID, resourceType, resourceID, details)
+	if err := repo.Create(entry); err != nil {
+		log.Printf("Error writing audit log %s: %v", action, err)
+	}
+}
+
+func newAuditEntry(c *gin.Context, action string, userID *uuid.UUID, resourceType, resourceID string, details gin.H) *domain.AuditLog {
+	entry := &domain.AuditLog{
+		ID:            uuid.New(),
+		PrincipalType: domain.PrincipalUser,
+		UserID:        userID,
+		Action:        action,
+		ResourceType:  resourceType,
+		ResourceID:    resourceID,
+		UserAgent:     c.Request.UserAgent(),
+		Timestamp:     time.Now(),
+	}
+	if principal, ok := principalFrom(c); ok && principal.IsServiceAccount() {
+		entry.PrincipalType = domain.PrincipalServiceAccount
+		entry.ServiceAccountID = &principal.UserID
+	}
+	// Con un token de suplantación la acción es del admin, no del usuario suplantado
+	if actorID, ok := c.Get("impersonator_id"); ok {
+		actor := actorID.(uuid.UUID)
+		withActor := gin.H{"impersonation_jti": c.GetString("token_jti")}
+		if principal, ok := principalFrom(c); ok {
+			withActor["impersonated_user_id"] = principal.UserID
+		}
+		for key, value := range details {
+			withActor[key] = value
+		}
+		details = withActor
+		entry.UserID = &actor
+	}
+	if ip := c.ClientIP(); ip != "" {
+		entry.IPAddress = &ip
+	}
+	if raw, err := json.Marshal(details); err == nil {
+		entry.RequestDetails = raw
+	}
+	return entry
+}
+
+// AuditWriter escribe en audit_logs los accesos a datos de salud en segundo
+// plano y por lotes, para que la auditoría no añada latencia a las peticiones
+type AuditWriter struct {
+	repo          repository.AuditRepository
+	entries       chan *domain.AuditLog
+	batchSize     int
+	flushInterval time.Duration
+	retryBackoff  time.Duration
+	done          chan struct{}
+}
+
+func NewAuditWriter(repo repository.AuditRepository) *AuditWriter {
+	return &AuditWriter{
+		repo:          repo,
+		entries:       make(chan *domain.AuditLog, auditBufferSize),
+		batchSize:     intFromEnv("AUDIT_BATCH_SIZE", defaultAuditBatchSize),
+		flushInterval: durationFromEnv("AUDIT_FLUSH_INTERVAL", defaultAuditFlushInterval),
+		retryBackoff:  defaultAuditRetryBackoff,
+		done:          make(chan struct{}),
+	}
+}
+
+// Run agrupa las entradas y las escribe al completar un lote o cada
+// flushInterval, lo que ocurra antes. Al cancelarse ctx vacía la cola, escribe
+// lo pendiente y cierra Done; se cancela después de parar el servidor HTTP
+// para que no lleguen entradas nuevas.
+func (w *AuditWriter) Run(ctx context.Context) {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*domain.AuditLog, 0, w.batchSize)
+	for {
+		select {
+		case entry := <-w.entries:
+			batch = append(batch, entry)
+			if len(batch) < w.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-ctx.Done():
+			w.drain(batch)
+			return
+		}
+
+		w.flush(batch)
+		batch = make([]*domain.AuditLog, 0, w.batchSize)
+	}
+}
+
+// Done se cierra cuando Run ha escrito todo lo pendiente
+func (w *AuditWriter) Done() <-chan struct{} {
+	return w.done
+}
+
+// drain escribe el lote en curso y lo que quede en la cola
+func (w *AuditWriter) drain(batch []*domain.AuditLog) {
+	for {
+		select {
+		case entry := <-w.entries:
+			batch = append(batch, entry)
+			if len(batch) >= w.batchSize {
+				w.flush(batch)
+				batch = make([]*domain.AuditLog, 0, w.batchSize)
+			}
+		default:
+			if len(batch) > 0 {
+				w.flush(batch)
+			}
+			return
+		}
+	}
+}
+
+// flush reintenta el lote con backoff exponencial; si sigue fallando escribe
+// las entradas una a una para que una fila inválida no se lleve el resto, y
+// las que aun así no se pueden escribir quedan completas en el log
+func (w *AuditWriter) flush(batch []*domain.AuditLog) {
+	backoff := w.retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := w.repo.CreateBatch(batch)
+		if err == nil {
+			return
+		}
+		if attempt == auditMaxAttempts {
+			log.Printf("Error writing %d audit log entries after %d attempts, writing them one by one: %v", len(batch), attempt, err)
+			break
+		}
+		log.Printf("Error writing %d audit log entries (attempt %d), retrying in %s: %v", len(batch), attempt, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	for _, entry := range batch {
+		// Un lote que sí llegó a escribirse aunque fallara la respuesta da clave duplicada
+		if err := w.repo.Create(entry); err != nil && !errors.Is(err, gorm.ErrDuplicatedKey) {
+			raw, _ := json.Marshal(entry)
+			log.Printf("Lost audit log entry %s: %v", raw, err)
+		}
+	}
+}
+
+// Record encola una entrada. Si la cola está llena se escribe en la propia
+// petición: es preferible añadir latencia a perder registros de auditoría.
+func (w *AuditWriter) Record(entry *domain.AuditLog) {
+	select {
+	case w.entries <- entry:
+	default:
+		if err := w.repo.Create(entry); err != nil {
+			log.Printf("Error writing audit log %s: %v", entry.Action, err)
+		}
+	}
+}
+
+// AuditAccess registra cada petición del grupo como un acceso a resourceType,
+// con el ID tomado del parámetro idParam de la ruta (si lo hay), el principal
+// autenticado, su sesión y el código de respuesta. Va después del middleware de
+// autenticación para que también queden registrados los accesos denegados.
+func (w *AuditWriter) AuditAccess(resourceType, idParam string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		principal, ok := principalFrom(c)
+		if !ok {
+			return
+		}
+
+		var userID *uuid.UUID
+		if !principal.IsServiceAccount() {
+			userID = &principal.UserID
+		}
+		entry := newAuditEntry(c, domain.AuditPHIAccess, userID, resourceType, c.Param(idParam), gin.H{
+			"method": c.Request.Method,
+			"route":  c.FullPath(),
+			"path":   c.Request.URL.Path,
+		})
+
+		status := c.Writer.Status()
+		entry.ResponseStatus = &status
+		if sessionID, ok := c.Get("session_id"); ok {
+			entry.SessionID = sessionID.(uuid.UUID).String()
+		}
+
+		w.Record(entry)
+	}
+}
+
+// AuditCheckpointer firma periódicamente la cabeza de la cadena de audit_logs.
+// Entre dos checkpoints, quien pueda escribir en la base de datos podría
+// reescribir las filas más recientes; el intervalo acota esa ventana.
+type AuditCheckpointer struct {
+	repo   repository.AuditRepository
+	signer *auditchain.Signer
+}
+
+func NewAuditCheckpointer(repo repository.AuditRepository, signer *auditchain.Signer) *AuditCheckpointer {
+	return &AuditCheckpointer{repo: repo, signer: signer}
+}
+
+// CreateCheckpoints firma la cabeza de la cadena cada interval si ha avanzado
+// desde el último checkpoint
+func (a *AuditCheckpointer) CreateCheckpoints(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := a.checkpoint(); err != nil {
+			log.Printf("Error creating audit checkpoint: %v", err)
+		}
+	}
+}
+
+func (a *AuditCheckpointer) checkpoint() error {
+	seq, hash, err := a.repo.ChainHead()
+	if err != nil {
+		return err
+	}
+	if seq == 0 {
+		return nil
+	}
+
+	last, err := a.repo.LastCheckpoint()
+	if err != nil {
+		return err
+	}
+	if last != nil && last.ChainSeq >= seq {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	checkpoint := &domain.AuditCheckpoint{
+		ID:        uuid.New(),
+		ChainSeq:  seq,
+		RowHash:   hash,
+		KeyID:     a.signer.KeyID(),
+		Signature: a.signer.Sign(seq, hash, now.Unix()),
+		SignedAt:  now.Unix(),
+		CreatedAt: now,
+	}
+	if err := a.repo.CreateCheckpoint(checkpoint); err != nil {
+		return err
+	}
+	// Quien reescriba la base de datos también puede borrar checkpoints: el log
+	// permite guardar el último fuera de ella y pasarlo a audit-verify -pin
+	log.Printf("Audit checkpoint PIN %d:%s", seq, hash)
+	return nil
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+	// auditExportFlushRows es cada cuántas filas se envía al cliente lo exportado
+	auditExportFlushRows = 500
+)
+
+// auditCSVHeader son las columnas de la exportación CSV
+var auditCSVHeader = []string{
+	"id", "timestamp", "principal_type", "user_id", "service_account_id", "action",
+	"resource_type", "resource_id", "ip_address", "user_agent", "response_status",
+	"session_id", "request_details", "chain_seq", "row_hash",
+}
+
+type AuditHandler struct {
+	auditRepo repository.AuditRepository
+}
+
+func NewAuditHandler(auditRepo repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{auditRepo: auditRepo}
+}
+
+// GetAuditLogs - Consulta de audit_logs para cumplimiento (solo admin). Con
+// format=csv o format=ndjson se exporta todo lo que cumple el filtro en
+// streaming y la exportación queda registrada como un evento más.
+func (h *AuditHandler) GetAuditLogs(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch format := c.DefaultQuery("format", "json"); format {
+	case "json":
+		h.listAuditLogs(c, filter)
+	case "csv", "ndjson":
+		h.exportAuditLogs(c, filter, format)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid format %q, expected json, csv or ndjson", format)})
+	}
+}
+
+func (h *AuditHandler) listAuditLogs(c *gin.Context, filter repository.AuditFilter) {
+	page, err := h.auditRepo.List(filter)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get audit logs"})
+		return
+	}
+
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	if page.Entries == nil {
+		page.Entries = []domain.AuditLog{}
+	}
+	c.JSON(http.StatusOK, page.Entries)
+}
+
+func (h *AuditHandler) exportAuditLogs(c *gin.Context, filter repository.AuditFilter, format string) {
+	var (
+		rows     int
+		csvOut   *csv.Writer
+		started  bool
+		encoder  = json.NewEncoder(c.Writer)
+		filename = fmt.Sprintf("audit-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	)
+
+	// Las cabeceras se envían con la primera fila para poder responder un error
+	// JSON si la consulta falla antes de empezar
+	start := func() {
+		started = true
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		if format == "csv" {
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Status(http.StatusOK)
+			csvOut = csv.NewWriter(c.Writer)
+			csvOut.Write(auditCSVHeader)
+			return
+		}
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+	}
+
+	err := h.auditRepo.Stream(filter, func(entry *domain.AuditLog) error {
+		if !started {
+			start()
+		}
+		rows++
+
+		if csvOut != nil {
+			if err := csvOut.Write(auditCSVRecord(entry)); err != nil {
+				return err
+			}
+		} else if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+
+		if rows%auditExportFlushRows == 0 {
+			if csvOut != nil {
+				csvOut.Flush()
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+
+	if err == nil && !started {
+		start()
+	}
+	if csvOut != nil {
+		csvOut.Flush()
+	}
+
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	details := gin.H{"format": format, "query": c.Request.URL.Query(), "rows": rows}
+	if err != nil {
+		log.Printf("Error exporting audit logs for admin %s after %d rows: %v", adminID, rows, err)
+		details["error"] = "export interrupted"
+	}
+	writeAudit(h.auditRepo, c, domain.AuditLogExported, &adminID, "audit_log", "", details)
+
+	if err != nil && !started {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export audit logs"})
+	}
+}
+
+// parseAuditFilter lee los parámetros de la consulta de auditoría:
+//
+//	user_id, service_account_id  principal que actúa
+//	resource_type, resource_id   recurso accedido (p. ej. call y su ID)
+//	action                       acción exacta (p. ej. phi_access)
+//	from, to                     fecha (2006-01-02, ambos días incluidos) o RFC3339
+//	status                       código de respuesta exacto (403) o clase (4xx)
+//	limit, cursor                tamaño de página y X-Next-Cursor de la anterior (solo format=json)
+func parseAuditFilter(c *gin.Context) (repository.AuditFilter, error) {
+	filter := repository.AuditFilter{
+		ResourceType: c.Query("resource_type"),
+		ResourceID:   c.Query("resource_id"),
+		Action:       c.Query("action"),
+		Limit:        defaultAuditPageSize,
+		Cursor:       c.Query("cursor"),
+	}
+
+	if value := c.Query("user_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid user_id %q", value)
+		}
+		filter.UserID = &id
+	}
+	if value := c.Query("service_account_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid service_account_id %q", value)
+		}
+		filter.ServiceAccountID = &id
+	}
+
+	if value := c.Query("from"); value != "" {
+		from, _, err := parseDateParam(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from %q", value)
+		}
+		filter.From = &from
+	}
+	if value := c.Query("to"); value != "" {
+		to, dateOnly, err := parseDateParam(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to %q", value)
+		}
+		// Una fecha sin hora incluye todo ese día
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.To = &to
+	}
+
+	if value := c.Query("status"); value != "" {
+		min, max, err := parseStatusParam(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.StatusMin, filter.StatusMax = min, max
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditPageSize {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxAuditPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// parseStatusParam acepta un código HTTP (403) o una clase (4xx) y devuelve el
+// rango inclusivo que cubre
+func parseStatusParam(value string) (int, int, error) {
+	invalid := fmt.Errorf("invalid status %q, expected a code like 403 or a class like 4xx", value)
+	if len(value) != 3 {
+		return 0, 0, invalid
+	}
+	if strings.HasSuffix(strings.ToLower(value), "xx") {
+		class, err := strconv.Atoi(value[:1])
+		if err != nil || class < 1 || class > 5 {
+			return 0, 0, invalid
+		}
+		return class * 100, class*100 + 99, nil
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil || code < 100 || code > 599 {
+		return 0, 0, invalid
+	}
+	return code, code, nil
+}
+
+func auditCSVRecord(entry *domain.AuditLog) []string {
+	optional := func(id *uuid.UUID) string {
+		if id == nil {
+			return ""
+		}
+		return id.String()
+	}
+	ip := ""
+	if entry.IPAddress != nil {
+		ip = *entry.IPAddress
+	}
+	status := ""
+	if entry.ResponseStatus != nil {
+		status = strconv.Itoa(*entry.ResponseStatus)
+	}
+	chainSeq := ""
+	if entry.ChainSeq != nil {
+		chainSeq = strconv.FormatInt(*entry.ChainSeq, 10)
+	}
+
+	return []string{
+		entry.ID.String(),
+		entry.Timestamp.UTC().Format(time.RFC3339Nano),
+		entry.PrincipalType,
+		optional(entry.UserID),
+		optional(entry.ServiceAccountID),
+		csvSafe(entry.Action),
+		csvSafe(entry.ResourceType),
+		csvSafe(entry.ResourceID),
+		ip,
+		csvSafe(entry.UserAgent),
+		status,
+		csvSafe(entry.SessionID),
+		csvSafe(string(entry.RequestDetails)),
+		chainSeq,
+		entry.RowHash,
+	}
+}
+
+// csvSafe evita que una hoja de cálculo interprete como fórmula un valor que
+// controla el cliente (user agent, IDs de la URL)
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// fakeAuditRepo guarda las entradas escritas; failBatches hace fallar las
+// primeras llamadas a CreateBatch y failIDs las escrituras de esas entradas
+type fakeAuditRepo struct {
+	repository.AuditRepository
+
+	mu          sync.Mutex
+	failBatches int
+	failIDs     map[uuid.UUID]error
+	batchCalls  int
+	written     map[uuid.UUID]bool
+}
+
+func newFakeAuditRepo() *fakeAuditRepo {
+	return &fakeAuditRepo{failIDs: map[uuid.UUID]error{}, written: map[uuid.UUID]bool{}}
+}
+
+func (r *fakeAuditRepo) CreateBatch(entries []*domain.AuditLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batchCalls++
+	if r.batchCalls <= r.failBatches {
+		return errors.New("connection refused")
+	}
+	for _, entry := range entries {
+		if err := r.failIDs[entry.ID]; err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		r.written[entry.ID] = true
+	}
+	return nil
+}
+
+func (r *fakeAuditRepo) Create(entry *domain.AuditLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.failIDs[entry.ID]; err != nil {
+		return err
+	}
+	r.written[entry.ID] = true
+	return nil
+}
+
+func newTestAuditWriter(repo repository.AuditRepository) *AuditWriter {
+	return &AuditWriter{
+		repo:          repo,
+		entries:       make(chan *domain.AuditLog, 100),
+		batchSize:     10,
+		flushInterval: time.Hour,
+		retryBackoff:  time.Millisecond,
+		done:          make(chan struct{}),
+	}
+}
+
+func testAuditEntries(n int) []*domain.AuditLog {
+	entries := make([]*domain.AuditLog, n)
+	for i := range entries {
+		entries[i] = &domain.AuditLog{ID: uuid.New(), Action: domain.AuditPHIAccess}
+	}
+	return entries
+}
+
+func TestAuditWriterDrainsOnShutdown(t *testing.T) {
+	repo := newFakeAuditRepo()
+	w := newTestAuditWriter(repo)
+	// Sin llenar un lote ni llegar al intervalo: solo se escriben al parar
+	entries := testAuditEntries(25)
+	for _, entry := range entries {
+		w.Record(entry)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go w.Run(ctx)
+	cancel()
+
+	select {
+	case <-w.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not finish after cancelling the context")
+	}
+	for _, entry := range entries {
+		if !repo.written[entry.ID] {
+			t.Fatalf("entry %s was not written on shutdown", entry.ID)
+		}
+	}
+}
+
+func TestAuditWriterFlush(t *testing.T) {
+	tests := []struct {
+		name        string
+		failBatches int
+		failEntry   error
+		wantWritten int
+	}{
+		{"primer intento", 0, nil, 5},
+		{"reintento tras fallos transitorios", auditMaxAttempts - 1, nil, 5},
+		{"fallo persistente: una a una", auditMaxAttempts, nil, 5},
+		{"una entrada inválida no se lleva el lote", 0, errors.New("invalid input syntax"), 4},
+		{"lote ya escrito: la clave duplicada no es una pérdida", 0, gorm.ErrDuplicatedKey, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeAuditRepo()
+			repo.failBatches = tt.failBatches
+			entries := testAuditEntries(5)
+			if tt.failEntry != nil {
+				repo.failIDs[entries[2].ID] = tt.failEntry
+			}
+
+			newTestAuditWriter(repo).flush(entries)
+
+			if len(repo.written) != tt.wantWritten {
+				t.Errorf("written %d entries, want %d", len(repo.written), tt.wantWritten)
+			}
+			if tt.failBatches < auditMaxAttempts && tt.failEntry == nil && repo.batchCalls != tt.failBatches+1 {
+				t.Errorf("CreateBatch called %d times, want %d", repo.batchCalls, tt.failBatches+1)
+			}
+		})
+	}
+}
@@ -1,22 +1,40 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"user-service/internal/domain"
+	"user-service/internal/keys"
+	"user-service/internal/mfa"
 	"user-service/internal/repository"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthHandler struct {
-	userRepo  repository.UserRepository
-	jwtSecret string
+	userRepo   repository.UserRepository
+	tokenRepo  repository.TokenRepository
+	mfaRepo    repository.MFARepository
+	accounts   *AccountHandler
+	guard      *LoginGuard
+	apiKeys    *ServiceAccountHandler
+	consents   *ConsentHandler
+	keys       *keys.Manager
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+
+	mfaCipher        *mfa.Cipher
+	mfaIssuer        string
+	mfaRequiredRoles map[domain.UserRole]bool
+	impersonationTTL time.Duration
 }
 
 type LoginRequest struct {
@@ -24,18 +42,31 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// RegisterRequest es el registro público; las cuentas de personal (employee/admin)
+// solo se crean por invitación (InvitationHandler)
 type RegisterRequest struct {
 	Email     string          `json:"email" binding:"required,email"`
 	Password  string          `json:"password" binding:"required,min=6"`
 	FirstName string          `json:"first_name" binding:"required"`
 	LastName  string          `json:"last_name" binding:"required"`
-	Role      domain.UserRole `json:"role" binding:"required,oneof=patient employee family admin"`
+	Role      domain.UserRole `json:"role" binding:"required,oneof=patient family"`
+
+	// Aceptación expresa del documento de consentimiento: sin ella, o sobre otra
+	// versión que la vigente, no se crea la cuenta
+	AcceptTerms  bool   `json:"accept_terms"`
+	TermsVersion string `json:"terms_version"`
+
+	// Permisos marcados en el formulario; solo se registran los concedidos
+	RecordingConsent  bool `json:"recording_consent"`
+	LivestreamConsent bool `json:"livestream_consent"`
 }
 
 type AuthResponse struct {
-	Token     string      `json:"token"`
-	User      domain.User `json:"user"`
-	ExpiresAt time.Time   `json:"expires_at"`
+	Token            string      `json:"token"`
+	RefreshToken     string      `json:"refresh_token"`
+	User             domain.User `json:"user"`
+	ExpiresAt        time.Time   `json:"expires_at"`
+	RefreshExpiresAt time.Time   `json:"refresh_expires_at"`
 }
 
 type UserResponse struct {
@@ -47,12 +78,38 @@ type UserResponse struct {
 	IsActive  bool            `json:"is_active"`
 	CreatedAt time.Time       `json:"created_at"`
 	UpdatedAt time.Time       `json:"updated_at"`
+
+	EmailVerified bool       `json:"email_verified"`
+	MFAEnabled    bool       `json:"mfa_enabled"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	ErasedAt      *time.Time `json:"erased_at,omitempty"`
+
+	// Admin que suplanta al usuario (solo en /me con un token de suplantación)
+	ImpersonatedBy *uuid.UUID `json:"impersonated_by,omitempty"`
 }
 
-func NewAuthHandler(userRepo repository.UserRepository, jwtSecret string) *AuthHandler {
+func NewAuthHandler(userRepo repository.UserRepository, tokenRepo repository.TokenRepository, mfaRepo repository.MFARepository, accounts *AccountHandler, guard *LoginGuard, apiKeys *ServiceAccountHandler, consents *ConsentHandler, mfaCipher *mfa.Cipher, keyManager *keys.Manager) *AuthHandler {
+	issuer := os.Getenv("MFA_ISSUER")
+	if issuer == "" {
+		issuer = "Vincula"
+	}
+
 	return &AuthHandler{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
+		userRepo:         userRepo,
+		tokenRepo:        tokenRepo,
+		mfaRepo:          mfaRepo,
+		accounts:         accounts,
+		guard:            guard,
+		apiKeys:          apiKeys,
+		consents:         consents,
+		keys:             keyManager,
+		accessTTL:        durationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL),
+		refreshTTL:       durationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL),
+		mfaCipher:        mfaCipher,
+		mfaIssuer:        issuer,
+		mfaRequiredRoles: mfaRequiredRolesFromEnv(),
+		impersonationTTL: durationFromEnv("IMPERSONATION_TTL", defaultImpersonationTTL),
 	}
 }
 
@@ -62,10 +119,13 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.Email = domain.NormalizeEmail(req.Email)
+	if !h.consents.requireSignupTerms(c, req.AcceptTerms, req.TermsVersion) {
+		return
+	}
 
 	// Verificar si el usuario ya existe
-	existingUser, _ := h.userRepo.GetByEmail(req.Email)
-	if existingUser != nil {
+	if taken, _ := h.userRepo.EmailTaken(req.Email); taken {
 		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
 		return
 	}
@@ -95,18 +155,27 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Generar token
-	token, expiresAt, err := h.generateJWT(user)
+	// Si no se puede guardar lo aceptado la cuenta se crea igual y el paciente
+	// aceptará los términos antes de unirse a la cola
+	if err := h.consents.recordRegistration(c, user.ID, req.RecordingConsent, req.LivestreamConsent); err != nil {
+		log.Printf("Error recording consent of user %s: %v", user.ID, err)
+	}
+
+	// La cuenta funciona sin verificar, pero no puede unirse a la cola hasta confirmar el email
+	go func() {
+		if err := h.accounts.SendVerificationEmail(user); err != nil {
+			log.Printf("Error sending verification email to user %s: %v", user.ID, err)
+		}
+	}()
+
+	// Generar tokens
+	resp, err := h.issueSession(c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, AuthResponse{
-		Token:     token,
-		User:      *user,
-		ExpiresAt: expiresAt,
-	})
+	c.JSON(http.StatusCreated, resp)
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -115,34 +184,55 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.Email = domain.NormalizeEmail(req.Email)
+
+	// Una IP o cuenta en espera/bloqueada no llega a comparar la contraseña
+	if !h.guard.Allow(c, nil) {
+		return
+	}
 
 	user, err := h.userRepo.GetByEmail(req.Email)
 	if err != nil {
+		h.guard.RecordFailure(c, nil, req.Email)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
+	if !h.guard.Allow(c, user) {
+		return
+	}
+
 	if !user.IsActive {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is inactive"})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		h.guard.RecordFailure(c, user, req.Email)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	token, expiresAt, err := h.generateJWT(user)
+	h.completeLogin(c, user)
+}
+
+// completeLogin termina un login con el primer factor ya verificado (contraseña
+// o SSO): pide el segundo factor si corresponde o emite la sesión
+func (h *AuthHandler) completeLogin(c *gin.Context, user *domain.User) {
+	// Con MFA el primer factor solo da un token intermedio para el segundo paso
+	if user.MFAEnabled || h.mfaRequired(user.Role) {
+		h.respondMFAChallenge(c, user)
+		return
+	}
+
+	resp, err := h.issueSession(c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, AuthResponse{
-		Token:     token,
-		User:      *user,
-		ExpiresAt: expiresAt,
-	})
+	h.guard.RecordSuccess(user)
+	c.JSON(http.StatusOK, resp)
 }
 
 func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
@@ -158,21 +248,46 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, h.toUserResponse(user))
+	resp := h.toUserResponse(user)
+	if actorID, ok := c.Get("impersonator_id"); ok {
+		actor := actorID.(uuid.UUID)
+		resp.ImpersonatedBy = &actor
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
+// GetUsers - Lista paginada de usuarios. El total va en X-Total-Count y el
+// cursor de la página siguiente en X-Next-Cursor (ver parseUserFilter).
 func (h *AuthHandler) GetUsers(c *gin.Context) {
-	users, err := h.userRepo.GetAll()
+	filter, err := parseUserFilter(c)
+	if errors.Is(err, errForbidden) {
+		forbidden(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.userRepo.List(filter)
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users"})
 		return
 	}
 
-	var userResponses []UserResponse
-	for _, user := range users {
+	userResponses := make([]UserResponse, 0, len(page.Users))
+	for _, user := range page.Users {
 		userResponses = append(userResponses, h.toUserResponse(&user))
 	}
 
+	c.Header("X-Total-Count", strconv.FormatInt(page.Total, 10))
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
 	c.JSON(http.StatusOK, userResponses)
 }
 
@@ -201,24 +316,29 @@ func (h *AuthHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userRepo.GetByID(id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	principal, ok := principalFrom(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	var updateReq struct {
-		FirstName *string          `json:"first_name"`
-		LastName  *string          `json:"last_name"`
-		Role      *domain.UserRole `json:"role"`
-		IsActive  *bool            `json:"is_active"`
-	}
-
+	var updateReq UserUpdate
 	if err := c.ShouldBindJSON(&updateReq); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := authorizeUserUpdate(principal, id, updateReq); err != nil {
+		forbidden(c, err)
+		return
+	}
+
+	user, err := h.userRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
 	if updateReq.FirstName != nil {
 		user.FirstName = *updateReq.FirstName
 	}
@@ -239,6 +359,13 @@ func (h *AuthHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// Al desactivar una cuenta (p. ej. baja de un empleado) se cierran sus sesiones
+	if updateReq.IsActive != nil && !user.IsActive {
+		if err := h.tokenRepo.RevokeAllForUser(user.ID); err != nil {
+			log.Printf("Error revoking sessions of deactivated user %s: %v", user.ID, err)
+		}
+	}
+
 	c.JSON(http.StatusOK, h.toUserResponse(user))
 }
 
@@ -250,125 +377,152 @@ func (h *AuthHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	principal, ok := principalFrom(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := authorizeUserDelete(principal, id); err != nil {
+		forbidden(c, err)
+		return
+	}
+
+	// Borrado lógico: se puede restaurar desde /api/admin/users/:id/restore
 	if err := h.userRepo.Delete(id); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
+	if err := h.tokenRepo.RevokeAllForUser(id); err != nil {
+		log.Printf("Error revoking sessions of deleted user %s: %v", id, err)
+	}
 
+	h.guard.audit(c, domain.AuditUserDeleted, &principal.UserID, id.String(), nil)
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
+// AuthMiddleware acepta el access token de un usuario o la API key de una cuenta
+// de servicio. Las cuentas de servicio solo pasan las políticas con scopes
+// (Authorize), así que las rutas sin ellas siguen siendo solo para usuarios.
 func (h *AuthHandler) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
-			return
-		}
-
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
-			c.Abort()
-			return
-		}
-
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(h.jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+		if key, ok := apiKeyFromRequest(c); ok {
+			if h.apiKeys.authenticate(c, key) {
+				c.Next()
+				h.apiKeys.auditRequest(c)
+			}
 			return
 		}
-
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
-			return
+		if h.authenticate(c) {
+			c.Next()
 		}
+	}
+}
 
-		userIDStr, ok := claims["user_id"].(string)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
-			c.Abort()
+// UserAuthMiddleware solo acepta access tokens de usuario; se usa en las rutas
+// que operan sobre la cuenta propia (sesión, MFA, perfil)
+func (h *AuthHandler) UserAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := apiKeyFromRequest(c); ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API keys are not accepted on this endpoint"})
 			return
 		}
-
-		userID, err := uuid.Parse(userIDStr)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID format"})
-			c.Abort()
-			return
+		if h.authenticate(c) {
+			c.Next()
 		}
-
-		c.Set("user_id", userID)
-		c.Set("user_email", claims["email"])
-		c.Set("user_role", claims["role"])
-		c.Next()
 	}
 }
 
-func (h *AuthHandler) generateJWT(user *domain.User) (string, time.Time, error) {
-	expiresAt := time.Now().Add(24 * time.Hour)
-
-	claims := jwt.MapClaims{
-		"user_id": user.ID.String(),
-		"email":   user.Email,
-		"role":    user.Role,
-		"exp":     expiresAt.Unix(),
-		"iat":     time.Now().Unix(),
+// authenticate valida el access token del header Authorization y carga la identidad
+// en el contexto; si falla responde 401 y aborta
+func (h *AuthHandler) authenticate(c *gin.Context) bool {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		c.Abort()
+		return false
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(h.jwtSecret))
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
+		c.Abort()
+		return false
+	}
 
-	return tokenString, expiresAt, err
-}
+	claims, err := h.parseToken(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return false
+	}
 
-func (h *AuthHandler) WebSocketAuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Obtener token de los query parameters
-		token := c.Query("token")
-		if token == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token required"})
-			return
-		}
+	userIDStr, ok := claims["user_id"].(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
+		c.Abort()
+		return false
+	}
 
-		// Validar token
-		claims := jwt.MapClaims{}
-		jwtToken, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(h.jwtSecret), nil
-		})
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID format"})
+		c.Abort()
+		return false
+	}
 
-		if err != nil || !jwtToken.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			return
+	// Rechazar tokens revocados por logout o por reutilización de refresh token
+	jti, _ := claims["jti"].(string)
+	if jti != "" {
+		revoked, err := h.tokenRepo.IsAccessTokenRevoked(jti)
+		if err != nil || revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return false
 		}
+	}
 
-		// Extraer información del usuario
-		userID := c.Query("user_id")
-		userRole := c.Query("role")
+	// Token de suplantación emitido por un admin (Impersonate)
+	if _, ok := claims[claimActor]; ok && !h.authenticateImpersonation(c, claims) {
+		return false
+	}
 
-		if userID == "" || userRole == "" {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "User ID and role required"})
-			return
+	if sid, ok := claims["sid"].(string); ok {
+		sessionID, err := uuid.Parse(sid)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session in token"})
+			c.Abort()
+			return false
 		}
+		revoked, err := h.tokenRepo.IsFamilyRevoked(sessionID)
+		if err != nil || revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return false
+		}
+		c.Set("session_id", sessionID)
+		if c.FullPath() != introspectionRoute {
+			h.touchSession(c, sessionID)
+		}
+	}
 
-		// Establecer valores en el contexto
-		c.Set("user_id", userID)
-		c.Set("user_role", userRole)
-		c.Set("token", token)
-
-		c.Next()
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		c.Set("token_expires_at", exp.Time)
 	}
+
+	c.Set("user_id", userID)
+	c.Set("user_email", claims["email"])
+	c.Set("user_role", claims["role"])
+	c.Set("token_jti", jti)
+	return true
 }
 
 func (h *AuthHandler) toUserResponse(user *domain.User) UserResponse {
-	return UserResponse{
+	resp := UserResponse{
 		ID:        user.ID,
 		Email:     user.Email,
 		FirstName: user.FirstName,
@@ -377,5 +531,14 @@ func (h *AuthHandler) toUserResponse(user *domain.User) UserResponse {
 		IsActive:  user.IsActive,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
+
+		EmailVerified: user.EmailVerified,
+		MFAEnabled:    user.MFAEnabled,
+		LockedUntil:   user.LockedUntil,
+	}
+	if user.DeletedAt.Valid {
+		resp.DeletedAt = &user.DeletedAt.Time
 	}
+	resp.ErasedAt = user.ErasedAt
+	return resp
 }
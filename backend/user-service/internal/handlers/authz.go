@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"user-service/internal/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	errForbidden    = errors.New("insufficient permissions")
+	errRoleChange   = errors.New("only admins can change user roles")
+	errStatusChange = errors.New("only admins can activate or deactivate users")
+	errSelfLockout  = errors.New("admins cannot change their own role or status")
+	errSelfDelete   = errors.New("admins cannot delete their own account")
+	errRoleMismatch = errors.New("requested role does not match the authenticated user")
+	errCallAccess   = errors.New("not a participant of this call")
+	errInvalidRole  = errors.New("invalid role")
+	errClinicalData = errors.New("only staff can change the medical record number or date of birth")
+)
+
+// Principal es la identidad autenticada tal como la deja AuthMiddleware en el
+// contexto: un usuario con su rol o una cuenta de servicio (UserID es entonces
+// el ID de la cuenta) con los scopes de su API key
+type Principal struct {
+	UserID uuid.UUID
+	Role   domain.UserRole
+	Type   string
+	Scopes []string
+}
+
+// IsServiceAccount indica si el principal es una cuenta de servicio
+func (p Principal) IsServiceAccount() bool {
+	return p.Type == domain.PrincipalServiceAccount
+}
+
+// HasScope indica si la API key del principal concede alguno de los scopes
+func (p Principal) HasScope(scopes ...string) bool {
+	for _, granted := range p.Scopes {
+		for _, scope := range scopes {
+			if granted == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Is indica si el principal tiene alguno de los roles dados
+func (p Principal) Is(roles ...domain.UserRole) bool {
+	for _, role := range roles {
+		if p.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy describe declarativamente quién puede acceder a una ruta
+type Policy struct {
+	// Roles con acceso a cualquier recurso de la ruta
+	Roles []domain.UserRole
+	// SelfParam es el parámetro de ruta con el ID del usuario dueño del recurso;
+	// si coincide con el del principal se permite el acceso aunque no tenga el rol
+	SelfParam string
+	// Scopes que dan acceso a las cuentas de servicio; sin scopes no pueden entrar
+	Scopes []string
+}
+
+// Allows evalúa la política para el principal y el ID de recurso de la ruta
+func (p Policy) Allows(principal Principal, resourceID string) bool {
+	if principal.IsServiceAccount() {
+		return len(p.Scopes) > 0 && principal.HasScope(p.Scopes...)
+	}
+	if principal.Is(p.Roles...) {
+		return true
+	}
+	if p.SelfParam == "" {
+		return false
+	}
+	id, err := uuid.Parse(resourceID)
+	return err == nil && id == principal.UserID
+}
+
+// Authorize aplica la política a la ruta; debe ir después de AuthMiddleware
+func Authorize(policy Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := principalFrom(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		var resourceID string
+		if policy.SelfParam != "" {
+			resourceID = c.Param(policy.SelfParam)
+		}
+
+		if !policy.Allows(principal, resourceID) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole restringe la ruta a los roles indicados
+func RequireRole(roles ...domain.UserRole) gin.HandlerFunc {
+	return Authorize(Policy{Roles: roles})
+}
+
+// RequireSelfOrRole permite al dueño del recurso (parámetro param) o a los roles indicados
+func RequireSelfOrRole(param string, roles ...domain.UserRole) gin.HandlerFunc {
+	return Authorize(Policy{Roles: roles, SelfParam: param})
+}
+
+// RequireRoleOrScope permite a los roles indicados y a las cuentas de servicio
+// cuya API key tenga el scope
+func RequireRoleOrScope(scope string, roles ...domain.UserRole) gin.HandlerFunc {
+	return Authorize(Policy{Roles: roles, Scopes: []string{scope}})
+}
+
+// principalFrom lee user_id y user_role (o los scopes de la API key) del contexto de gin
+func principalFrom(c *gin.Context) (Principal, bool) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		return Principal{}, false
+	}
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		return Principal{}, false
+	}
+
+	if c.GetString("principal_type") == domain.PrincipalServiceAccount {
+		return Principal{UserID: id, Type: domain.PrincipalServiceAccount, Scopes: c.GetStringSlice("api_key_scopes")}, true
+	}
+
+	var role domain.UserRole
+	value, _ := c.Get("user_role")
+	switch value := value.(type) {
+	case string:
+		role = domain.UserRole(value)
+	case domain.UserRole:
+		role = value
+	}
+	if role == "" {
+		return Principal{}, false
+	}
+
+	return Principal{UserID: id, Role: role, Type: domain.PrincipalUser}, true
+}
+
+// UserUpdate son los campos que una petición intenta modificar de un usuario
+type UserUpdate struct {
+	FirstName *string          `json:"first_name"`
+	LastName  *string          `json:"last_name"`
+	Role      *domain.UserRole `json:"role"`
+	IsActive  *bool            `json:"is_active"`
+}
+
+// authorizeUserUpdate aplica las reglas de propiedad sobre la edición de perfiles:
+// cada usuario edita su nombre, pero solo un admin cambia roles o el estado de la
+// cuenta, y nunca los suyos propios para no quedarse sin administradores
+func authorizeUserUpdate(actor Principal, targetID uuid.UUID, update UserUpdate) error {
+	isSelf := actor.UserID == targetID
+	isAdmin := actor.Is(domain.RoleAdmin)
+
+	if !isSelf && !isAdmin {
+		return errForbidden
+	}
+
+	if update.Role != nil && !validRole(*update.Role) {
+		return errInvalidRole
+	}
+
+	if update.Role != nil || update.IsActive != nil {
+		if !isAdmin {
+			if update.Role != nil {
+				return errRoleChange
+			}
+			return errStatusChange
+		}
+		if isSelf {
+			return errSelfLockout
+		}
+	}
+
+	return nil
+}
+
+// authorizeUserDelete solo permite a un admin borrar cuentas ajenas
+func authorizeUserDelete(actor Principal, targetID uuid.UUID) error {
+	if !actor.Is(domain.RoleAdmin) {
+		return errForbidden
+	}
+	if actor.UserID == targetID {
+		return errSelfDelete
+	}
+	return nil
+}
+
+// PatientUpdate son los campos que una petición intenta modificar de una ficha clínica
+type PatientUpdate struct {
+	MedicalRecordNumber *string                  `json:"medical_record_number"`
+	DateOfBirth         *domain.Date             `json:"date_of_birth"`
+	EmergencyContact    *domain.EmergencyContact `json:"emergency_contact"`
+	InsuranceInfo       *domain.InsuranceInfo    `json:"insurance_info"`
+}
+
+// authorizePatientUpdate deja al personal editar cualquier ficha y al paciente la
+// suya, salvo los datos de identificación clínica (MRN y fecha de nacimiento)
+func authorizePatientUpdate(actor Principal, targetID uuid.UUID, update PatientUpdate) error {
+	if actor.Is(domain.RoleEmployee, domain.RoleAdmin) {
+		return nil
+	}
+	if actor.UserID != targetID {
+		return errForbidden
+	}
+	if update.MedicalRecordNumber != nil || update.DateOfBirth != nil {
+		return errClinicalData
+	}
+	return nil
+}
+
+// canAccessCall permite ver o terminar una llamada a sus participantes, a los
+// admins y a las cuentas de servicio con el scope de la operación
+func canAccessCall(actor Principal, call *domain.Call, scope string) bool {
+	if actor.IsServiceAccount() {
+		return actor.HasScope(scope)
+	}
+	if actor.Is(domain.RoleAdmin) {
+		return true
+	}
+	return call.PatientID == actor.UserID || call.EmployeeID == actor.UserID
+}
+
+// authorizeCallToken decide si se emite un token de LiveKit para la sala de la
+// llamada: el rol pedido debe ser el del usuario, y este un participante de la
+// llamada, un admin o un familiar con vínculo aprobado con el paciente
+func authorizeCallToken(actor Principal, req TokenRequest, call *domain.Call, familyApproved bool) error {
+	// Las integraciones no entran en las salas de vídeo
+	if actor.IsServiceAccount() {
+		return errForbidden
+	}
+	if req.Role != string(actor.Role) {
+		return errRoleMismatch
+	}
+
+	switch actor.Role {
+	case domain.RoleAdmin:
+		return nil
+	case domain.RoleFamily:
+		if familyApproved {
+			return nil
+		}
+	case domain.RolePatient:
+		if call.PatientID == actor.UserID {
+			return nil
+		}
+	case domain.RoleEmployee:
+		if call.EmployeeID == actor.UserID {
+			return nil
+		}
+	}
+	return errCallAccess
+}
+
+func validRole(role domain.UserRole) bool {
+	switch role {
+	case domain.RolePatient, domain.RoleEmployee, domain.RoleFamily, domain.RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// forbidden responde con el motivo de la denegación de una regla de autorización
+func forbidden(c *gin.Context, err error) {
+	status := http.StatusForbidden
+	if errors.Is(err, errInvalidRole) {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
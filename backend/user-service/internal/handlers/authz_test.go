@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"user-service/internal/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestAuthorizeMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	self := uuid.New()
+	other := uuid.New()
+
+	tests := []struct {
+		name   string
+		policy Policy
+		userID *uuid.UUID
+		role   any
+		path   string
+		want   int
+	}{
+		{"sin autenticar", Policy{Roles: []domain.UserRole{domain.RoleAdmin}}, nil, nil, "/users/" + other.String(), http.StatusUnauthorized},
+		{"sin rol en el token", Policy{Roles: []domain.UserRole{domain.RoleAdmin}}, &self, nil, "/users/" + other.String(), http.StatusUnauthorized},
+		{"rol permitido", Policy{Roles: []domain.UserRole{domain.RoleAdmin}}, &self, "admin", "/users/" + other.String(), http.StatusOK},
+		{"rol tipado permitido", Policy{Roles: []domain.UserRole{domain.RoleAdmin}}, &self, domain.RoleAdmin, "/users/" + other.String(), http.StatusOK},
+		{"rol no permitido", Policy{Roles: []domain.UserRole{domain.RoleAdmin}}, &self, "patient", "/users/" + other.String(), http.StatusForbidden},
+		{"dueño del recurso", Policy{Roles: []domain.UserRole{domain.RoleAdmin}, SelfParam: "id"}, &self, "patient", "/users/" + self.String(), http.StatusOK},
+		{"recurso ajeno", Policy{Roles: []domain.UserRole{domain.RoleAdmin}, SelfParam: "id"}, &self, "patient", "/users/" + other.String(), http.StatusForbidden},
+		{"id inválido", Policy{SelfParam: "id"}, &self, "patient", "/users/not-a-uuid", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if tt.userID != nil {
+					c.Set("user_id", *tt.userID)
+				}
+				if tt.role != nil {
+					c.Set("user_role", tt.role)
+				}
+			})
+			r.GET("/users/:id", Authorize(tt.policy), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeUserUpdate(t *testing.T) {
+	admin := Principal{UserID: uuid.New(), Role: domain.RoleAdmin}
+	patient := Principal{UserID: uuid.New(), Role: domain.RolePatient}
+	employee := Principal{UserID: uuid.New(), Role: domain.RoleEmployee}
+
+	name := "Ana"
+	active := false
+	roleAdmin := domain.RoleAdmin
+	roleInvalid := domain.UserRole("superuser")
+
+	tests := []struct {
+		name   string
+		actor  Principal
+		target uuid.UUID
+		update UserUpdate
+		want   error
+	}{
+		{"edita su nombre", patient, patient.UserID, UserUpdate{FirstName: &name}, nil},
+		{"edita nombre ajeno", patient, employee.UserID, UserUpdate{FirstName: &name}, errForbidden},
+		{"empleado edita a otro", employee, patient.UserID, UserUpdate{LastName: &name}, errForbidden},
+		{"se cambia el rol", patient, patient.UserID, UserUpdate{Role: &roleAdmin}, errRoleChange},
+		{"se desactiva", patient, patient.UserID, UserUpdate{IsActive: &active}, errStatusChange},
+		{"rol inválido", admin, patient.UserID, UserUpdate{Role: &roleInvalid}, errInvalidRole},
+		{"admin cambia rol ajeno", admin, patient.UserID, UserUpdate{Role: &roleAdmin}, nil},
+		{"admin desactiva a otro", admin, employee.UserID, UserUpdate{IsActive: &active}, nil},
+		{"admin edita su nombre", admin, admin.UserID, UserUpdate{FirstName: &name}, nil},
+		{"admin se desactiva", admin, admin.UserID, UserUpdate{IsActive: &active}, errSelfLockout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authorizeUserUpdate(tt.actor, tt.target, tt.update)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("authorizeUserUpdate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeUserDelete(t *testing.T) {
+	admin := Principal{UserID: uuid.New(), Role: domain.RoleAdmin}
+	employee := Principal{UserID: uuid.New(), Role: domain.RoleEmployee}
+
+	tests := []struct {
+		name   string
+		actor  Principal
+		target uuid.UUID
+		want   error
+	}{
+		{"admin borra a otro", admin, employee.UserID, nil},
+		{"admin se borra", admin, admin.UserID, errSelfDelete},
+		{"empleado borra a otro", employee, admin.UserID, errForbidden},
+		{"empleado se borra", employee, employee.UserID, errForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := authorizeUserDelete(tt.actor, tt.target); !errors.Is(err, tt.want) {
+				t.Errorf("authorizeUserDelete() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizePatientUpdate(t *testing.T) {
+	patient := Principal{UserID: uuid.New(), Role: domain.RolePatient}
+	employee := Principal{UserID: uuid.New(), Role: domain.RoleEmployee}
+	family := Principal{UserID: uuid.New(), Role: domain.RoleFamily}
+
+	mrn := "MRN-0001"
+	birth, _ := domain.ParseDate("1950-04-12")
+	contact := &domain.EmergencyContact{Name: "Luis", Phone: "600000000"}
+
+	tests := []struct {
+		name   string
+		actor  Principal
+		target uuid.UUID
+		update PatientUpdate
+		want   error
+	}{
+		{"paciente edita su contacto", patient, patient.UserID, PatientUpdate{EmergencyContact: contact}, nil},
+		{"paciente cambia su MRN", patient, patient.UserID, PatientUpdate{MedicalRecordNumber: &mrn}, errClinicalData},
+		{"paciente cambia su fecha de nacimiento", patient, patient.UserID, PatientUpdate{DateOfBirth: &birth}, errClinicalData},
+		{"familiar edita la ficha", family, patient.UserID, PatientUpdate{EmergencyContact: contact}, errForbidden},
+		{"empleado cambia el MRN", employee, patient.UserID, PatientUpdate{MedicalRecordNumber: &mrn}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := authorizePatientUpdate(tt.actor, tt.target, tt.update); !errors.Is(err, tt.want) {
+				t.Errorf("authorizePatientUpdate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeServiceAccount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	account := uuid.New()
+
+	tests := []struct {
+		name   string
+		policy Policy
+		scopes []string
+		want   int
+	}{
+		{"scope concedido", Policy{Roles: []domain.UserRole{domain.RoleEmployee}, Scopes: []string{domain.ScopeQueueRead}}, []string{domain.ScopeQueueRead}, http.StatusOK},
+		{"scope distinto", Policy{Roles: []domain.UserRole{domain.RoleEmployee}, Scopes: []string{domain.ScopeQueueRead}}, []string{domain.ScopeCallsWrite}, http.StatusForbidden},
+		{"ruta sin scopes", Policy{Roles: []domain.UserRole{domain.RoleEmployee}}, []string{domain.ScopeQueueRead}, http.StatusForbidden},
+		{"id propio no aplica", Policy{SelfParam: "id"}, []string{domain.ScopeUsersRead}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				c.Set("user_id", account)
+				c.Set("principal_type", domain.PrincipalServiceAccount)
+				c.Set("api_key_scopes", tt.scopes)
+			})
+			r.GET("/users/:id", Authorize(tt.policy), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/"+account.String(), nil))
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanAccessCall(t *testing.T) {
+	call := &domain.Call{PatientID: uuid.New(), EmployeeID: uuid.New()}
+
+	tests := []struct {
+		name  string
+		actor Principal
+		want  bool
+	}{
+		{"paciente de la llamada", Principal{UserID: call.PatientID, Role: domain.RolePatient}, true},
+		{"empleado de la llamada", Principal{UserID: call.EmployeeID, Role: domain.RoleEmployee}, true},
+		{"admin", Principal{UserID: uuid.New(), Role: domain.RoleAdmin}, true},
+		{"otro paciente", Principal{UserID: uuid.New(), Role: domain.RolePatient}, false},
+		{"otro empleado", Principal{UserID: uuid.New(), Role: domain.RoleEmployee}, false},
+		{"integración con scope", Principal{UserID: uuid.New(), Type: domain.PrincipalServiceAccount, Scopes: []string{domain.ScopeCallsWrite}}, true},
+		{"integración sin scope", Principal{UserID: uuid.New(), Type: domain.PrincipalServiceAccount, Scopes: []string{domain.ScopeCallsRead}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canAccessCall(tt.actor, call, domain.ScopeCallsWrite); got != tt.want {
+				t.Errorf("canAccessCall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCallToken(t *testing.T) {
+	call := &domain.Call{PatientID: uuid.New(), EmployeeID: uuid.New()}
+	patient := Principal{UserID: call.PatientID, Role: domain.RolePatient}
+	employee := Principal{UserID: call.EmployeeID, Role: domain.RoleEmployee}
+	family := Principal{UserID: uuid.New(), Role: domain.RoleFamily}
+
+	tests := []struct {
+		name     string
+		actor    Principal
+		req      TokenRequest
+		approved bool
+		want     error
+	}{
+		{"paciente de la llamada", patient, TokenRequest{Role: "patient"}, false, nil},
+		{"empleado de la llamada", employee, TokenRequest{Role: "employee"}, false, nil},
+		{"admin", Principal{UserID: uuid.New(), Role: domain.RoleAdmin}, TokenRequest{Role: "admin"}, false, nil},
+		{"familiar con vínculo aprobado", family, TokenRequest{Role: "family"}, true, nil},
+		{"se hace pasar por empleado", patient, TokenRequest{Role: "employee"}, false, errRoleMismatch},
+		{"otro paciente", Principal{UserID: uuid.New(), Role: domain.RolePatient}, TokenRequest{Role: "patient"}, false, errCallAccess},
+		{"otro empleado", Principal{UserID: uuid.New(), Role: domain.RoleEmployee}, TokenRequest{Role: "employee"}, false, errCallAccess},
+		{"familiar sin vínculo aprobado", family, TokenRequest{Role: "family"}, false, errCallAccess},
+		{"integración", Principal{UserID: uuid.New(), Type: domain.PrincipalServiceAccount, Scopes: []string{domain.ScopeCallsWrite}}, TokenRequest{Role: ""}, false, errForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := authorizeCallToken(tt.actor, tt.req, call, tt.approved); !errors.Is(err, tt.want) {
+				t.Errorf("authorizeCallToken() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
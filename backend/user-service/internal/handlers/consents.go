@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// defaultConsentTermsVersion es la versión del documento de consentimiento si no
+// se define CONSENT_TERMS_VERSION
+const defaultConsentTermsVersion = "1"
+
+type ConsentHandler struct {
+	consentRepo repository.ConsentRepository
+	userRepo    repository.UserRepository
+	version     string
+	documentURL string
+}
+
+// ConsentRequest concede o retira consentimientos; los campos omitidos no
+// cambian. Conceder exige indicar la versión del documento que se ha leído.
+type ConsentRequest struct {
+	Version    string `json:"version"`
+	Terms      *bool  `json:"terms"`
+	Recording  *bool  `json:"recording"`
+	Livestream *bool  `json:"livestream"`
+}
+
+// ConsentStatus es el estado de consentimiento del usuario frente a la versión
+// vigente del documento
+type ConsentStatus struct {
+	TermsVersion      string     `json:"terms_version"`
+	DocumentURL       string     `json:"document_url,omitempty"`
+	AcceptedVersion   string     `json:"accepted_version,omitempty"`
+	AcceptedAt        *time.Time `json:"accepted_at,omitempty"`
+	ConsentRequired   bool       `json:"consent_required"`
+	RecordingConsent  bool       `json:"recording_consent"`
+	LivestreamConsent bool       `json:"livestream_consent"`
+}
+
+func NewConsentHandler(consentRepo repository.ConsentRepository, userRepo repository.UserRepository) *ConsentHandler {
+	version := os.Getenv("CONSENT_TERMS_VERSION")
+	if version == "" {
+		version = defaultConsentTermsVersion
+	}
+
+	return &ConsentHandler{
+		consentRepo: consentRepo,
+		userRepo:    userRepo,
+		version:     version,
+		documentURL: os.Getenv("CONSENT_TERMS_URL"),
+	}
+}
+
+// GetConsents - Estado de consentimiento del usuario autenticado
+func (h *ConsentHandler) GetConsents(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.status(user))
+}
+
+// UpdateConsents - Concede o retira consentimientos. Cada cambio queda como un
+// registro inmutable con la versión del documento, la IP y el user agent.
+func (h *ConsentHandler) UpdateConsents(c *gin.Context) {
+	var req ConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Terms == nil && req.Recording == nil && req.Livestream == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide at least one of terms, recording or livestream"})
+		return
+	}
+	// Los términos se retiran retirando grabación y livestream, no aceptándolos a medias
+	if req.Terms != nil && !*req.Terms {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Terms cannot be withdrawn, withdraw recording or livestream instead"})
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	granting := req.Terms != nil || isTrue(req.Recording) || isTrue(req.Livestream)
+	if granting && req.Version != h.version {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "Consent document version is outdated",
+			"terms_version": h.version,
+		})
+		return
+	}
+	// Grabación y livestream solo se conceden sobre los términos vigentes
+	if (isTrue(req.Recording) || isTrue(req.Livestream)) && req.Terms == nil && user.ConsentVersion != h.version {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":            "Current consent terms must be accepted first",
+			"terms_version":    h.version,
+			"consent_required": true,
+		})
+		return
+	}
+
+	now := time.Now()
+	if err := h.record(c, userID, req.Terms, req.Recording, req.Livestream, now); err != nil {
+		log.Printf("Error recording consent of user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record consent"})
+		return
+	}
+
+	user, err = h.userRepo.GetByID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get consent status"})
+		return
+	}
+	c.JSON(http.StatusOK, h.status(user))
+}
+
+// GetConsentHistory - Historial de concesiones y retiradas de un usuario (el
+// propio usuario o personal)
+func (h *ConsentHandler) GetConsentHistory(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	records, err := h.consentRepo.ListForUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get consent history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, records)
+}
+
+// RequireCurrentTerms corta las peticiones de pacientes que no han aceptado la
+// versión vigente del documento de consentimiento
+func (h *ConsentHandler) RequireCurrentTerms() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("user_id").(uuid.UUID)
+
+		user, err := h.userRepo.GetByID(userID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+		if user.ConsentVersion != h.version {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":            "Current consent terms must be accepted",
+				"terms_version":    h.version,
+				"consent_required": true,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ExpireOutdatedConsents retira los permisos concedidos con versiones anteriores
+// del documento; se ejecuta al arrancar, cuando puede haber cambiado la versión
+func (h *ConsentHandler) ExpireOutdatedConsents() {
+	expired, err := h.consentRepo.ExpireOutdated(h.version, time.Now())
+	if err != nil {
+		log.Printf("Error expiring outdated consents: %v", err)
+		return
+	}
+	if expired > 0 {
+		log.Printf("Expired consents of %d users for terms version %s", expired, h.version)
+	}
+}
+
+// TermsResponse es la versión vigente del documento que debe aceptar un alta
+type TermsResponse struct {
+	TermsVersion string `json:"terms_version"`
+	DocumentURL  string `json:"document_url,omitempty"`
+}
+
+// GetTerms - Versión vigente del documento de consentimiento (pública: la
+// necesitan los formularios de alta)
+func (h *ConsentHandler) GetTerms(c *gin.Context) {
+	c.JSON(http.StatusOK, TermsResponse{TermsVersion: h.version, DocumentURL: h.documentURL})
+}
+
+// requireSignupTerms corta un alta que no acepta expresamente la versión
+// vigente del documento; responde y devuelve false en ese caso
+func (h *ConsentHandler) requireSignupTerms(c *gin.Context, accepted bool, version string) bool {
+	if !accepted {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":            "Consent terms must be accepted to create an account",
+			"terms_version":    h.version,
+			"consent_required": true,
+		})
+		return false
+	}
+	if version != h.version {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "Consent document version is outdated",
+			"terms_version": h.version,
+		})
+		return false
+	}
+	return true
+}
+
+// recordRegistration guarda lo aceptado en un alta: los términos, que
+// requireSignupTerms ya comprobó, y grabación y livestream solo si se concedieron
+func (h *ConsentHandler) recordRegistration(c *gin.Context, userID uuid.UUID, recording, livestream bool) error {
+	accepted := true
+	var recordingGranted, livestreamGranted *bool
+	if recording {
+		recordingGranted = &recording
+	}
+	if livestream {
+		livestreamGranted = &livestream
+	}
+	return h.record(c, userID, &accepted, recordingGranted, livestreamGranted, time.Now())
+}
+
+// record convierte cada campo indicado en un registro y actualiza las columnas
+// de users en consecuencia
+func (h *ConsentHandler) record(c *gin.Context, userID uuid.UUID, terms, recording, livestream *bool, at time.Time) error {
+	var records []domain.ConsentRecord
+	flags := map[string]interface{}{"updated_at": at}
+
+	add := func(consentType string, granted bool) {
+		action := domain.ConsentWithdrawn
+		if granted {
+			action = domain.ConsentGranted
+		}
+		records = append(records, domain.ConsentRecord{
+			ID:              uuid.New(),
+			UserID:          userID,
+			ConsentType:     consentType,
+			Action:          action,
+			DocumentVersion: h.version,
+			IPAddress:       c.ClientIP(),
+			UserAgent:       c.Request.UserAgent(),
+			CreatedAt:       at,
+		})
+	}
+
+	if terms != nil {
+		add(domain.ConsentTerms, true)
+		flags["consent_version"] = h.version
+		flags["consent_accepted_at"] = at
+	}
+	if recording != nil {
+		add(domain.ConsentRecording, *recording)
+		flags["recording_consent"] = *recording
+	}
+	if livestream != nil {
+		add(domain.ConsentLivestream, *livestream)
+		flags["livestream_consent"] = *livestream
+	}
+
+	return h.consentRepo.Record(userID, records, flags)
+}
+
+func (h *ConsentHandler) status(user *domain.User) ConsentStatus {
+	return ConsentStatus{
+		TermsVersion:      h.version,
+		DocumentURL:       h.documentURL,
+		AcceptedVersion:   user.ConsentVersion,
+		AcceptedAt:        user.ConsentAcceptedAt,
+		ConsentRequired:   user.ConsentVersion != h.version,
+		RecordingConsent:  user.RecordingConsent,
+		LivestreamConsent: user.LivestreamConsent,
+	}
+}
+
+func isTrue(value *bool) bool {
+	return value != nil && *value
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRegisterRequiresTerms(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"sin aceptar los términos",
+			`{"email": "ana@clinica.local", "password": "secreto123", "first_name": "Ana", "last_name": "García", "role": "patient", "recording_consent": true}`,
+			http.StatusBadRequest},
+		{"términos rechazados",
+			`{"email": "ana@clinica.local", "password": "secreto123", "first_name": "Ana", "last_name": "García", "role": "family", "accept_terms": false, "terms_version": "2024-01"}`,
+			http.StatusBadRequest},
+		{"versión distinta de la vigente",
+			`{"email": "ana@clinica.local", "password": "secreto123", "first_name": "Ana", "last_name": "García", "role": "patient", "accept_terms": true, "terms_version": "2023-01"}`,
+			http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeLookupUserRepo{}
+			h := &AuthHandler{userRepo: repo, consents: &ConsentHandler{version: "2024-01"}}
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			h.Register(c)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+			// La comprobación va antes de tocar la base de datos
+			if len(repo.lookups) != 0 {
+				t.Errorf("looked up %v before checking the terms", repo.lookups)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	familyInviteTTL = 72 * time.Hour
+	// Sin caracteres ambiguos (0/O, 1/I/L) para poder dictar el código por teléfono
+	inviteCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	inviteCodeLength   = 10
+)
+
+type FamilyHandler struct {
+	familyRepo    repository.FamilyRepository
+	userRepo      repository.UserRepository
+	userTokenRepo repository.UserTokenRepository
+	auditRepo     repository.AuditRepository
+}
+
+// FamilyLinkRequest identifica al paciente por su email o por un código de
+// invitación que él mismo generó
+type FamilyLinkRequest struct {
+	PatientEmail     string `json:"patient_email"`
+	InviteCode       string `json:"invite_code"`
+	RelationshipType string `json:"relationship_type" binding:"required"`
+}
+
+// FamilyLinkResponse es un vínculo con los nombres de ambas partes
+type FamilyLinkResponse struct {
+	ID                uuid.UUID  `json:"id"`
+	PatientID         uuid.UUID  `json:"patient_id"`
+	PatientName       string     `json:"patient_name"`
+	FamilyMemberID    uuid.UUID  `json:"family_member_id"`
+	FamilyMemberName  string     `json:"family_member_name"`
+	FamilyMemberEmail string     `json:"family_member_email"`
+	RelationshipType  string     `json:"relationship_type"`
+	Status            string     `json:"status"`
+	RequestedAt       time.Time  `json:"requested_at"`
+	RespondedAt       *time.Time `json:"responded_at,omitempty"`
+}
+
+func NewFamilyHandler(familyRepo repository.FamilyRepository, userRepo repository.UserRepository, userTokenRepo repository.UserTokenRepository, auditRepo repository.AuditRepository) *FamilyHandler {
+	return &FamilyHandler{
+		familyRepo:    familyRepo,
+		userRepo:      userRepo,
+		userTokenRepo: userTokenRepo,
+		auditRepo:     auditRepo,
+	}
+}
+
+// CreateInviteCode - El paciente genera un código de un solo uso para que un
+// familiar solicite el vínculo; generar uno nuevo invalida el anterior
+func (h *FamilyHandler) CreateInviteCode(c *gin.Context) {
+	patientID := c.MustGet("user_id").(uuid.UUID)
+
+	code, err := generateInviteCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invite code"})
+		return
+	}
+
+	now := time.Now()
+	token := &domain.UserToken{
+		ID:        uuid.New(),
+		UserID:    patientID,
+		Purpose:   domain.PurposeFamilyInvite,
+		TokenHash: hashToken(normalizeInviteCode(code)),
+		ExpiresAt: now.Add(familyInviteTTL),
+		CreatedAt: now,
+	}
+	if err := h.userTokenRepo.Create(token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invite code"})
+		return
+	}
+
+	h.audit(c, domain.AuditFamilyInviteCreated, patientID, "", gin.H{"expires_at": token.ExpiresAt})
+	c.JSON(http.StatusCreated, gin.H{"invite_code": code, "expires_at": token.ExpiresAt})
+}
+
+// RequestLink - El familiar solicita el vínculo con un paciente, que debe aprobarlo
+func (h *FamilyHandler) RequestLink(c *gin.Context) {
+	var req FamilyLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !domain.ValidRelationshipType(req.RelationshipType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid relationship_type, expected one of " + strings.Join(domain.RelationshipTypes, ", ")})
+		return
+	}
+	email := domain.NormalizeEmail(req.PatientEmail)
+	code := normalizeInviteCode(req.InviteCode)
+	if (email == "") == (code == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either patient_email or invite_code"})
+		return
+	}
+
+	memberID := c.MustGet("user_id").(uuid.UUID)
+
+	var patientID uuid.UUID
+	via := "invite_code"
+	if code != "" {
+		token, err := h.userTokenRepo.Consume(hashToken(code), domain.PurposeFamilyInvite)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invite code"})
+			return
+		}
+		patientID = token.UserID
+	} else {
+		via = "email"
+		// Misma respuesta exista o no el paciente, para no revelar quién es
+		// paciente de la clínica
+		patient, err := h.userRepo.GetByEmail(email)
+		if err != nil || patient.Role != domain.RolePatient || !patient.IsActive {
+			c.JSON(http.StatusAccepted, gin.H{"message": "If the patient exists, they will be asked to approve the link"})
+			return
+		}
+		patientID = patient.ID
+	}
+
+	now := time.Now()
+	link := &domain.FamilyRelationship{
+		ID:               uuid.New(),
+		PatientID:        patientID,
+		FamilyMemberID:   memberID,
+		RelationshipType: req.RelationshipType,
+		Status:           domain.FamilyLinkPending,
+		RequestedAt:      now,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if err := h.familyRepo.RequestLink(link); err != nil {
+		if errors.Is(err, repository.ErrFamilyLinkExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A link with this patient already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request link"})
+		return
+	}
+
+	h.audit(c, domain.AuditFamilyLinkRequested, memberID, link.ID.String(), gin.H{
+		"patient_id":        patientID,
+		"relationship_type": link.RelationshipType,
+		"via":               via,
+	})
+
+	if via == "email" {
+		c.JSON(http.StatusAccepted, gin.H{"message": "If the patient exists, they will be asked to approve the link"})
+		return
+	}
+	h.respondLink(c, http.StatusCreated, link.ID)
+}
+
+// ListLinks - Vínculos del usuario: los de sus familiares si es paciente o los
+// suyos con pacientes si es familiar
+func (h *FamilyHandler) ListLinks(c *gin.Context) {
+	principal, ok := principalFrom(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var links []domain.FamilyRelationship
+	var err error
+	if principal.Is(domain.RolePatient) {
+		links, err = h.familyRepo.ListForPatient(principal.UserID)
+	} else {
+		links, err = h.familyRepo.ListForFamilyMember(principal.UserID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get family links"})
+		return
+	}
+
+	response := make([]FamilyLinkResponse, 0, len(links))
+	for i := range links {
+		response = append(response, toFamilyLinkResponse(&links[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ApproveLink - El paciente aprueba una solicitud pendiente
+func (h *FamilyHandler) ApproveLink(c *gin.Context) {
+	h.respond(c, domain.FamilyLinkApproved, domain.AuditFamilyLinkApproved)
+}
+
+// RejectLink - El paciente rechaza una solicitud pendiente
+func (h *FamilyHandler) RejectLink(c *gin.Context) {
+	h.respond(c, domain.FamilyLinkRejected, domain.AuditFamilyLinkRejected)
+}
+
+// RevokeLink - El paciente o el familiar retiran un vínculo (o una solicitud pendiente)
+func (h *FamilyHandler) RevokeLink(c *gin.Context) {
+	linkID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link ID"})
+		return
+	}
+
+	actorID := c.MustGet("user_id").(uuid.UUID)
+	if err := h.familyRepo.Revoke(linkID, actorID, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrFamilyLinkNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Family link not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke link"})
+		return
+	}
+
+	h.audit(c, domain.AuditFamilyLinkRevoked, actorID, linkID.String(), nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Family link revoked successfully"})
+}
+
+func (h *FamilyHandler) respond(c *gin.Context, status, action string) {
+	linkID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link ID"})
+		return
+	}
+
+	patientID := c.MustGet("user_id").(uuid.UUID)
+	if err := h.familyRepo.Respond(linkID, patientID, status, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrFamilyLinkNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pending link request not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update link"})
+		return
+	}
+
+	log.Printf("Family link %s %s by patient %s", linkID, status, patientID)
+	h.audit(c, action, patientID, linkID.String(), nil)
+	h.respondLink(c, http.StatusOK, linkID)
+}
+
+func (h *FamilyHandler) respondLink(c *gin.Context, status int, linkID uuid.UUID) {
+	link, err := h.familyRepo.GetByID(linkID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get family link"})
+		return
+	}
+	c.JSON(status, toFamilyLinkResponse(link))
+}
+
+func (h *FamilyHandler) audit(c *gin.Context, action string, actorID uuid.UUID, linkID string, details gin.H) {
+	resourceType := ""
+	if linkID != "" {
+		resourceType = "family_relationship"
+	}
+	writeAudit(h.auditRepo, c, action, &actorID, resourceType, linkID, details)
+}
+
+func toFamilyLinkResponse(link *domain.FamilyRelationship) FamilyLinkResponse {
+	resp := FamilyLinkResponse{
+		ID:               link.ID,
+		PatientID:        link.PatientID,
+		FamilyMemberID:   link.FamilyMemberID,
+		RelationshipType: link.RelationshipType,
+		Status:           link.Status,
+		RequestedAt:      link.RequestedAt,
+		RespondedAt:      link.RespondedAt,
+	}
+	if link.Patient != nil {
+		resp.PatientName = strings.TrimSpace(link.Patient.FirstName + " " + link.Patient.LastName)
+	}
+	if link.FamilyMember != nil {
+		resp.FamilyMemberName = strings.TrimSpace(link.FamilyMember.FirstName + " " + link.FamilyMember.LastName)
+		resp.FamilyMemberEmail = link.FamilyMember.Email
+	}
+	return resp
+}
+
+// generateInviteCode devuelve un código del tipo ABCDE-FGH23
+func generateInviteCode() (string, error) {
+	buf := make([]byte, inviteCodeLength)
+	max := big.NewInt(int64(len(inviteCodeAlphabet)))
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = inviteCodeAlphabet[n.Int64()]
+	}
+	return string(buf[:inviteCodeLength/2]) + "-" + string(buf[inviteCodeLength/2:]), nil
+}
+
+// normalizeInviteCode admite el código en minúsculas, con o sin guion y espacios
+func normalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
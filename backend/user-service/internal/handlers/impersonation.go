@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"user-service/internal/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// defaultImpersonationTTL es la vida del token de suplantación; no tiene refresh
+const defaultImpersonationTTL = 15 * time.Minute
+
+// Claims del token de suplantación: act identifica al admin que actúa (RFC 8693)
+// y imp_mode indica si puede escribir. El resto de claims son los del usuario
+// suplantado, así que los demás servicios lo tratan como a ese usuario.
+const (
+	claimActor             = "act"
+	claimImpersonationMode = "imp_mode"
+
+	impersonationReadOnly  = "read"
+	impersonationReadWrite = "write"
+)
+
+var (
+	errImpersonationReadOnly = errors.New("Impersonation token is read-only")
+	errImpersonationDenied   = errors.New("Not available while impersonating a user")
+)
+
+// Rutas que un token de suplantación no puede usar ni con escritura: la
+// seguridad de la cuenta y los consentimientos los gestiona solo el titular, y
+// el admin no entra en la videollamada del usuario
+var impersonationDeniedRoutes = map[string]bool{
+	"POST /api/calls/token":             true,
+	"POST /api/auth/mfa/enroll":         true,
+	"POST /api/auth/mfa/enroll/confirm": true,
+	"POST /api/auth/mfa/disable":        true,
+	"POST /api/auth/mfa/recovery-codes": true,
+	"DELETE /api/auth/sessions":         true,
+	"DELETE /api/auth/sessions/:id":     true,
+	"POST /api/auth/email/verification": true,
+	"POST /api/consents":                true,
+}
+
+// Escrituras que se permiten en modo lectura: terminar la suplantación y abrir
+// el WebSocket para ver la cola en tiempo real como el usuario
+var impersonationReadOnlyExempt = map[string]bool{
+	"POST /api/auth/logout":    true,
+	"POST /api/auth/ws-ticket": true,
+}
+
+// ImpersonateRequest abre una suplantación; el motivo queda en la auditoría
+type ImpersonateRequest struct {
+	Reason     string `json:"reason" binding:"required"`
+	AllowWrite bool   `json:"allow_write"`
+}
+
+// ImpersonationResponse es el token del usuario suplantado (sin refresh token)
+type ImpersonationResponse struct {
+	AccessToken string       `json:"access_token"`
+	TokenType   string       `json:"token_type"`
+	ExpiresAt   time.Time    `json:"expires_at"`
+	ReadOnly    bool         `json:"read_only"`
+	User        UserResponse `json:"user"`
+}
+
+// Impersonate - Emite un token de corta duración para ver la aplicación como
+// otro usuario (solo admin). Es de solo lectura salvo allow_write y cada
+// petición hecha con él se atribuye al admin en audit_logs.
+func (h *AuthHandler) Impersonate(c *gin.Context) {
+	targetID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	if _, impersonating := c.Get("impersonator_id"); impersonating || targetID == adminID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot impersonate this user"})
+		return
+	}
+
+	admin, err := h.userRepo.GetByID(adminID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	target, err := h.userRepo.GetByID(targetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if target.Role == domain.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Administrators cannot be impersonated"})
+		return
+	}
+	if !target.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User is inactive"})
+		return
+	}
+
+	mode := impersonationReadOnly
+	if req.AllowWrite {
+		mode = impersonationReadWrite
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(h.impersonationTTL)
+	jti := uuid.New().String()
+	token, err := h.signToken(jwt.MapClaims{
+		"user_id":              target.ID.String(),
+		"email":                target.Email,
+		"role":                 target.Role,
+		claimActor:             map[string]interface{}{"sub": admin.ID.String(), "email": admin.Email},
+		claimImpersonationMode: mode,
+		"jti":                  jti,
+		"exp":                  expiresAt.Unix(),
+		"iat":                  now.Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	log.Printf("Admin %s started impersonating user %s (%s)", adminID, targetID, mode)
+	h.guard.audit(c, domain.AuditImpersonationStarted, &adminID, targetID.String(), gin.H{
+		"reason":     req.Reason,
+		"mode":       mode,
+		"jti":        jti,
+		"expires_at": expiresAt,
+	})
+
+	c.JSON(http.StatusCreated, ImpersonationResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresAt:   expiresAt,
+		ReadOnly:    mode == impersonationReadOnly,
+		User:        h.toUserResponse(target),
+	})
+}
+
+// authenticateImpersonation valida el claim act de un token de suplantación: el
+// admin que lo emitió debe seguir activo y la ruta debe estar permitida. Carga
+// impersonator_id en el contexto para atribuirle la auditoría.
+func (h *AuthHandler) authenticateImpersonation(c *gin.Context, claims jwt.MapClaims) bool {
+	act, _ := claims[claimActor].(map[string]interface{})
+	actorStr, _ := act["sub"].(string)
+	actorID, err := uuid.Parse(actorStr)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid impersonation token"})
+		c.Abort()
+		return false
+	}
+
+	actor, err := h.userRepo.GetByID(actorID)
+	if err != nil || actor.Role != domain.RoleAdmin || !actor.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Impersonation is no longer valid"})
+		c.Abort()
+		return false
+	}
+
+	readOnly := claims[claimImpersonationMode] != impersonationReadWrite
+	if err := impersonationAllowed(c.Request.Method, c.FullPath(), readOnly); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		c.Abort()
+		return false
+	}
+
+	c.Set("impersonator_id", actorID)
+	return true
+}
+
+// impersonationAllowed decide si un token de suplantación puede usar la ruta
+func impersonationAllowed(method, route string, readOnly bool) error {
+	key := method + " " + route
+	if impersonationDeniedRoutes[key] {
+		return errImpersonationDenied
+	}
+	if !readOnly || impersonationReadOnlyExempt[key] {
+		return nil
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	default:
+		return errImpersonationReadOnly
+	}
+}
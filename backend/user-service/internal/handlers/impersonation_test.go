@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestImpersonationAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		route    string
+		readOnly bool
+		want     error
+	}{
+		{"lectura de la cola", http.MethodGet, "/api/queue/", true, nil},
+		{"unirse a la cola en solo lectura", http.MethodPost, "/api/queue/join", true, errImpersonationReadOnly},
+		{"unirse a la cola con escritura", http.MethodPost, "/api/queue/join", false, nil},
+		{"cerrar la suplantación", http.MethodPost, "/api/auth/logout", true, nil},
+		{"ticket de WebSocket", http.MethodPost, "/api/auth/ws-ticket", true, nil},
+		{"desactivar MFA con escritura", http.MethodPost, "/api/auth/mfa/disable", false, errImpersonationDenied},
+		{"cambiar consentimientos con escritura", http.MethodPost, "/api/consents", false, errImpersonationDenied},
+		{"ver consentimientos", http.MethodGet, "/api/consents", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := impersonationAllowed(tt.method, tt.route, tt.readOnly); !errors.Is(err, tt.want) {
+				t.Errorf("impersonationAllowed() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
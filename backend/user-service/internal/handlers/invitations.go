@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/mail"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultInvitationTTL = 72 * time.Hour
+	inviteTokenType      = "invite"
+)
+
+var (
+	errInvalidInvitation = errors.New("invalid invitation token")
+	errUserExists        = errors.New("User already exists")
+	errEmailDeleted      = errors.New("A deleted user has this email; restore it instead")
+)
+
+type InvitationHandler struct {
+	invitationRepo repository.InvitationRepository
+	userRepo       repository.UserRepository
+	auth           *AuthHandler
+	mailer         mail.EmailSender
+	ttl            time.Duration
+	acceptURL      string
+}
+
+type CreateInvitationRequest struct {
+	Email string          `json:"email" binding:"required,email"`
+	Role  domain.UserRole `json:"role" binding:"required,oneof=employee admin"`
+}
+
+type AcceptInvitationRequest struct {
+	Token     string `json:"token" binding:"required"`
+	Password  string `json:"password" binding:"required,min=6"`
+	FirstName string `json:"first_name" binding:"required"`
+	LastName  string `json:"last_name" binding:"required"`
+
+	// Aceptación expresa del documento de consentimiento vigente
+	AcceptTerms  bool   `json:"accept_terms"`
+	TermsVersion string `json:"terms_version"`
+}
+
+type InvitationResponse struct {
+	domain.Invitation
+	Status    domain.InvitationStatus `json:"status"`
+	Token     string                  `json:"token,omitempty"`
+	AcceptURL string                  `json:"accept_url,omitempty"`
+	EmailSent *bool                   `json:"email_sent,omitempty"`
+}
+
+func NewInvitationHandler(invitationRepo repository.InvitationRepository, userRepo repository.UserRepository, auth *AuthHandler, mailer mail.EmailSender) *InvitationHandler {
+	return &InvitationHandler{
+		invitationRepo: invitationRepo,
+		userRepo:       userRepo,
+		auth:           auth,
+		mailer:         mailer,
+		ttl:            durationFromEnv("INVITATION_TTL", defaultInvitationTTL),
+		acceptURL:      frontendURL() + "/accept-invitation",
+	}
+}
+
+// CreateInvitation - Un admin invita a una cuenta de personal
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	email := domain.NormalizeEmail(req.Email)
+
+	if err := h.emailAvailable(email); err != nil {
+		h.respondEmailTaken(c, err)
+		return
+	}
+
+	// Solo puede haber una invitación pendiente por email: reenviar invalida la anterior
+	if err := h.invitationRepo.RevokePendingForEmail(email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	invitation := &domain.Invitation{
+		ID:        uuid.New(),
+		Email:     email,
+		Role:      req.Role,
+		InvitedBy: c.MustGet("user_id").(uuid.UUID),
+		ExpiresAt: time.Now().Add(h.ttl),
+		CreatedAt: time.Now(),
+	}
+
+	if err := h.invitationRepo.Create(invitation); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+
+	token, err := h.signInvitation(invitation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign invitation"})
+		return
+	}
+
+	log.Printf("Invitation %s created for %s (%s)", invitation.ID, invitation.Email, invitation.Role)
+
+	acceptURL := h.acceptURL + "?token=" + url.QueryEscape(token)
+
+	// Si el correo falla el admin aún puede compartir el enlace devuelto
+	emailSent := true
+	if err := h.sendInvitation(invitation, acceptURL); err != nil {
+		log.Printf("Error sending invitation %s: %v", invitation.ID, err)
+		emailSent = false
+	}
+
+	c.JSON(http.StatusCreated, InvitationResponse{
+		Invitation: *invitation,
+		Status:     invitation.Status(time.Now()),
+		Token:      token,
+		AcceptURL:  acceptURL,
+		EmailSent:  &emailSent,
+	})
+}
+
+// ListInvitations - Invitaciones pendientes de aceptar
+func (h *InvitationHandler) ListInvitations(c *gin.Context) {
+	invitations, err := h.invitationRepo.ListPending()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get invitations"})
+		return
+	}
+
+	now := time.Now()
+	responses := make([]InvitationResponse, 0, len(invitations))
+	for _, invitation := range invitations {
+		responses = append(responses, InvitationResponse{Invitation: invitation, Status: invitation.Status(now)})
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+// RevokeInvitation - Invalida una invitación pendiente
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	if err := h.invitationRepo.Revoke(id); err != nil {
+		if errors.Is(err, repository.ErrInvitationUnavailable) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Invitation is no longer pending"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// AcceptInvitation - El invitado canjea el token y crea su cuenta con el email y rol fijados
+func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invitation, err := h.verifyInvitation(req.Token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired invitation"})
+		return
+	}
+	if !h.auth.consents.requireSignupTerms(c, req.AcceptTerms, req.TermsVersion) {
+		return
+	}
+
+	if err := h.emailAvailable(invitation.Email); err != nil {
+		h.respondEmailTaken(c, err)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	// El enlace de invitación llegó a ese buzón, así que el email queda verificado
+	now := time.Now()
+	user := &domain.User{
+		ID:              uuid.New(),
+		Email:           invitation.Email,
+		PasswordHash:    string(hashedPassword),
+		FirstName:       req.FirstName,
+		LastName:        req.LastName,
+		Role:            invitation.Role,
+		IsActive:        true,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+	}
+
+	if err := h.invitationRepo.Accept(invitation, user); err != nil {
+		if errors.Is(err, repository.ErrInvitationUnavailable) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Invitation is no longer pending"})
+			return
+		}
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			// Otra cuenta ocupó el email después de la comprobación
+			c.JSON(http.StatusConflict, gin.H{"error": errUserExists.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	// La invitación no concede grabación ni livestream: solo se registran los términos
+	if err := h.auth.consents.recordRegistration(c, user.ID, false, false); err != nil {
+		log.Printf("Error recording consent of user %s: %v", user.ID, err)
+	}
+
+	resp, err := h.auth.issueSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// emailAvailable comprueba que el email no tenga cuenta. Los usuarios borrados
+// conservan su email mientras pueden restaurarse y el índice único los incluye,
+// así que también lo ocupan.
+func (h *InvitationHandler) emailAvailable(email string) error {
+	if existingUser, _ := h.userRepo.GetByEmail(email); existingUser != nil {
+		return errUserExists
+	}
+	taken, err := h.userRepo.EmailTaken(email)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errEmailDeleted
+	}
+	return nil
+}
+
+func (h *InvitationHandler) respondEmailTaken(c *gin.Context, err error) {
+	if errors.Is(err, errUserExists) || errors.Is(err, errEmailDeleted) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email"})
+}
+
+func (h *InvitationHandler) sendInvitation(invitation *domain.Invitation, acceptURL string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+
+	return h.mailer.Send(ctx, mail.Message{
+		To:      invitation.Email,
+		Subject: "Invitación a Vincula",
+		Body: fmt.Sprintf("Hola,\n\nHas sido invitado a Vincula como %s. Activa tu cuenta abriendo este enlace:\n\n%s\n\n"+
+			"La invitación caduca el %s.\n",
+			invitation.Role, acceptURL, invitation.ExpiresAt.Format("02/01/2006 15:04")),
+	})
+}
+
+func (h *InvitationHandler) signInvitation(invitation *domain.Invitation) (string, error) {
+	return h.auth.signToken(jwt.MapClaims{
+		"typ":   inviteTokenType,
+		"inv":   invitation.ID.String(),
+		"email": invitation.Email,
+		"role":  invitation.Role,
+		"exp":   invitation.ExpiresAt.Unix(),
+		"iat":   invitation.CreatedAt.Unix(),
+	})
+}
+
+// verifyInvitation valida la firma del token y que coincida con una invitación pendiente
+func (h *InvitationHandler) verifyInvitation(token string) (*domain.Invitation, error) {
+	claims, err := h.auth.parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if typ, _ := claims["typ"].(string); typ != inviteTokenType {
+		return nil, errInvalidInvitation
+	}
+
+	idStr, _ := claims["inv"].(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, errInvalidInvitation
+	}
+
+	invitation, err := h.invitationRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	email, _ := claims["email"].(string)
+	role, _ := claims["role"].(string)
+	if email != invitation.Email || domain.UserRole(role) != invitation.Role {
+		return nil, errInvalidInvitation
+	}
+	if status := invitation.Status(time.Now()); status != domain.InvitationPending {
+		return nil, fmt.Errorf("invitation %s is %s", invitation.ID, status)
+	}
+
+	return invitation, nil
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+func TestInvitationEmailAvailable(t *testing.T) {
+	store := newSSOStore()
+	addSSOUser(store, "ana@clinica.local", domain.RoleEmployee)
+	deleted := addSSOUser(store, "luis@clinica.local", domain.RoleEmployee)
+	deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	h := &InvitationHandler{userRepo: &fakeSSOUserRepo{store: store}}
+
+	tests := []struct {
+		name  string
+		email string
+		want  error
+	}{
+		{"email libre", "eva@clinica.local", nil},
+		{"usuario existente", "ana@clinica.local", errUserExists},
+		{"usuario borrado", "luis@clinica.local", errEmailDeleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := h.emailAvailable(tt.email); !errors.Is(err, tt.want) {
+				t.Errorf("emailAvailable(%q) = %v, want %v", tt.email, err, tt.want)
+			}
+		})
+	}
+}
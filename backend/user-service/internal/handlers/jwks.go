@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"user-service/internal/keys"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler publica las claves públicas de firma para que el gateway y el
+// resto de servicios validen los JWT sin compartir ningún secreto
+type JWKSHandler struct {
+	keys *keys.Manager
+}
+
+func NewJWKSHandler(keyManager *keys.Manager) *JWKSHandler {
+	return &JWKSHandler{keys: keyManager}
+}
+
+// GetJWKS - /.well-known/jwks.json con las claves vigentes y las rotadas aún en solapamiento
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
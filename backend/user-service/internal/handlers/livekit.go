@@ -1,19 +1,25 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 	"os"
 	"time"
 
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/livekit/protocol/auth"
 )
 
 type LiveKitHandler struct {
-	apiKey    string
-	apiSecret string
-	serverUrl string
+	queueRepo  repository.QueueRepository
+	familyRepo repository.FamilyRepository
+	apiKey     string
+	apiSecret  string
+	serverUrl  string
 }
 
 type TokenRequest struct {
@@ -23,7 +29,7 @@ type TokenRequest struct {
 	IsObserver    bool   `json:"is_observer"`
 }
 
-func NewLiveKitHandler() *LiveKitHandler {
+func NewLiveKitHandler(queueRepo repository.QueueRepository, familyRepo repository.FamilyRepository) *LiveKitHandler {
 	apiKey := os.Getenv("LIVEKIT_API_KEY")
 	if apiKey == "" {
 		apiKey = "devkey" // Local development key matching docker-compose
@@ -46,9 +52,11 @@ func NewLiveKitHandler() *LiveKitHandler {
 	}
 
 	return &LiveKitHandler{
-		apiKey:    apiKey,
-		apiSecret: apiSecret,
-		serverUrl: serverUrl,
+		queueRepo:  queueRepo,
+		familyRepo: familyRepo,
+		apiKey:     apiKey,
+		apiSecret:  apiSecret,
+		serverUrl:  serverUrl,
 	}
 }
 
@@ -61,18 +69,54 @@ func (h *LiveKitHandler) GenerateToken(c *gin.Context) {
 	}
 
 	// Verificar que el usuario está autenticado
-	userID, exists := c.Get("user_id")
-	if !exists {
+	principal, ok := principalFrom(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
 	// Validar que el participant_id coincida con el usuario autenticado
-	if req.ParticipantID != userID.(uuid.UUID).String() {
+	if req.ParticipantID != principal.UserID.String() {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Participant ID mismatch"})
 		return
 	}
 
+	callID, err := uuid.Parse(req.CallID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid call ID"})
+		return
+	}
+	call, err := h.queueRepo.GetCallByID(callID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Call not found"})
+		return
+	}
+	if call.Status != "active" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Call is not active"})
+		return
+	}
+
+	// Los familiares solo observan las llamadas de pacientes con vínculo aprobado
+	familyApproved := false
+	if principal.Is(domain.RoleFamily) {
+		familyApproved, err = h.familyRepo.IsApproved(call.PatientID, principal.UserID)
+		if err != nil {
+			log.Printf("Error checking family link of user %s: %v", principal.UserID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authorize call access"})
+			return
+		}
+	}
+
+	if err := authorizeCallToken(principal, req, call, familyApproved); err != nil {
+		forbidden(c, err)
+		return
+	}
+
+	// Los familiares nunca publican audio ni vídeo
+	if principal.Is(domain.RoleFamily) {
+		req.IsObserver = true
+	}
+
 	// Crear el token
 	at := auth.NewAccessToken(h.apiKey, h.apiSecret)
 
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultLoginMaxAttempts        = 5
+	defaultLoginIPMaxAttempts      = 20
+	defaultLoginSuspiciousAccounts = 5
+	defaultLoginAttemptWindow      = time.Hour
+	defaultLoginLockoutDuration    = 15 * time.Minute
+	defaultLoginBackoffBase        = time.Second
+	maxLoginLockoutDuration        = 24 * time.Hour
+	// La espera por IP es más suave: detrás de una IP puede haber muchos usuarios (NAT)
+	maxLoginIPBackoff = time.Minute
+)
+
+// LoginGuard limita los intentos de login fallidos por cuenta y por IP.
+//
+// Cada fallo impone una espera creciente (backoff exponencial) antes del
+// siguiente intento; al llegar al umbral la cuenta o la IP quedan bloqueadas
+// y cada bloqueo sucesivo dentro de la ventana dura el doble. Los contadores
+// por cuenta viven en la tabla users; los de IP en memoria de esta instancia.
+type LoginGuard struct {
+	userRepo  repository.UserRepository
+	auditRepo repository.AuditRepository
+	ips       *ipAttemptTracker
+
+	maxAttempts        int
+	ipMaxAttempts      int
+	suspiciousAccounts int
+	window             time.Duration
+	lockout            time.Duration
+	backoffBase        time.Duration
+}
+
+type ipAttempts struct {
+	failures     int
+	last         time.Time
+	blockedUntil time.Time
+	accounts     map[string]struct{}
+	flagged      bool
+}
+
+type ipAttemptTracker struct {
+	mu      sync.Mutex
+	entries map[string]*ipAttempts
+}
+
+// ipFailure resume el estado de una IP tras registrar un fallo
+type ipFailure struct {
+	failures     int
+	accounts     int
+	blockedUntil time.Time
+	blocked      bool
+	suspicious   bool
+}
+
+func NewLoginGuard(userRepo repository.UserRepository, auditRepo repository.AuditRepository) *LoginGuard {
+	return &LoginGuard{
+		userRepo:           userRepo,
+		auditRepo:          auditRepo,
+		ips:                &ipAttemptTracker{entries: make(map[string]*ipAttempts)},
+		maxAttempts:        intFromEnv("LOGIN_MAX_ATTEMPTS", defaultLoginMaxAttempts),
+		ipMaxAttempts:      intFromEnv("LOGIN_IP_MAX_ATTEMPTS", defaultLoginIPMaxAttempts),
+		suspiciousAccounts: intFromEnv("LOGIN_SUSPICIOUS_ACCOUNTS", defaultLoginSuspiciousAccounts),
+		window:             durationFromEnv("LOGIN_ATTEMPT_WINDOW", defaultLoginAttemptWindow),
+		lockout:            durationFromEnv("LOGIN_LOCKOUT_DURATION", defaultLoginLockoutDuration),
+		backoffBase:        durationFromEnv("LOGIN_BACKOFF_BASE", defaultLoginBackoffBase),
+	}
+}
+
+// CheckIP devuelve cuánto falta para que la IP pueda volver a intentarlo (0 si ya puede)
+func (g *LoginGuard) CheckIP(ip string, now time.Time) time.Duration {
+	g.ips.mu.Lock()
+	defer g.ips.mu.Unlock()
+
+	entry, ok := g.ips.entries[ip]
+	if !ok || now.Sub(entry.last) > g.window {
+		return 0
+	}
+	if now.Before(entry.blockedUntil) {
+		return entry.blockedUntil.Sub(now)
+	}
+	return remaining(entry.last.Add(g.backoff(entry.failures, maxLoginIPBackoff)), now)
+}
+
+// CheckAccount devuelve cuánto falta para que la cuenta admita otro intento
+func (g *LoginGuard) CheckAccount(user *domain.User, now time.Time) time.Duration {
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return user.LockedUntil.Sub(now)
+	}
+	if user.FailedLoginAttempts == 0 || user.LastFailedLoginAt == nil || now.Sub(*user.LastFailedLoginAt) > g.window {
+		return 0
+	}
+	return remaining(user.LastFailedLoginAt.Add(g.backoff(user.FailedLoginAttempts, g.lockout)), now)
+}
+
+// Allow comprueba IP y cuenta (si se conoce) y responde 429 si aún deben esperar
+func (g *LoginGuard) Allow(c *gin.Context, user *domain.User) bool {
+	now := time.Now()
+	wait := g.CheckIP(c.ClientIP(), now)
+	if user != nil {
+		if accountWait := g.CheckAccount(user, now); accountWait > wait {
+			wait = accountWait
+		}
+		// Intentos contra una cuenta bloqueada: posible ataque dirigido
+		if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+			g.audit(c, domain.AuditLockedLoginAttempt, &user.ID, user.ID.String(), gin.H{"locked_until": user.LockedUntil})
+		}
+	}
+	if wait <= 0 {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many failed login attempts, try again later",
+		"retry_after": retryAfter,
+	})
+	return false
+}
+
+// RecordFailure anota un intento fallido. user es nil si el email no existe.
+func (g *LoginGuard) RecordFailure(c *gin.Context, user *domain.User, email string) {
+	now := time.Now()
+	ip := c.ClientIP()
+
+	state := g.recordIPFailure(ip, domain.NormalizeEmail(email), now)
+	if state.blocked {
+		log.Printf("Login blocked for IP %s after %d failed attempts", ip, state.failures)
+		g.audit(c, domain.AuditLoginIPBlocked, nil, "", gin.H{
+			"failures":      state.failures,
+			"accounts":      state.accounts,
+			"blocked_until": state.blockedUntil,
+		})
+	}
+	if state.suspicious {
+		log.Printf("Suspicious login pattern from IP %s: %d accounts", ip, state.accounts)
+		g.audit(c, domain.AuditSuspiciousLogin, nil, "", gin.H{
+			"failures": state.failures,
+			"accounts": state.accounts,
+			"reason":   "failed logins against multiple accounts",
+		})
+	}
+
+	if user == nil {
+		return
+	}
+
+	attempts, err := g.userRepo.RecordLoginFailure(user.ID, now, g.window)
+	if err != nil {
+		log.Printf("Error recording failed login for user %s: %v", user.ID, err)
+		return
+	}
+	if attempts < g.maxAttempts {
+		return
+	}
+
+	until := now.Add(g.lockoutFor(attempts - g.maxAttempts))
+	if err := g.userRepo.LockUntil(user.ID, until); err != nil {
+		log.Printf("Error locking user %s: %v", user.ID, err)
+		return
+	}
+	log.Printf("User %s locked until %s after %d failed attempts", user.ID, until.Format(time.RFC3339), attempts)
+	g.audit(c, domain.AuditAccountLocked, &user.ID, user.ID.String(), gin.H{
+		"failures":     attempts,
+		"locked_until": until,
+	})
+}
+
+// RecordSuccess limpia los fallos de la cuenta al completar el login
+func (g *LoginGuard) RecordSuccess(user *domain.User) {
+	if user.FailedLoginAttempts == 0 && user.LockedUntil == nil {
+		return
+	}
+	if err := g.userRepo.ResetLoginFailures(user.ID); err != nil {
+		log.Printf("Error resetting failed logins for user %s: %v", user.ID, err)
+	}
+}
+
+// UnlockUser - Un admin desbloquea una cuenta y reinicia sus fallos
+func (g *LoginGuard) UnlockUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := g.userRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := g.userRepo.ResetLoginFailures(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	log.Printf("User %s unlocked by admin %s", user.ID, adminID)
+	g.audit(c, domain.AuditAccountUnlocked, &adminID, user.ID.String(), gin.H{
+		"failures":     user.FailedLoginAttempts,
+		"locked_until": user.LockedUntil,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// CleanupExpiredAttempts descarta periódicamente las IPs sin fallos recientes
+func (g *LoginGuard) CleanupExpiredAttempts(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		g.ips.mu.Lock()
+		for ip, entry := range g.ips.entries {
+			if now.Sub(entry.last) > g.window && now.After(entry.blockedUntil) {
+				delete(g.ips.entries, ip)
+			}
+		}
+		g.ips.mu.Unlock()
+	}
+}
+
+func (g *LoginGuard) recordIPFailure(ip, email string, now time.Time) ipFailure {
+	g.ips.mu.Lock()
+	defer g.ips.mu.Unlock()
+
+	entry, ok := g.ips.entries[ip]
+	if !ok || (now.Sub(entry.last) > g.window && now.After(entry.blockedUntil)) {
+		entry = &ipAttempts{accounts: make(map[string]struct{})}
+		g.ips.entries[ip] = entry
+	}
+	entry.failures++
+	entry.last = now
+	if email != "" {
+		entry.accounts[email] = struct{}{}
+	}
+
+	state := ipFailure{failures: entry.failures, accounts: len(entry.accounts)}
+	if entry.failures >= g.ipMaxAttempts {
+		entry.blockedUntil = now.Add(g.lockoutFor(entry.failures - g.ipMaxAttempts))
+		state.blocked = true
+		state.blockedUntil = entry.blockedUntil
+	}
+	// Muchas cuentas distintas desde la misma IP (credential stuffing) se reporta una vez
+	if !entry.flagged && len(entry.accounts) >= g.suspiciousAccounts {
+		entry.flagged = true
+		state.suspicious = true
+	}
+	return state
+}
+
+// backoff es la espera tras n fallos: base, 2*base, 4*base... hasta limit
+func (g *LoginGuard) backoff(failures int, limit time.Duration) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	return capDuration(g.backoffBase, failures-1, limit)
+}
+
+// lockoutFor duplica el bloqueo por cada fallo por encima del umbral (over)
+func (g *LoginGuard) lockoutFor(over int) time.Duration {
+	return capDuration(g.lockout, over, maxLoginLockoutDuration)
+}
+
+func (g *LoginGuard) audit(c *gin.Context, action string, userID *uuid.UUID, resourceID string, details gin.H) {
+	var resourceType string
+	if resourceID != "" {
+		resourceType = "user"
+	}
+	writeAudit(g.auditRepo, c, action, userID, resourceType, resourceID, details)
+}
+
+// capDuration calcula base*2^exp sin pasar de limit
+func capDuration(base time.Duration, exp int, limit time.Duration) time.Duration {
+	if exp < 0 {
+		exp = 0
+	}
+	d := base
+	for i := 0; i < exp && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		return limit
+	}
+	return d
+}
+
+func remaining(until, now time.Time) time.Duration {
+	if until.After(now) {
+		return until.Sub(now)
+	}
+	return 0
+}
+
+// intFromEnv lee un entero positivo o devuelve el valor por defecto
+func intFromEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Invalid %s=%q, using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"user-service/internal/domain"
+)
+
+func newTestLoginGuard() *LoginGuard {
+	return &LoginGuard{
+		ips:                &ipAttemptTracker{entries: make(map[string]*ipAttempts)},
+		maxAttempts:        5,
+		ipMaxAttempts:      20,
+		suspiciousAccounts: 5,
+		window:             time.Hour,
+		lockout:            15 * time.Minute,
+		backoffBase:        time.Second,
+	}
+}
+
+func TestCapDuration(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  time.Duration
+		exp   int
+		limit time.Duration
+		want  time.Duration
+	}{
+		{"exponente cero", time.Second, 0, time.Minute, time.Second},
+		{"exponente negativo", time.Second, -3, time.Minute, time.Second},
+		{"duplica por cada paso", time.Second, 4, time.Minute, 16 * time.Second},
+		{"se corta en el límite", time.Second, 6, time.Minute, time.Minute},
+		{"exponente enorme sin desbordar", time.Second, 1000, time.Minute, time.Minute},
+		{"base por encima del límite", 2 * time.Minute, 0, time.Minute, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := capDuration(tt.base, tt.exp, tt.limit); got != tt.want {
+				t.Errorf("capDuration() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginBackoff(t *testing.T) {
+	g := newTestLoginGuard()
+
+	tests := []struct {
+		failures int
+		limit    time.Duration
+		want     time.Duration
+	}{
+		{0, time.Minute, 0},
+		{1, time.Minute, time.Second},
+		{2, time.Minute, 2 * time.Second},
+		{5, time.Minute, 16 * time.Second},
+		{7, time.Minute, time.Minute},
+		{50, time.Minute, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := g.backoff(tt.failures, tt.limit); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+
+	// Cada bloqueo por encima del umbral dura el doble, hasta 24h
+	lockouts := []struct {
+		over int
+		want time.Duration
+	}{
+		{0, 15 * time.Minute},
+		{1, 30 * time.Minute},
+		{3, 2 * time.Hour},
+		{6, 16 * time.Hour},
+		{7, maxLoginLockoutDuration},
+		{100, maxLoginLockoutDuration},
+	}
+	for _, tt := range lockouts {
+		if got := g.lockoutFor(tt.over); got != tt.want {
+			t.Errorf("lockoutFor(%d) = %s, want %s", tt.over, got, tt.want)
+		}
+	}
+}
+
+func TestCheckAccount(t *testing.T) {
+	g := newTestLoginGuard()
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time {
+		at := now.Add(-d)
+		return &at
+	}
+	in := func(d time.Duration) *time.Time {
+		at := now.Add(d)
+		return &at
+	}
+
+	tests := []struct {
+		name string
+		user domain.User
+		want time.Duration
+	}{
+		{"sin fallos", domain.User{}, 0},
+		{"tercer fallo hace 1s: espera 4s", domain.User{FailedLoginAttempts: 3, LastFailedLoginAt: ago(time.Second)}, 3 * time.Second},
+		{"espera ya cumplida", domain.User{FailedLoginAttempts: 3, LastFailedLoginAt: ago(10 * time.Second)}, 0},
+		{"fallos fuera de la ventana", domain.User{FailedLoginAttempts: 12, LastFailedLoginAt: ago(2 * time.Hour)}, 0},
+		{"cuenta bloqueada", domain.User{FailedLoginAttempts: 5, LastFailedLoginAt: ago(time.Minute), LockedUntil: in(10 * time.Minute)}, 10 * time.Minute},
+		{"bloqueo caducado", domain.User{FailedLoginAttempts: 1, LastFailedLoginAt: ago(time.Hour - time.Minute), LockedUntil: ago(time.Minute)}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.CheckAccount(&tt.user, now); got != tt.want {
+				t.Errorf("CheckAccount() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordIPFailure(t *testing.T) {
+	g := newTestLoginGuard()
+	now := time.Now()
+	ip := "203.0.113.7"
+
+	// Por debajo del umbral solo hay backoff, acotado a maxLoginIPBackoff
+	for i := 1; i < g.ipMaxAttempts; i++ {
+		if state := g.recordIPFailure(ip, "ana@clinica.local", now); state.blocked {
+			t.Fatalf("IP blocked after %d failures", i)
+		}
+	}
+	if got := g.CheckIP(ip, now); got != maxLoginIPBackoff {
+		t.Errorf("CheckIP() after %d failures = %s, want %s", g.ipMaxAttempts-1, got, maxLoginIPBackoff)
+	}
+
+	// En el umbral se bloquea durante lockout y el siguiente fallo lo duplica
+	state := g.recordIPFailure(ip, "ana@clinica.local", now)
+	if !state.blocked || state.blockedUntil.Sub(now) != g.lockout {
+		t.Fatalf("state at the threshold = %+v, want blocked for %s", state, g.lockout)
+	}
+	state = g.recordIPFailure(ip, "ana@clinica.local", now)
+	if state.blockedUntil.Sub(now) != 2*g.lockout {
+		t.Errorf("second lockout = %s, want %s", state.blockedUntil.Sub(now), 2*g.lockout)
+	}
+	if got := g.CheckIP(ip, now); got != 2*g.lockout {
+		t.Errorf("CheckIP() while blocked = %s, want %s", got, 2*g.lockout)
+	}
+
+	// Pasada la ventana y el bloqueo, la IP empieza de cero
+	later := now.Add(g.window + 2*g.lockout + time.Second)
+	if got := g.CheckIP(ip, later); got != 0 {
+		t.Errorf("CheckIP() after the window = %s, want 0", got)
+	}
+	if state := g.recordIPFailure(ip, "ana@clinica.local", later); state.failures != 1 {
+		t.Errorf("failures after the window = %d, want 1", state.failures)
+	}
+}
+
+func TestRecordIPFailureSuspicious(t *testing.T) {
+	g := newTestLoginGuard()
+	now := time.Now()
+	emails := []string{"a@x.com", "b@x.com", "c@x.com", "d@x.com", "e@x.com", "f@x.com"}
+
+	flagged := 0
+	for i, email := range emails {
+		state := g.recordIPFailure("198.51.100.1", email, now)
+		if state.suspicious {
+			flagged++
+			if i+1 != g.suspiciousAccounts {
+				t.Errorf("flagged after %d accounts, want %d", i+1, g.suspiciousAccounts)
+			}
+		}
+	}
+	// Se informa una sola vez por IP
+	if flagged != 1 {
+		t.Errorf("suspicious pattern reported %d times, want 1", flagged)
+	}
+}
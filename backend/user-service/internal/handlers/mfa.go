@@ -0,0 +1,497 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/mfa"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	mfaChallengeTTL  = 5 * time.Minute
+	mfaTokenType     = "mfa"
+	mfaPurposeLogin  = "login"
+	mfaPurposeEnroll = "enroll"
+	// Un paso de margen a cada lado (±30s) por desfase de reloj del teléfono
+	mfaSkew = 1
+)
+
+var errInvalidMFAToken = errors.New("invalid mfa token")
+
+// MFAChallengeResponse sustituye a AuthResponse en el primer paso del login
+// cuando la cuenta tiene (o debe tener) MFA
+type MFAChallengeResponse struct {
+	MFARequired           bool      `json:"mfa_required"`
+	MFAEnrollmentRequired bool      `json:"mfa_enrollment_required"`
+	MFAToken              string    `json:"mfa_token"`
+	ExpiresAt             time.Time `json:"expires_at"`
+}
+
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfa_token"`
+}
+
+type MFAEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+}
+
+type MFAConfirmRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type MFAConfirmResponse struct {
+	RecoveryCodes []string      `json:"recovery_codes"`
+	Session       *AuthResponse `json:"session,omitempty"`
+}
+
+type MFADisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type MFARecoveryCodesRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// mfaRequiredRolesFromEnv lee MFA_REQUIRED_ROLES (p. ej. "employee,admin")
+func mfaRequiredRolesFromEnv() map[domain.UserRole]bool {
+	roles := make(map[domain.UserRole]bool)
+	for _, role := range strings.Split(os.Getenv("MFA_REQUIRED_ROLES"), ",") {
+		role = strings.TrimSpace(role)
+		if role == "" {
+			continue
+		}
+		if !validRole(domain.UserRole(role)) {
+			log.Printf("Ignoring unknown role %q in MFA_REQUIRED_ROLES", role)
+			continue
+		}
+		roles[domain.UserRole(role)] = true
+	}
+	return roles
+}
+
+func (h *AuthHandler) mfaRequired(role domain.UserRole) bool {
+	return h.mfaRequiredRoles[role]
+}
+
+// respondMFAChallenge emite el token intermedio del login. Si la política exige
+// MFA y la cuenta aún no lo tiene, el token solo sirve para darlo de alta.
+func (h *AuthHandler) respondMFAChallenge(c *gin.Context, user *domain.User) {
+	purpose := mfaPurposeLogin
+	if !user.MFAEnabled {
+		purpose = mfaPurposeEnroll
+	}
+
+	expiresAt := time.Now().Add(mfaChallengeTTL)
+	token, err := h.signToken(jwt.MapClaims{
+		"typ":     mfaTokenType,
+		"sub":     user.ID.String(),
+		"purpose": purpose,
+		"jti":     uuid.New().String(),
+		"exp":     expiresAt.Unix(),
+		"iat":     time.Now().Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAChallengeResponse{
+		MFARequired:           true,
+		MFAEnrollmentRequired: purpose == mfaPurposeEnroll,
+		MFAToken:              token,
+		ExpiresAt:             expiresAt,
+	})
+}
+
+// parseMFAChallenge valida el token intermedio y devuelve su usuario y el jti
+// que hay que gastar con consumeMFAChallenge al completar la verificación
+func (h *AuthHandler) parseMFAChallenge(token, purpose string) (*domain.User, *domain.RevokedToken, error) {
+	claims, err := h.parseToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if typ, _ := claims["typ"].(string); typ != mfaTokenType {
+		return nil, nil, errInvalidMFAToken
+	}
+	if p, _ := claims["purpose"].(string); p != purpose {
+		return nil, nil, errInvalidMFAToken
+	}
+
+	sub, _ := claims["sub"].(string)
+	userID, err := uuid.Parse(sub)
+	if err != nil {
+		return nil, nil, errInvalidMFAToken
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, nil, errInvalidMFAToken
+	}
+	used, err := h.tokenRepo.IsAccessTokenRevoked(jti)
+	if err != nil {
+		return nil, nil, err
+	}
+	if used {
+		return nil, nil, errInvalidMFAToken
+	}
+	exp, _ := claims.GetExpirationTime()
+	challenge := &domain.RevokedToken{JTI: jti, UserID: userID}
+	if exp != nil {
+		challenge.ExpiresAt = exp.Time
+	}
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, errInvalidMFAToken
+	}
+	return user, challenge, nil
+}
+
+// consumeMFAChallenge gasta el token intermedio: con él ya no se puede volver a
+// completar el login aunque no haya expirado
+func (h *AuthHandler) consumeMFAChallenge(c *gin.Context, challenge *domain.RevokedToken) bool {
+	err := h.tokenRepo.ConsumeAccessToken(challenge)
+	if errors.Is(err, repository.ErrAccessTokenRevoked) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify MFA token"})
+		return false
+	}
+	return true
+}
+
+// MFALogin - Segundo paso del login: código TOTP o código de recuperación
+func (h *AuthHandler) MFALogin(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, challenge, err := h.parseMFAChallenge(req.MFAToken, mfaPurposeLogin)
+	if err != nil || !user.MFAEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		return
+	}
+
+	// Los códigos fallidos cuentan igual que las contraseñas erróneas
+	if !h.guard.Allow(c, user) {
+		return
+	}
+
+	switch {
+	case req.Code != "":
+		if err := h.verifyTOTP(user, req.Code); err != nil {
+			h.guard.RecordFailure(c, user, user.Email)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+			return
+		}
+	case req.RecoveryCode != "":
+		if err := h.mfaRepo.UseRecoveryCode(user.ID, mfa.HashRecoveryCode(req.RecoveryCode)); err != nil {
+			h.guard.RecordFailure(c, user, user.Email)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid recovery code"})
+			return
+		}
+		log.Printf("User %s signed in with a recovery code", user.ID)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return
+	}
+
+	if !h.consumeMFAChallenge(c, challenge) {
+		return
+	}
+
+	resp, err := h.issueSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	h.guard.RecordSuccess(user)
+	c.JSON(http.StatusOK, resp)
+}
+
+// EnrollMFA - Genera un secreto TOTP pendiente de confirmar. Acepta una sesión
+// normal o el mfa_token de un login que exige dar de alta MFA.
+func (h *AuthHandler) EnrollMFA(c *gin.Context) {
+	var req MFAEnrollRequest
+	// El cuerpo es opcional cuando se usa el header Authorization
+	_ = c.ShouldBindJSON(&req)
+
+	user, _, ok := h.mfaEnrollmentUser(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+		return
+	}
+
+	secret, err := mfa.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate MFA secret"})
+		return
+	}
+	encrypted, err := h.mfaCipher.Encrypt(secret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate MFA secret"})
+		return
+	}
+
+	user.MFASecret = encrypted
+	user.UpdatedAt = time.Now()
+	if err := h.userRepo.Update(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save MFA secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAEnrollResponse{
+		Secret:     secret,
+		OTPAuthURL: mfa.ProvisioningURI(h.mfaIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmMFA - Activa MFA con un primer código válido y entrega los códigos de recuperación
+func (h *AuthHandler) ConfirmMFA(c *gin.Context) {
+	var req MFAConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, challenge, ok := h.mfaEnrollmentUser(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+		return
+	}
+	if user.MFASecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA enrollment not started"})
+		return
+	}
+
+	// Los códigos fallidos cuentan igual que en MFALogin
+	if !h.guard.Allow(c, user) {
+		return
+	}
+	if err := h.verifyTOTP(user, req.Code); err != nil {
+		h.guard.RecordFailure(c, user, user.Email)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+		return
+	}
+	if challenge != nil && !h.consumeMFAChallenge(c, challenge) {
+		return
+	}
+
+	codes, err := h.replaceRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	if err := h.mfaRepo.Enable(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable MFA"})
+		return
+	}
+	user.MFAEnabled = true
+
+	resp := MFAConfirmResponse{RecoveryCodes: codes}
+
+	// Si el alta venía del login, se completa también el inicio de sesión
+	if challenge != nil {
+		session, err := h.issueSession(c, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		resp.Session = session
+	}
+
+	h.guard.RecordSuccess(user)
+	c.JSON(http.StatusOK, resp)
+}
+
+// DisableMFA - Desactiva MFA pidiendo contraseña y código; no se permite si la política lo exige
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	var req MFADisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(c.MustGet("user_id").(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is not enabled"})
+		return
+	}
+	if h.mfaRequired(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "MFA is mandatory for this role"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+	if err := h.verifyTOTP(user, req.Code); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+		return
+	}
+
+	if err := h.mfaRepo.Disable(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable MFA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA disabled successfully"})
+}
+
+// RegenerateRecoveryCodes - Sustituye todos los códigos de recuperación
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req MFARecoveryCodesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(c.MustGet("user_id").(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is not enabled"})
+		return
+	}
+
+	if err := h.verifyTOTP(user, req.Code); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+		return
+	}
+
+	codes, err := h.replaceRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAConfirmResponse{RecoveryCodes: codes})
+}
+
+// GetMFAStatus - Estado de MFA del usuario autenticado
+func (h *AuthHandler) GetMFAStatus(c *gin.Context) {
+	user, err := h.userRepo.GetByID(c.MustGet("user_id").(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	remaining, err := h.mfaRepo.CountRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get MFA status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.MFAEnabled,
+		"required":                 h.mfaRequired(user.Role),
+		"enabled_at":               user.MFAEnabledAt,
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// mfaEnrollmentUser resuelve el usuario del alta: por mfa_token (login con MFA
+// obligatorio, y entonces devuelve su jti) o por el access token del header
+// Authorization
+func (h *AuthHandler) mfaEnrollmentUser(c *gin.Context, mfaToken string) (*domain.User, *domain.RevokedToken, bool) {
+	if mfaToken != "" {
+		user, challenge, err := h.parseMFAChallenge(mfaToken, mfaPurposeEnroll)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+			return nil, nil, false
+		}
+		return user, challenge, true
+	}
+
+	if !h.authenticate(c) {
+		return nil, nil, false
+	}
+
+	user, err := h.userRepo.GetByID(c.MustGet("user_id").(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, nil, false
+	}
+	return user, nil, true
+}
+
+// verifyTOTP valida el código contra el secreto cifrado y consume su paso
+func (h *AuthHandler) verifyTOTP(user *domain.User, code string) error {
+	secret, err := h.mfaCipher.Decrypt(user.MFASecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := mfa.Validate(secret, code, time.Now(), mfaSkew)
+	if !ok {
+		return errInvalidMFAToken
+	}
+	if err := h.mfaRepo.ClaimStep(user.ID, step); err != nil {
+		if errors.Is(err, repository.ErrMFACodeReused) {
+			log.Printf("Rejected reused MFA code for user %s", user.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (h *AuthHandler) replaceRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	codes, err := mfa.GenerateRecoveryCodes(mfa.RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = mfa.HashRecoveryCode(code)
+	}
+	if err := h.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/mfa"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// fakeMFARepo aplica la regla de ClaimStep: solo se acepta un paso mayor que el último
+type fakeMFARepo struct {
+	repository.MFARepository
+	lastStep int64
+}
+
+func (r *fakeMFARepo) ClaimStep(userID uuid.UUID, step int64) error {
+	if step <= r.lastStep {
+		return repository.ErrMFACodeReused
+	}
+	r.lastStep = step
+	return nil
+}
+
+func TestVerifyTOTPRejectsReplay(t *testing.T) {
+	cipher, err := mfa.NewCipher("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := mfa.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := cipher.Encrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Evita que el paso cambie a mitad del test
+	if left := mfa.Period - time.Duration(time.Now().UnixNano())%mfa.Period; left < time.Second {
+		time.Sleep(left)
+	}
+	current := mfa.Step(time.Now())
+	code := func(step int64) string {
+		c, err := mfa.Code(secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	repo := &fakeMFARepo{}
+	h := &AuthHandler{mfaRepo: repo, mfaCipher: cipher}
+	user := &domain.User{ID: uuid.New(), MFASecret: encrypted}
+
+	steps := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{"código del paso anterior", code(current - 1), nil},
+		{"mismo código otra vez", code(current - 1), repository.ErrMFACodeReused},
+		{"código actual", code(current), nil},
+		{"código anterior tras usar el actual", code(current - 1), repository.ErrMFACodeReused},
+		{"código actual repetido", code(current), repository.ErrMFACodeReused},
+		{"código erróneo", "ABCDEF", errInvalidMFAToken},
+	}
+	for _, step := range steps {
+		if err := h.verifyTOTP(user, step.code); !errors.Is(err, step.wantErr) {
+			t.Errorf("%s: verifyTOTP() = %v, want %v", step.name, err, step.wantErr)
+		}
+	}
+	if repo.lastStep != current {
+		t.Errorf("last accepted step = %d, want %d", repo.lastStep, current)
+	}
+}
+
+// fakeFailureUserRepo cuenta los fallos que anota LoginGuard
+type fakeFailureUserRepo struct {
+	*fakeTokenUserRepo
+	failures int
+}
+
+func (r *fakeFailureUserRepo) RecordLoginFailure(id uuid.UUID, at time.Time, window time.Duration) (int, error) {
+	r.failures++
+	return r.failures, nil
+}
+
+// mfaChallenge emite el token intermedio de login para user
+func mfaChallenge(t *testing.T, h *AuthHandler, user *domain.User) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+	h.respondMFAChallenge(c, user)
+
+	var resp MFAChallengeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.MFAToken
+}
+
+func postMFA(handler gin.HandlerFunc, path string, body interface{}) int {
+	data, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler(c)
+	return w.Code
+}
+
+func TestMFAChallengeSingleUse(t *testing.T) {
+	h, _, user := newTestAuthHandler(t)
+	secret, err := mfa.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.mfaCipher, _ = mfa.NewCipher("test-key")
+	if user.MFASecret, err = h.mfaCipher.Encrypt(secret); err != nil {
+		t.Fatal(err)
+	}
+	user.MFAEnabled = true
+	repo := &fakeMFARepo{}
+	h.mfaRepo = repo
+	users := &fakeFailureUserRepo{fakeTokenUserRepo: h.userRepo.(*fakeTokenUserRepo)}
+	h.userRepo = users
+	h.guard = newTestLoginGuard()
+	h.guard.userRepo = users
+
+	if left := mfa.Period - time.Duration(time.Now().UnixNano())%mfa.Period; left < time.Second {
+		time.Sleep(left)
+	}
+	current := mfa.Step(time.Now())
+	code := func(step int64) string {
+		c, err := mfa.Code(secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	token := mfaChallenge(t, h, user)
+	if status := postMFA(h.MFALogin, "/api/auth/mfa/login", MFALoginRequest{MFAToken: token, Code: code(current - 1)}); status != http.StatusOK {
+		t.Fatalf("first MFA login = %d, want %d", status, http.StatusOK)
+	}
+	// El mismo token con otro código válido ya no sirve
+	if status := postMFA(h.MFALogin, "/api/auth/mfa/login", MFALoginRequest{MFAToken: token, Code: code(current)}); status != http.StatusUnauthorized {
+		t.Errorf("reused MFA token = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if repo.lastStep != current-1 {
+		t.Errorf("last accepted step = %d, want %d", repo.lastStep, current-1)
+	}
+
+	// El alta desde el login también gasta el token y los códigos fallidos
+	// pasan por LoginGuard
+	user.MFAEnabled = false
+	enroll := mfaChallenge(t, h, user)
+	if status := postMFA(h.ConfirmMFA, "/api/auth/mfa/enroll/confirm", MFAConfirmRequest{MFAToken: enroll, Code: "ABCDEF"}); status != http.StatusUnauthorized {
+		t.Errorf("wrong enrollment code = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if users.failures != 1 {
+		t.Errorf("failures recorded = %d, want 1", users.failures)
+	}
+}
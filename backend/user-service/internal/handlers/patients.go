@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// mrnPattern limita el número de historia clínica a letras, dígitos y guiones
+var mrnPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]{0,49}$`)
+
+type PatientHandler struct {
+	patientRepo repository.PatientRepository
+	userRepo    repository.UserRepository
+}
+
+// PatientProfileRequest crea la ficha clínica de un paciente
+type PatientProfileRequest struct {
+	MedicalRecordNumber string                   `json:"medical_record_number" binding:"required"`
+	DateOfBirth         *domain.Date             `json:"date_of_birth"`
+	EmergencyContact    *domain.EmergencyContact `json:"emergency_contact"`
+	InsuranceInfo       *domain.InsuranceInfo    `json:"insurance_info"`
+}
+
+func NewPatientHandler(patientRepo repository.PatientRepository, userRepo repository.UserRepository) *PatientHandler {
+	return &PatientHandler{
+		patientRepo: patientRepo,
+		userRepo:    userRepo,
+	}
+}
+
+// GetPatientProfile - Ficha clínica del paciente (el propio paciente o personal)
+func (h *PatientHandler) GetPatientProfile(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	patient, err := h.patientRepo.GetByUserID(userID)
+	if err != nil {
+		h.respondPatientError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, patient)
+}
+
+// CreatePatientProfile - Alta de la ficha clínica de un usuario con rol patient (personal)
+func (h *PatientHandler) CreatePatientProfile(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req PatientProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mrn := strings.TrimSpace(req.MedicalRecordNumber)
+	if !mrnPattern.MatchString(mrn) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid medical record number"})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.Role != domain.RolePatient {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User is not a patient"})
+		return
+	}
+
+	if _, err := h.patientRepo.GetByUserID(userID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Patient profile already exists"})
+		return
+	} else if !errors.Is(err, repository.ErrPatientNotFound) {
+		h.respondPatientError(c, err)
+		return
+	}
+
+	patient := &domain.Patient{
+		ID:                  uuid.New(),
+		UserID:              userID,
+		MedicalRecordNumber: mrn,
+		DateOfBirth:         req.DateOfBirth,
+		EmergencyContact:    req.EmergencyContact,
+		InsuranceInfo:       req.InsuranceInfo,
+		CreatedAt:           time.Now(),
+		UpdatedAt:           time.Now(),
+	}
+	if err := validatePatient(patient); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.patientRepo.Create(patient); err != nil {
+		h.respondPatientError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, patient)
+}
+
+// UpdatePatientProfile - Edita la ficha; el paciente puede cambiar sus datos de
+// contacto y seguro, pero el MRN y la fecha de nacimiento solo el personal
+func (h *PatientHandler) UpdatePatientProfile(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req PatientUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	principal, ok := principalFrom(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if err := authorizePatientUpdate(principal, userID, req); err != nil {
+		forbidden(c, err)
+		return
+	}
+
+	patient, err := h.patientRepo.GetByUserID(userID)
+	if err != nil {
+		h.respondPatientError(c, err)
+		return
+	}
+
+	if req.MedicalRecordNumber != nil {
+		mrn := strings.TrimSpace(*req.MedicalRecordNumber)
+		if !mrnPattern.MatchString(mrn) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid medical record number"})
+			return
+		}
+		patient.MedicalRecordNumber = mrn
+	}
+	if req.DateOfBirth != nil {
+		patient.DateOfBirth = req.DateOfBirth
+	}
+	if req.EmergencyContact != nil {
+		patient.EmergencyContact = req.EmergencyContact
+	}
+	if req.InsuranceInfo != nil {
+		patient.InsuranceInfo = req.InsuranceInfo
+	}
+	if err := validatePatient(patient); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	patient.UpdatedAt = time.Now()
+	if err := h.patientRepo.Update(patient); err != nil {
+		h.respondPatientError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, patient)
+}
+
+// DeletePatientProfile - Borra la ficha clínica (solo admin)
+func (h *PatientHandler) DeletePatientProfile(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.patientRepo.DeleteByUserID(userID); err != nil {
+		h.respondPatientError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Patient profile deleted successfully"})
+}
+
+func (h *PatientHandler) respondPatientError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrPatientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient profile not found"})
+	case errors.Is(err, repository.ErrDuplicateMRN):
+		c.JSON(http.StatusConflict, gin.H{"error": "Medical record number already in use"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save patient profile"})
+	}
+}
+
+// validatePatient comprueba los datos que no valida el binding
+func validatePatient(patient *domain.Patient) error {
+	if patient.DateOfBirth != nil && patient.DateOfBirth.After(time.Now()) {
+		return errors.New("date_of_birth cannot be in the future")
+	}
+	if contact := patient.EmergencyContact; contact != nil && (strings.TrimSpace(contact.Name) == "" || strings.TrimSpace(contact.Phone) == "") {
+		return errors.New("emergency_contact requires name and phone")
+	}
+	if insurance := patient.InsuranceInfo; insurance != nil && (strings.TrimSpace(insurance.Provider) == "" || strings.TrimSpace(insurance.PolicyNumber) == "") {
+		return errors.New("insurance_info requires provider and policy_number")
+	}
+	return nil
+}
+
+// canViewPatientProfile decide si la ficha clínica embebida en colas y llamadas
+// se muestra: al personal y al propio paciente, nunca a integraciones
+func canViewPatientProfile(actor Principal, patientID uuid.UUID) bool {
+	if actor.IsServiceAccount() {
+		return false
+	}
+	return actor.Is(domain.RoleEmployee, domain.RoleAdmin) || actor.UserID == patientID
+}
@@ -63,6 +63,11 @@ func (h *QueueHandler) JoinQueue(c *gin.Context) {
 		return
 	}
 
+	if !user.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email address must be verified before joining the queue"})
+		return
+	}
+
 	// Establecer prioridad por defecto si no se especifica
 	priority := req.Priority
 	if priority == 0 {
@@ -99,6 +104,15 @@ func (h *QueueHandler) GetQueue(c *gin.Context) {
 		return
 	}
 
+	// Las integraciones con queue:read ven la cola pero no las fichas clínicas
+	if principal, ok := principalFrom(c); ok {
+		for i := range entries {
+			if !canViewPatientProfile(principal, entries[i].PatientID) {
+				entries[i].PatientProfile = nil
+			}
+		}
+	}
+
 	// Calcular estadísticas
 	stats := h.calculateQueueStats(entries)
 
@@ -246,6 +260,12 @@ func (h *QueueHandler) GetActiveCalls(c *gin.Context) {
 		return
 	}
 
+	if principal, ok := principalFrom(c); ok {
+		for i := range calls {
+			hidePatientProfile(principal, &calls[i])
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"calls": calls,
 		"count": len(calls),
@@ -267,6 +287,13 @@ func (h *QueueHandler) GetCall(c *gin.Context) {
 		return
 	}
 
+	principal, ok := principalFrom(c)
+	if !ok || !canAccessCall(principal, call, domain.ScopeCallsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+	hidePatientProfile(principal, call)
+
 	c.JSON(http.StatusOK, gin.H{
 		"call": call,
 	})
@@ -281,6 +308,19 @@ func (h *QueueHandler) EndCall(c *gin.Context) {
 		return
 	}
 
+	call, err := h.queueRepo.GetCallByID(callID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Call not found"})
+		return
+	}
+
+	// Solo los participantes de la llamada, un admin o una integración con calls:write pueden terminarla
+	principal, ok := principalFrom(c)
+	if !ok || !canAccessCall(principal, call, domain.ScopeCallsWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
 	if err := h.queueRepo.EndCall(callID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end call"})
 		return
@@ -292,6 +332,19 @@ func (h *QueueHandler) EndCall(c *gin.Context) {
 }
 
 // Helper functions
+
+// hidePatientProfile quita la ficha clínica de la llamada (y de su entrada de
+// cola) si quien la consulta no puede verla
+func hidePatientProfile(principal Principal, call *domain.Call) {
+	if canViewPatientProfile(principal, call.PatientID) {
+		return
+	}
+	call.PatientProfile = nil
+	if call.QueueEntry != nil {
+		call.QueueEntry.PatientProfile = nil
+	}
+}
+
 func (h *QueueHandler) getQueuePosition(entryID uuid.UUID) int {
 	entries, err := h.queueRepo.GetQueueEntries()
 	if err != nil {
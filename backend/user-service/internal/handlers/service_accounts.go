@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	apiKeyPrefix = "vk_"
+	// El último uso de una key se anota como mucho una vez por minuto
+	apiKeyTouchInterval = time.Minute
+)
+
+// ServiceAccountHandler gestiona las cuentas de servicio de las integraciones
+// (solo admins) y autentica sus API keys en AuthMiddleware
+type ServiceAccountHandler struct {
+	accountRepo repository.ServiceAccountRepository
+	auditRepo   repository.AuditRepository
+}
+
+type CreateServiceAccountRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type ServiceAccountResponse struct {
+	ID          uuid.UUID        `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	IsActive    bool             `json:"is_active"`
+	CreatedBy   uuid.UUID        `json:"created_by"`
+	CreatedAt   time.Time        `json:"created_at"`
+	APIKeys     []APIKeyResponse `json:"api_keys"`
+}
+
+type APIKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyResponse incluye la key completa: es la única vez que se devuelve
+type CreateAPIKeyResponse struct {
+	Key    string         `json:"key"`
+	APIKey APIKeyResponse `json:"api_key"`
+}
+
+func NewServiceAccountHandler(accountRepo repository.ServiceAccountRepository, auditRepo repository.AuditRepository) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		accountRepo: accountRepo,
+		auditRepo:   auditRepo,
+	}
+}
+
+// CreateServiceAccount - Da de alta la cuenta de una integración (sin keys)
+func (h *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	var req CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	now := time.Now()
+	account := &domain.ServiceAccount{
+		ID:          uuid.New(),
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		IsActive:    true,
+		CreatedBy:   adminID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := h.accountRepo.Create(account); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Service account name already in use"})
+		return
+	}
+
+	writeAudit(h.auditRepo, c, domain.AuditServiceAccountCreated, &adminID, "service_account", account.ID.String(), gin.H{
+		"name": account.Name,
+	})
+	c.JSON(http.StatusCreated, toServiceAccountResponse(account))
+}
+
+// ListServiceAccounts - Lista las cuentas de servicio con sus keys (sin el secreto)
+func (h *ServiceAccountHandler) ListServiceAccounts(c *gin.Context) {
+	accounts, err := h.accountRepo.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get service accounts"})
+		return
+	}
+
+	response := make([]ServiceAccountResponse, 0, len(accounts))
+	for i := range accounts {
+		response = append(response, toServiceAccountResponse(&accounts[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"service_accounts": response})
+}
+
+// DeactivateServiceAccount - Desactiva la cuenta y revoca todas sus keys
+func (h *ServiceAccountHandler) DeactivateServiceAccount(c *gin.Context) {
+	account, ok := h.accountFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.accountRepo.Deactivate(account.ID, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate service account"})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	log.Printf("Service account %s deactivated by admin %s", account.ID, adminID)
+	writeAudit(h.auditRepo, c, domain.AuditServiceAccountDeactivated, &adminID, "service_account", account.ID.String(), gin.H{
+		"name": account.Name,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Service account deactivated successfully"})
+}
+
+// CreateAPIKey - Emite una key con los scopes indicados y caducidad opcional
+func (h *ServiceAccountHandler) CreateAPIKey(c *gin.Context) {
+	account, ok := h.accountFromParam(c)
+	if !ok {
+		return
+	}
+	if !account.IsActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Service account is inactive"})
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !domain.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope " + scope, "valid_scopes": domain.Scopes})
+			return
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	raw, prefix, err := generateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	key := &domain.APIKey{
+		ID:               uuid.New(),
+		ServiceAccountID: account.ID,
+		Name:             strings.TrimSpace(req.Name),
+		Prefix:           prefix,
+		KeyHash:          hashToken(raw),
+		Scopes:           strings.Join(req.Scopes, " "),
+		ExpiresAt:        req.ExpiresAt,
+		CreatedBy:        adminID,
+		CreatedAt:        time.Now(),
+	}
+	if err := h.accountRepo.CreateKey(key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	writeAudit(h.auditRepo, c, domain.AuditAPIKeyCreated, &adminID, "service_account", account.ID.String(), gin.H{
+		"key_id":     key.ID,
+		"prefix":     key.Prefix,
+		"scopes":     req.Scopes,
+		"expires_at": key.ExpiresAt,
+	})
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{Key: raw, APIKey: toAPIKeyResponse(key)})
+}
+
+// RevokeAPIKey - Revoca una key; deja de aceptarse de inmediato
+func (h *ServiceAccountHandler) RevokeAPIKey(c *gin.Context) {
+	account, ok := h.accountFromParam(c)
+	if !ok {
+		return
+	}
+	keyID, err := uuid.Parse(c.Param("keyId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	if err := h.accountRepo.RevokeKey(account.ID, keyID, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	writeAudit(h.auditRepo, c, domain.AuditAPIKeyRevoked, &adminID, "service_account", account.ID.String(), gin.H{
+		"key_id": keyID,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+// authenticate valida la API key y carga la cuenta de servicio como principal;
+// si falla responde 401 y aborta
+func (h *ServiceAccountHandler) authenticate(c *gin.Context, raw string) bool {
+	now := time.Now()
+	key, err := h.accountRepo.GetKeyByHash(hashToken(raw))
+	if err != nil || !key.Usable(now) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		return false
+	}
+
+	account, err := h.accountRepo.GetByID(key.ServiceAccountID)
+	if err != nil || !account.IsActive {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Service account is inactive"})
+		return false
+	}
+
+	if err := h.accountRepo.TouchKey(key.ID, now, c.ClientIP(), apiKeyTouchInterval); err != nil {
+		log.Printf("Error updating last use of API key %s: %v", key.Prefix, err)
+	}
+
+	c.Set("user_id", account.ID)
+	c.Set("principal_type", domain.PrincipalServiceAccount)
+	c.Set("api_key_id", key.ID)
+	c.Set("api_key_prefix", key.Prefix)
+	c.Set("api_key_scopes", key.ScopeList())
+	return true
+}
+
+// auditRequest registra las peticiones que modifican datos hechas con una API key
+func (h *ServiceAccountHandler) auditRequest(c *gin.Context) {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	}
+
+	writeAudit(h.auditRepo, c, domain.AuditServiceAccountRequest, nil, "", "", gin.H{
+		"method":     c.Request.Method,
+		"path":       c.Request.URL.Path,
+		"key_prefix": c.GetString("api_key_prefix"),
+		"status":     c.Writer.Status(),
+	})
+}
+
+func (h *ServiceAccountHandler) accountFromParam(c *gin.Context) (*domain.ServiceAccount, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service account ID"})
+		return nil, false
+	}
+	account, err := h.accountRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service account not found"})
+		return nil, false
+	}
+	return account, true
+}
+
+// apiKeyFromRequest extrae la API key de X-API-Key o de un bearer con prefijo vk_
+func apiKeyFromRequest(c *gin.Context) (string, bool) {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key, true
+	}
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if strings.HasPrefix(token, apiKeyPrefix) {
+		return token, true
+	}
+	return "", false
+}
+
+// generateAPIKey devuelve la key completa (vk_<prefix>_<secreto>) y su prefijo
+func generateAPIKey() (string, string, error) {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	prefix := hex.EncodeToString(id)
+	return apiKeyPrefix + prefix + "_" + secret, prefix, nil
+}
+
+func toServiceAccountResponse(account *domain.ServiceAccount) ServiceAccountResponse {
+	keys := make([]APIKeyResponse, 0, len(account.APIKeys))
+	for i := range account.APIKeys {
+		keys = append(keys, toAPIKeyResponse(&account.APIKeys[i]))
+	}
+	return ServiceAccountResponse{
+		ID:          account.ID,
+		Name:        account.Name,
+		Description: account.Description,
+		IsActive:    account.IsActive,
+		CreatedBy:   account.CreatedBy,
+		CreatedAt:   account.CreatedAt,
+		APIKeys:     keys,
+	}
+}
+
+func toAPIKeyResponse(key *domain.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     apiKeyPrefix + key.Prefix,
+		Scopes:     key.ScopeList(),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+		CreatedAt:  key.CreatedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// sessionTouchInterval limita cada cuánto se escribe la última actividad de una sesión
+const sessionTouchInterval = time.Minute
+
+// introspectionRoute la llaman los otros servicios, no el navegador: no cuenta
+// como actividad de la sesión ni su IP es la del usuario
+const introspectionRoute = "/api/auth/introspect"
+
+// SessionResponse es una sesión tal como la ve el usuario; Current marca la del
+// access token con el que se hace la petición
+type SessionResponse struct {
+	ID             uuid.UUID `json:"id"`
+	Device         string    `json:"device"`
+	IPAddress      string    `json:"ip_address"`
+	UserAgent      string    `json:"user_agent"`
+	CreatedAt      time.Time `json:"created_at"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	Current        bool      `json:"current"`
+}
+
+// ListSessions - Sesiones abiertas del usuario autenticado
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	h.respondSessions(c, userID)
+}
+
+// RevokeSession - Cierra una de las sesiones del usuario (puede ser la actual)
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+	if err := h.tokenRepo.RevokeSession(userID, sessionID); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeAllSessions - Cierra todas las sesiones del usuario ("cerrar sesión en
+// todas partes"). Con ?except_current=true se mantiene la sesión actual.
+func (h *AuthHandler) RevokeAllSessions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	keep := uuid.Nil
+	if c.Query("except_current") == "true" {
+		if current, ok := c.Get("session_id"); ok {
+			keep = current.(uuid.UUID)
+		}
+	}
+
+	revoked, err := h.tokenRepo.RevokeOtherSessions(userID, keep)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully", "revoked": revoked})
+}
+
+// ListUserSessions - Sesiones abiertas de cualquier usuario (solo admin)
+func (h *AuthHandler) ListUserSessions(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if _, err := h.userRepo.GetByID(userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	h.respondSessions(c, userID)
+}
+
+// TerminateUserSessions - Cierra todas las sesiones de un usuario, p. ej. cuando
+// un empleado deja la clínica (solo admin)
+func (h *AuthHandler) TerminateUserSessions(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	if _, err := h.userRepo.GetByID(userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	revoked, err := h.tokenRepo.RevokeOtherSessions(userID, uuid.Nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	log.Printf("All sessions of user %s terminated by admin %s", userID, adminID)
+	h.guard.audit(c, domain.AuditSessionsTerminated, &adminID, userID.String(), gin.H{"revoked": revoked})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully", "revoked": revoked})
+}
+
+// TerminateUserSession - Cierra una sesión concreta de un usuario (solo admin)
+func (h *AuthHandler) TerminateUserSession(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if err := h.tokenRepo.RevokeSession(userID, sessionID); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	log.Printf("Session %s of user %s terminated by admin %s", sessionID, userID, adminID)
+	h.guard.audit(c, domain.AuditSessionRevoked, &adminID, userID.String(), gin.H{"session_id": sessionID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+func (h *AuthHandler) respondSessions(c *gin.Context, userID uuid.UUID) {
+	sessions, err := h.tokenRepo.ListActiveSessions(userID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	// Solo se marca la sesión actual cuando el usuario consulta las suyas
+	var current uuid.UUID
+	if actor, ok := c.Get("user_id"); ok && actor.(uuid.UUID) == userID {
+		if sid, ok := c.Get("session_id"); ok {
+			current = sid.(uuid.UUID)
+		}
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			ID:             session.ID,
+			Device:         session.Device,
+			IPAddress:      session.IPAddress,
+			UserAgent:      session.UserAgent,
+			CreatedAt:      session.CreatedAt,
+			LastActivityAt: session.LastActivityAt,
+			ExpiresAt:      session.ExpiresAt,
+			Current:        session.ID == current,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// IntrospectToken - Responde 200 si el access token del header sigue vigente:
+// firma, jti y sesión sin revocar (authenticate), suplantación aún válida y
+// usuario activo. api-gateway, call-service y queue-service solo comprueban la
+// firma y consultan aquí las revocaciones (shared/tokenstatus).
+func (h *AuthHandler) IntrospectToken(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	user, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User no longer exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check token"})
+		return
+	}
+	if !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User is no longer active"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"active": true})
+}
+
+// touchSession actualiza la última actividad y la IP de la sesión; un fallo no
+// debe cortar la petición
+func (h *AuthHandler) touchSession(c *gin.Context, sessionID uuid.UUID) {
+	if err := h.tokenRepo.TouchSession(sessionID, time.Now(), c.ClientIP(), sessionTouchInterval); err != nil {
+		log.Printf("Error updating activity of session %s: %v", sessionID, err)
+	}
+}
+
+// deviceFromUserAgent resume el user agent en "<navegador> on <sistema>" para
+// mostrarlo en el listado de sesiones
+func deviceFromUserAgent(userAgent string) string {
+	fields := strings.Fields(userAgent)
+	if len(fields) == 0 {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	system := "Unknown OS"
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		system = "Android"
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		system = "iOS"
+	case strings.Contains(userAgent, "Windows"):
+		system = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"):
+		system = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		system = "Linux"
+	}
+
+	if browser == "Unknown browser" && system == "Unknown OS" {
+		// Clientes no navegador (curl, apps): se muestra el producto tal cual
+		if len(fields[0]) <= 100 {
+			return fields[0]
+		}
+		return "Unknown device"
+	}
+	return browser + " on " + system
+}
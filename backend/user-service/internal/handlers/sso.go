@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+	"user-service/internal/sso"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	ssoStateTTL    = 10 * time.Minute
+	ssoExchangeTTL = time.Minute
+	ssoLinkTTL     = 24 * time.Hour
+
+	// ssoStateCookie liga el state al navegador que inició el login
+	ssoStateCookie = "vincula_sso_state"
+	ssoCookiePath  = "/api/auth/oidc"
+)
+
+var (
+	errSSOAccountInactive = errors.New("account is inactive")
+	errSSOLinkRequired    = errors.New("linking this account requires admin approval")
+	errSSOAccountDeleted  = errors.New("account is deleted")
+)
+
+// SSOHandler implementa el login del personal de las clínicas con su proveedor
+// OIDC (authorization code + PKCE). El callback no entrega tokens en la URL:
+// redirige al frontend con un código de un solo uso que se canjea por la sesión
+// en /api/auth/oidc/exchange, donde se aplica el mismo MFA que al login normal.
+type SSOHandler struct {
+	client        *sso.Client
+	ssoRepo       repository.SSORepository
+	userRepo      repository.UserRepository
+	userTokenRepo repository.UserTokenRepository
+	auth          *AuthHandler
+}
+
+type SSOConfigResponse struct {
+	Enabled bool   `json:"enabled"`
+	Name    string `json:"name,omitempty"`
+}
+
+type SSOExchangeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// NewSSOHandler admite client nil: el SSO queda desactivado y sus rutas responden 404
+func NewSSOHandler(client *sso.Client, ssoRepo repository.SSORepository, userRepo repository.UserRepository, userTokenRepo repository.UserTokenRepository, auth *AuthHandler) *SSOHandler {
+	return &SSOHandler{
+		client:        client,
+		ssoRepo:       ssoRepo,
+		userRepo:      userRepo,
+		userTokenRepo: userTokenRepo,
+		auth:          auth,
+	}
+}
+
+// GetConfig - Indica al frontend si debe mostrar el botón de SSO
+func (h *SSOHandler) GetConfig(c *gin.Context) {
+	if h.client == nil {
+		c.JSON(http.StatusOK, SSOConfigResponse{Enabled: false})
+		return
+	}
+	c.JSON(http.StatusOK, SSOConfigResponse{Enabled: true, Name: h.client.DisplayName()})
+}
+
+// Login - Guarda state, nonce y verificador PKCE y redirige al proveedor
+func (h *SSOHandler) Login(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+	nonce, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+	// 32 bytes en base64url son 43 caracteres, la longitud mínima de un verificador PKCE
+	verifier, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+
+	now := time.Now()
+	loginState := &domain.OIDCLoginState{
+		ID:           uuid.New(),
+		StateHash:    hashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		RedirectPath: safeRedirectPath(c.Query("redirect")),
+		ExpiresAt:    now.Add(ssoStateTTL),
+		CreatedAt:    now,
+	}
+	if err := h.ssoRepo.CreateState(loginState); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start single sign-on"})
+		return
+	}
+
+	authURL, err := h.client.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("Error building SSO authorization URL: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+
+	// SameSite=Lax: la cookie tiene que viajar en la redirección del IdP al callback
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoStateCookie, state, int(ssoStateTTL.Seconds()), ssoCookiePath, "", h.client.SecureCallback(), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback - Recibe el código del proveedor, resuelve (o crea) el usuario y
+// devuelve al frontend un código de canje de un minuto
+func (h *SSOHandler) Callback(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	browserState, _ := c.Cookie(ssoStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoStateCookie, "", -1, ssoCookiePath, "", h.client.SecureCallback(), true)
+
+	if idpError := c.Query("error"); idpError != "" {
+		log.Printf("SSO login rejected by identity provider: %s %s", idpError, c.Query("error_description"))
+		h.redirectError(c, "access_denied")
+		return
+	}
+
+	// Un state válido pero iniciado en otro navegador es un intento de login CSRF
+	state := c.Query("state")
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		log.Printf("SSO callback rejected: state does not match the browser that started the login")
+		h.redirectError(c, "invalid_state")
+		return
+	}
+
+	loginState, err := h.ssoRepo.ConsumeState(hashToken(state))
+	if err != nil {
+		h.redirectError(c, "invalid_state")
+		return
+	}
+
+	identity, err := h.client.Exchange(c.Request.Context(), c.Query("code"), loginState.Nonce, loginState.CodeVerifier)
+	if err != nil {
+		log.Printf("SSO code exchange failed: %v", err)
+		switch {
+		case errors.Is(err, sso.ErrEmailNotVerified):
+			h.redirectError(c, "email_not_verified")
+		case errors.Is(err, sso.ErrDomainNotAllowed):
+			h.redirectError(c, "domain_not_allowed")
+		default:
+			h.redirectError(c, "sso_failed")
+		}
+		return
+	}
+
+	// Sin ningún grupo mapeado no hay rol que asignar: se deniega el acceso
+	role, ok := h.client.RoleFor(identity.Groups)
+	if !ok {
+		log.Printf("SSO login denied for %s: no role mapped from groups %v", identity.Email, identity.Groups)
+		h.redirectError(c, "no_role")
+		return
+	}
+
+	user, err := h.resolveUser(c, identity, role)
+	if err != nil {
+		log.Printf("Error resolving SSO user %s: %v", identity.Email, err)
+		switch {
+		case errors.Is(err, errSSOAccountInactive):
+			h.redirectError(c, "account_inactive")
+			return
+		case errors.Is(err, errSSOLinkRequired):
+			h.redirectError(c, "link_required")
+			return
+		case errors.Is(err, errSSOAccountDeleted):
+			h.redirectError(c, "account_deleted")
+			return
+		}
+		h.redirectError(c, "sso_failed")
+		return
+	}
+
+	code, err := h.createExchangeCode(user)
+	if err != nil {
+		log.Printf("Error creating SSO exchange code for user %s: %v", user.ID, err)
+		h.redirectError(c, "sso_failed")
+		return
+	}
+
+	// El código va en el fragmento para que no llegue a logs ni cabeceras Referer
+	fragment := url.Values{"code": {code}, "redirect": {loginState.RedirectPath}}
+	c.Redirect(http.StatusFound, frontendURL()+"/sso/callback#"+fragment.Encode())
+}
+
+// Exchange - Canjea el código del callback por la sesión (o el reto MFA)
+func (h *SSOHandler) Exchange(c *gin.Context) {
+	var req SSOExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := h.userTokenRepo.Consume(hashToken(req.Code), domain.PurposeSSOExchange)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserTokenInvalid) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate code"})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(token.UserID)
+	if err != nil || !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is inactive"})
+		return
+	}
+
+	// Una cuenta bloqueada por fuerza bruta tampoco entra por SSO
+	if !h.auth.guard.Allow(c, user) {
+		return
+	}
+
+	h.auth.completeLogin(c, user)
+}
+
+// ApproveLink - Autoriza que el próximo login SSO con el email de una cuenta de
+// personal la vincule; sin esto el callback lo rechaza con link_required
+func (h *SSOHandler) ApproveLink(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// El token no se entrega a nadie: lo gasta el callback (ConsumeForUser)
+	raw, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve SSO link"})
+		return
+	}
+	now := time.Now()
+	token := &domain.UserToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Purpose:   domain.PurposeSSOLink,
+		TokenHash: hashToken(raw),
+		ExpiresAt: now.Add(ssoLinkTTL),
+		CreatedAt: now,
+	}
+	if err := h.userTokenRepo.Create(token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve SSO link"})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	log.Printf("SSO link for user %s approved by admin %s", user.ID, adminID)
+	h.auth.guard.audit(c, domain.AuditSSOLinkApproved, &adminID, user.ID.String(), gin.H{
+		"expires_at": token.ExpiresAt,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "SSO link approved", "expires_at": token.ExpiresAt})
+}
+
+// CleanupExpiredStates borra periódicamente los logins SSO abandonados
+func (h *SSOHandler) CleanupExpiredStates(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := h.ssoRepo.DeleteExpiredStates(time.Now()); err != nil {
+			log.Printf("Error cleaning up expired SSO login states: %v", err)
+		}
+	}
+}
+
+// resolveUser busca la cuenta por identidad externa; si no existe la vincula
+// por email verificado (authorizeLink) o la aprovisiona. El rol solo se
+// sincroniza con los grupos del IdP en las cuentas aprovisionadas por el SSO:
+// las cuentas locales vinculadas conservan el suyo.
+func (h *SSOHandler) resolveUser(c *gin.Context, identity *sso.Identity, role domain.UserRole) (*domain.User, error) {
+	now := time.Now()
+
+	var user *domain.User
+	provisioned := false
+	if external, _ := h.ssoRepo.GetIdentity(identity.Issuer, identity.Subject); external != nil {
+		existing, err := h.userRepo.GetByID(external.UserID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errSSOAccountDeleted
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := h.ssoRepo.TouchIdentity(external, identity.Email, now); err != nil {
+			log.Printf("Error updating SSO identity %s: %v", external.ID, err)
+		}
+		user = existing
+		provisioned = external.Provisioned
+	} else if existing, _ := h.userRepo.GetByEmail(identity.Email); existing != nil {
+		if err := h.authorizeLink(c, existing, identity); err != nil {
+			return nil, err
+		}
+		if err := h.ssoRepo.CreateIdentity(newExternalIdentity(existing.ID, identity, now)); err != nil {
+			return nil, err
+		}
+		log.Printf("User %s linked to SSO identity %s", existing.ID, identity.Subject)
+		h.auth.guard.audit(c, domain.AuditSSOAccountLinked, &existing.ID, existing.ID.String(), gin.H{
+			"issuer":  identity.Issuer,
+			"subject": identity.Subject,
+		})
+		user = existing
+	} else {
+		// Un usuario borrado conserva su email (y el índice único) hasta que se
+		// restaure o se suprima: no se aprovisiona otra cuenta encima
+		if taken, err := h.userRepo.EmailTaken(identity.Email); err != nil {
+			return nil, err
+		} else if taken {
+			return nil, errSSOAccountDeleted
+		}
+		user = &domain.User{
+			ID:              uuid.New(),
+			Email:           identity.Email,
+			FirstName:       identity.GivenName,
+			LastName:        identity.FamilyName,
+			Role:            role,
+			IsActive:        true,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+			EmailVerified:   true,
+			EmailVerifiedAt: &now,
+		}
+		if user.FirstName == "" {
+			user.FirstName, _, _ = strings.Cut(identity.Email, "@")
+		}
+		// Sin contraseña: la cuenta solo puede entrar por SSO
+		external := newExternalIdentity(user.ID, identity, now)
+		external.Provisioned = true
+		if err := h.ssoRepo.CreateUserWithIdentity(user, external); err != nil {
+			return nil, err
+		}
+		provisioned = true
+		log.Printf("User %s provisioned via SSO with role %s", user.ID, role)
+		h.auth.guard.audit(c, domain.AuditSSOUserProvisioned, &user.ID, user.ID.String(), gin.H{
+			"issuer":  identity.Issuer,
+			"subject": identity.Subject,
+			"role":    role,
+			"groups":  identity.Groups,
+		})
+	}
+
+	if !user.IsActive {
+		return nil, errSSOAccountInactive
+	}
+
+	changed := false
+	if provisioned && user.Role != role {
+		log.Printf("User %s role synced from SSO groups: %s -> %s", user.ID, user.Role, role)
+		user.Role = role
+		changed = true
+	}
+	if !user.EmailVerified {
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+		changed = true
+	}
+	if changed {
+		user.UpdatedAt = now
+		if err := h.userRepo.Update(user); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// authorizeLink decide si una identidad SSO puede vincularse por email a una
+// cuenta existente. Las de personal necesitan la autorización previa de un
+// admin (ApproveLink): controlar el email en el IdP no basta para heredarlas.
+func (h *SSOHandler) authorizeLink(c *gin.Context, user *domain.User, identity *sso.Identity) error {
+	if user.Role != domain.RoleEmployee && user.Role != domain.RoleAdmin {
+		return nil
+	}
+
+	err := h.userTokenRepo.ConsumeForUser(user.ID, domain.PurposeSSOLink)
+	if errors.Is(err, repository.ErrUserTokenInvalid) {
+		log.Printf("SSO identity %s not linked to %s account %s: no admin approval", identity.Subject, user.Role, user.ID)
+		h.auth.guard.audit(c, domain.AuditSSOLinkRefused, &user.ID, user.ID.String(), gin.H{
+			"issuer":  identity.Issuer,
+			"subject": identity.Subject,
+		})
+		return errSSOLinkRequired
+	}
+	return err
+}
+
+func (h *SSOHandler) createExchangeCode(user *domain.User) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	token := &domain.UserToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Purpose:   domain.PurposeSSOExchange,
+		TokenHash: hashToken(raw),
+		ExpiresAt: now.Add(ssoExchangeTTL),
+		CreatedAt: now,
+	}
+	if err := h.userTokenRepo.Create(token); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (h *SSOHandler) enabled(c *gin.Context) bool {
+	if h.client == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+		return false
+	}
+	return true
+}
+
+func (h *SSOHandler) redirectError(c *gin.Context, reason string) {
+	c.Redirect(http.StatusFound, frontendURL()+"/sso/callback#"+url.Values{"error": {reason}}.Encode())
+}
+
+func newExternalIdentity(userID uuid.UUID, identity *sso.Identity, now time.Time) *domain.ExternalIdentity {
+	return &domain.ExternalIdentity{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Issuer:      identity.Issuer,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		CreatedAt:   now,
+		LastLoginAt: &now,
+	}
+}
+
+// safeRedirectPath solo admite rutas locales del frontend para evitar open redirects
+func safeRedirectPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, "\\") {
+		return "/"
+	}
+	return path
+}
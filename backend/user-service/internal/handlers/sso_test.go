@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/mockoidc"
+	"user-service/internal/repository"
+	"user-service/internal/sso"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const testSSOCallbackURL = "http://vincula.test/api/auth/oidc/callback"
+
+// ssoStore hace de base de datos para los repositorios falsos del flujo SSO
+type ssoStore struct {
+	mu         sync.Mutex
+	users      map[uuid.UUID]*domain.User
+	identities []*domain.ExternalIdentity
+	states     map[string]*domain.OIDCLoginState
+	linkGrants map[uuid.UUID]bool
+	audits     []string
+}
+
+func newSSOStore() *ssoStore {
+	return &ssoStore{
+		users:      map[uuid.UUID]*domain.User{},
+		states:     map[string]*domain.OIDCLoginState{},
+		linkGrants: map[uuid.UUID]bool{},
+	}
+}
+
+type fakeSSORepo struct {
+	repository.SSORepository
+	store *ssoStore
+}
+
+func (r *fakeSSORepo) GetIdentity(issuer, subject string) (*domain.ExternalIdentity, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, identity := range r.store.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return nil, repository.ErrLoginStateInvalid
+}
+
+func (r *fakeSSORepo) CreateIdentity(identity *domain.ExternalIdentity) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.identities = append(r.store.identities, identity)
+	return nil
+}
+
+func (r *fakeSSORepo) CreateUserWithIdentity(user *domain.User, identity *domain.ExternalIdentity) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.users[user.ID] = user
+	r.store.identities = append(r.store.identities, identity)
+	return nil
+}
+
+func (r *fakeSSORepo) TouchIdentity(identity *domain.ExternalIdentity, email string, at time.Time) error {
+	return nil
+}
+
+func (r *fakeSSORepo) CreateState(state *domain.OIDCLoginState) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.states[state.StateHash] = state
+	return nil
+}
+
+func (r *fakeSSORepo) ConsumeState(hash string) (*domain.OIDCLoginState, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	state, ok := r.store.states[hash]
+	if !ok {
+		return nil, repository.ErrLoginStateInvalid
+	}
+	delete(r.store.states, hash)
+	return state, nil
+}
+
+type fakeSSOUserRepo struct {
+	repository.UserRepository
+	store *ssoStore
+}
+
+func (r *fakeSSOUserRepo) GetByID(id uuid.UUID) (*domain.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if user, ok := r.store.users[id]; ok && !user.DeletedAt.Valid {
+		return user, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeSSOUserRepo) GetByEmail(email string) (*domain.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, user := range r.store.users {
+		if user.Email == email && !user.DeletedAt.Valid {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeSSOUserRepo) EmailTaken(email string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, user := range r.store.users {
+		if user.Email == email {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeSSOUserRepo) Update(user *domain.User) error {
+	return nil
+}
+
+type fakeSSOTokenRepo struct {
+	repository.UserTokenRepository
+	store *ssoStore
+}
+
+func (r *fakeSSOTokenRepo) Create(token *domain.UserToken) error {
+	if token.Purpose == domain.PurposeSSOLink {
+		r.store.mu.Lock()
+		r.store.linkGrants[token.UserID] = true
+		r.store.mu.Unlock()
+	}
+	return nil
+}
+
+func (r *fakeSSOTokenRepo) ConsumeForUser(userID uuid.UUID, purpose domain.UserTokenPurpose) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if purpose != domain.PurposeSSOLink || !r.store.linkGrants[userID] {
+		return repository.ErrUserTokenInvalid
+	}
+	delete(r.store.linkGrants, userID)
+	return nil
+}
+
+type fakeSSOAuditRepo struct {
+	repository.AuditRepository
+	store *ssoStore
+}
+
+func (r *fakeSSOAuditRepo) Create(entry *domain.AuditLog) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.audits = append(r.store.audits, entry.Action)
+	return nil
+}
+
+// newTestSSO arranca el proveedor de cmd/mock-oidc y un router con las rutas SSO
+func newTestSSO(t *testing.T) (*gin.Engine, *ssoStore, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	var provider *mockoidc.Server
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provider.Handler().ServeHTTP(w, r)
+	}))
+	t.Cleanup(idp.Close)
+
+	var err error
+	provider, err = mockoidc.New(idp.URL, "vincula", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := sso.NewClient(sso.Config{
+		IssuerURL:   idp.URL,
+		ClientID:    "vincula",
+		RedirectURL: testSSOCallbackURL,
+		Scopes:      []string{"openid", "email", "profile", "groups"},
+		GroupsClaim: "groups",
+		RoleMapping: map[string]domain.UserRole{
+			"clinic-staff":  domain.RoleEmployee,
+			"clinic-admins": domain.RoleAdmin,
+		},
+	})
+
+	store := newSSOStore()
+	auth := &AuthHandler{guard: &LoginGuard{auditRepo: &fakeSSOAuditRepo{store: store}}}
+	h := NewSSOHandler(client, &fakeSSORepo{store: store}, &fakeSSOUserRepo{store: store}, &fakeSSOTokenRepo{store: store}, auth)
+
+	r := gin.New()
+	r.GET("/api/auth/oidc/login", h.Login)
+	r.GET("/api/auth/oidc/callback", h.Callback)
+	r.POST("/api/admin/users/:id/sso-link", func(c *gin.Context) {
+		c.Set("user_id", uuid.New())
+	}, h.ApproveLink)
+	return r, store, idp.URL
+}
+
+// startSSOLogin hace el login en Vincula y el formulario del IdP; devuelve la
+// cookie de state y la URL del callback a la que redirige el IdP
+func startSSOLogin(t *testing.T, r *gin.Engine, email, groups string) (*http.Cookie, string) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login status = %d, body %s", w.Code, w.Body.String())
+	}
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == ssoStateCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("login did not set an HttpOnly SameSite=Lax state cookie: %+v", cookie)
+	}
+
+	form := url.Values{
+		"email":          {email},
+		"given_name":     {"Ana"},
+		"family_name":    {"Pérez"},
+		"groups":         {groups},
+		"email_verified": {"true"},
+	}
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noRedirect.PostForm(w.Header().Get("Location"), form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d", resp.StatusCode)
+	}
+	return cookie, resp.Header.Get("Location")
+}
+
+// finishSSOLogin entrega el callback y devuelve el fragmento de la redirección al frontend
+func finishSSOLogin(t *testing.T, r *gin.Engine, callback string, cookie *http.Cookie) url.Values {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, callback, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("callback status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	_, fragment, _ := strings.Cut(w.Header().Get("Location"), "#")
+	values, err := url.ParseQuery(fragment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func addSSOUser(store *ssoStore, email string, role domain.UserRole) *domain.User {
+	user := &domain.User{ID: uuid.New(), Email: email, Role: role, IsActive: true, PasswordHash: "hash"}
+	store.users[user.ID] = user
+	return user
+}
+
+func TestSSOLoginFlow(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(r *gin.Engine, store *ssoStore, issuer string)
+		groups    string
+		wantError string
+		wantRole  domain.UserRole
+		wantAudit string
+	}{
+		{
+			name:      "aprovisiona una cuenta nueva",
+			groups:    "clinic-staff",
+			wantRole:  domain.RoleEmployee,
+			wantAudit: domain.AuditSSOUserProvisioned,
+		},
+		{
+			name: "sincroniza el rol de una cuenta aprovisionada",
+			setup: func(r *gin.Engine, store *ssoStore, issuer string) {
+				user := addSSOUser(store, "ana@clinica.local", domain.RoleEmployee)
+				store.identities = append(store.identities, &domain.ExternalIdentity{
+					UserID: user.ID, Issuer: issuer, Subject: "mock|ana@clinica.local", Provisioned: true,
+				})
+			},
+			groups:   "clinic-admins",
+			wantRole: domain.RoleAdmin,
+		},
+		{
+			name: "vincula una cuenta de paciente sin cambiar su rol",
+			setup: func(r *gin.Engine, store *ssoStore, issuer string) {
+				addSSOUser(store, "ana@clinica.local", domain.RolePatient)
+			},
+			groups:    "clinic-admins",
+			wantRole:  domain.RolePatient,
+			wantAudit: domain.AuditSSOAccountLinked,
+		},
+		{
+			name: "cuenta de personal sin autorización de un admin",
+			setup: func(r *gin.Engine, store *ssoStore, issuer string) {
+				addSSOUser(store, "ana@clinica.local", domain.RoleAdmin)
+			},
+			groups:    "clinic-admins",
+			wantError: "link_required",
+			wantRole:  domain.RoleAdmin,
+			wantAudit: domain.AuditSSOLinkRefused,
+		},
+		{
+			name: "cuenta de personal autorizada conserva su rol",
+			setup: func(r *gin.Engine, store *ssoStore, issuer string) {
+				user := addSSOUser(store, "ana@clinica.local", domain.RoleEmployee)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/users/"+user.ID.String()+"/sso-link", nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("approve link status = %d", w.Code)
+				}
+			},
+			groups:    "clinic-admins",
+			wantRole:  domain.RoleEmployee,
+			wantAudit: domain.AuditSSOAccountLinked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, store, issuer := newTestSSO(t)
+			if tt.setup != nil {
+				tt.setup(r, store, issuer)
+			}
+			cookie, callback := startSSOLogin(t, r, "ana@clinica.local", tt.groups)
+
+			fragment := finishSSOLogin(t, r, callback, cookie)
+
+			if fragment.Get("error") != tt.wantError {
+				t.Fatalf("error = %q, want %q", fragment.Get("error"), tt.wantError)
+			}
+			if tt.wantError == "" && fragment.Get("code") == "" {
+				t.Fatal("callback did not return an exchange code")
+			}
+			user, _ := (&fakeSSOUserRepo{store: store}).GetByEmail("ana@clinica.local")
+			if user == nil || user.Role != tt.wantRole {
+				t.Fatalf("user = %+v, want role %s", user, tt.wantRole)
+			}
+			if tt.wantError != "" && len(store.identities) != 0 {
+				t.Error("identity linked despite the error")
+			}
+			if tt.wantAudit != "" && !containsString(store.audits, tt.wantAudit) {
+				t.Errorf("audits = %v, want %s", store.audits, tt.wantAudit)
+			}
+		})
+	}
+}
+
+func TestSSOLoginDeletedAccount(t *testing.T) {
+	tests := []struct {
+		name   string
+		linked bool
+	}{
+		{"email de un usuario borrado", false},
+		{"identidad de un usuario borrado", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, store, issuer := newTestSSO(t)
+			user := addSSOUser(store, "ana@clinica.local", domain.RoleEmployee)
+			user.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+			if tt.linked {
+				store.identities = append(store.identities, &domain.ExternalIdentity{
+					UserID: user.ID, Issuer: issuer, Subject: "mock|ana@clinica.local", Provisioned: true,
+				})
+			}
+			cookie, callback := startSSOLogin(t, r, "ana@clinica.local", "clinic-staff")
+
+			fragment := finishSSOLogin(t, r, callback, cookie)
+
+			if fragment.Get("error") != "account_deleted" {
+				t.Fatalf("error = %q, want account_deleted", fragment.Get("error"))
+			}
+			if len(store.users) != 1 {
+				t.Error("provisioned a new account over the deleted user's email")
+			}
+		})
+	}
+}
+
+func TestSSOCallbackRequiresStateCookie(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie func(own *http.Cookie, other *http.Cookie) *http.Cookie
+	}{
+		{"sin cookie", func(own, other *http.Cookie) *http.Cookie { return nil }},
+		{"cookie de otro login", func(own, other *http.Cookie) *http.Cookie { return other }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, store, _ := newTestSSO(t)
+			// La víctima recibe el callback del login que inició el atacante
+			attacker, callback := startSSOLogin(t, r, "atacante@clinica.local", "clinic-staff")
+			victim, _ := startSSOLogin(t, r, "ana@clinica.local", "clinic-staff")
+
+			fragment := finishSSOLogin(t, r, callback, tt.cookie(attacker, victim))
+
+			if fragment.Get("error") != "invalid_state" {
+				t.Fatalf("error = %q, want invalid_state", fragment.Get("error"))
+			}
+			if len(store.users) != 0 {
+				t.Error("user provisioned from a callback without the matching state cookie")
+			}
+		})
+	}
+}
+
+func containsString(values []string, want string) bool {
+	for _, value := range values {
+		if value == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Refresh - Rota el refresh token y emite un nuevo access token
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	current, err := h.tokenRepo.GetRefreshTokenByHash(hashToken(req.RefreshToken))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	// Un token ya rotado o revocado solo puede llegar si se filtró: se revoca toda la familia
+	if current.UsedAt != nil || current.RevokedAt != nil {
+		h.revokeFamilyOnReuse(current)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected"})
+		return
+	}
+
+	if time.Now().After(current.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token expired"})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(current.UserID)
+	if err != nil || !user.IsActive {
+		h.tokenRepo.RevokeFamily(current.FamilyID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is inactive"})
+		return
+	}
+
+	next, refreshToken, err := h.newRefreshToken(user, current.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	if err := h.tokenRepo.RotateRefreshToken(current, next, newSession(c, user, next)); err != nil {
+		if errors.Is(err, repository.ErrTokenAlreadyUsed) {
+			h.revokeFamilyOnReuse(current)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate refresh token"})
+		return
+	}
+
+	token, expiresAt, err := h.generateAccessToken(user, current.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{
+		Token:            token,
+		RefreshToken:     refreshToken,
+		User:             *user,
+		ExpiresAt:        expiresAt,
+		RefreshExpiresAt: next.ExpiresAt,
+	})
+}
+
+// Logout - Revoca el access token actual y toda su familia de refresh tokens
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req LogoutRequest
+	// El cuerpo es opcional: la familia se obtiene del claim sid del access token
+	_ = c.ShouldBindJSON(&req)
+
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	if jti := c.GetString("token_jti"); jti != "" {
+		expiresAt, _ := c.Get("token_expires_at")
+		exp, _ := expiresAt.(time.Time)
+		if err := h.tokenRepo.RevokeAccessToken(&domain.RevokedToken{JTI: jti, UserID: userID, ExpiresAt: exp}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+			return
+		}
+	}
+
+	if familyID, ok := c.Get("session_id"); ok {
+		if err := h.tokenRepo.RevokeFamily(familyID.(uuid.UUID)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+			return
+		}
+	}
+
+	if req.RefreshToken != "" {
+		if token, err := h.tokenRepo.GetRefreshTokenByHash(hashToken(req.RefreshToken)); err == nil && token.UserID == userID {
+			h.tokenRepo.RevokeFamily(token.FamilyID)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// issueSession abre una nueva familia de tokens para el usuario (login/registro)
+// y registra la sesión con el dispositivo y la IP de la petición
+func (h *AuthHandler) issueSession(c *gin.Context, user *domain.User) (*AuthResponse, error) {
+	familyID := uuid.New()
+
+	refresh, refreshToken, err := h.newRefreshToken(user, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.tokenRepo.CreateSession(newSession(c, user, refresh), refresh); err != nil {
+		return nil, err
+	}
+
+	token, expiresAt, err := h.generateAccessToken(user, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		Token:            token,
+		RefreshToken:     refreshToken,
+		User:             *user,
+		ExpiresAt:        expiresAt,
+		RefreshExpiresAt: refresh.ExpiresAt,
+	}, nil
+}
+
+// newSession describe la sesión de la familia del token con el dispositivo y la
+// IP de la petición
+func newSession(c *gin.Context, user *domain.User, refresh *domain.RefreshToken) *domain.Session {
+	userAgent := c.Request.UserAgent()
+	return &domain.Session{
+		ID:             refresh.FamilyID,
+		UserID:         user.ID,
+		IPAddress:      c.ClientIP(),
+		UserAgent:      userAgent,
+		Device:         deviceFromUserAgent(userAgent),
+		CreatedAt:      refresh.CreatedAt,
+		LastActivityAt: refresh.CreatedAt,
+		ExpiresAt:      refresh.ExpiresAt,
+	}
+}
+
+func (h *AuthHandler) newRefreshToken(user *domain.User, familyID uuid.UUID) (*domain.RefreshToken, string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &domain.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(h.refreshTTL),
+		CreatedAt: time.Now(),
+	}, raw, nil
+}
+
+// generateAccessToken emite un JWT de vida corta ligado a la familia (sid)
+func (h *AuthHandler) generateAccessToken(user *domain.User, familyID uuid.UUID) (string, time.Time, error) {
+	expiresAt := time.Now().Add(h.accessTTL)
+
+	claims := jwt.MapClaims{
+		"user_id": user.ID.String(),
+		"email":   user.Email,
+		"role":    user.Role,
+		"sid":     familyID.String(),
+		"jti":     uuid.New().String(),
+		"exp":     expiresAt.Unix(),
+		"iat":     time.Now().Unix(),
+	}
+
+	tokenString, err := h.signToken(claims)
+	return tokenString, expiresAt, err
+}
+
+// signToken firma con la clave asimétrica activa (cabecera kid)
+func (h *AuthHandler) signToken(claims jwt.MapClaims) (string, error) {
+	return h.keys.Sign(claims)
+}
+
+func (h *AuthHandler) parseToken(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, h.keys.Keyfunc, jwt.WithValidMethods(h.keys.Methods()))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
+
+func (h *AuthHandler) revokeFamilyOnReuse(token *domain.RefreshToken) {
+	log.Printf("Refresh token reuse detected for user %s, revoking family %s", token.UserID, token.FamilyID)
+	if err := h.tokenRepo.RevokeFamily(token.FamilyID); err != nil {
+		log.Printf("Error revoking token family %s: %v", token.FamilyID, err)
+	}
+}
+
+// CleanupExpiredTokens borra periódicamente tokens expirados
+func (h *AuthHandler) CleanupExpiredTokens(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := h.tokenRepo.DeleteExpired(time.Now()); err != nil {
+			log.Printf("Error cleaning up expired tokens: %v", err)
+		}
+	}
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// durationFromEnv lee una duración (p. ej. "15m") o devuelve el valor por defecto
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s=%q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/keys"
+	"user-service/internal/mfa"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// fakeTokenRepo guarda refresh tokens y sesiones en memoria con la misma
+// semántica de rotación que tokenRepository
+type fakeTokenRepo struct {
+	repository.TokenRepository
+
+	mu       sync.Mutex
+	tokens   map[string]*domain.RefreshToken
+	sessions map[uuid.UUID]*domain.Session
+	revoked  map[string]bool
+	// beforeRotate simula otra petición que rota el mismo token en paralelo
+	beforeRotate func(old *domain.RefreshToken)
+}
+
+func newFakeTokenRepo() *fakeTokenRepo {
+	return &fakeTokenRepo{
+		tokens:   map[string]*domain.RefreshToken{},
+		sessions: map[uuid.UUID]*domain.Session{},
+		revoked:  map[string]bool{},
+	}
+}
+
+func (r *fakeTokenRepo) CreateSession(session *domain.Session, token *domain.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[session.ID] = session
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (r *fakeTokenRepo) GetRefreshTokenByHash(hash string) (*domain.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[hash]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (r *fakeTokenRepo) RotateRefreshToken(old, next *domain.RefreshToken, session *domain.Session) error {
+	if r.beforeRotate != nil {
+		r.beforeRotate(old)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := r.tokens[old.TokenHash]
+	if stored.UsedAt != nil || stored.RevokedAt != nil {
+		return repository.ErrTokenAlreadyUsed
+	}
+	now := time.Now()
+	stored.UsedAt = &now
+	stored.ReplacedByID = &next.ID
+	r.tokens[next.TokenHash] = next
+	if existing, ok := r.sessions[next.FamilyID]; ok {
+		existing.ExpiresAt = next.ExpiresAt
+		existing.LastActivityAt = now
+		existing.IPAddress = session.IPAddress
+	} else {
+		r.sessions[session.ID] = session
+	}
+	return nil
+}
+
+func (r *fakeTokenRepo) RevokeFamily(familyID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	if session, ok := r.sessions[familyID]; ok {
+		session.RevokedAt = &now
+	}
+	return nil
+}
+
+func (r *fakeTokenRepo) ConsumeAccessToken(token *domain.RevokedToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.revoked[token.JTI] {
+		return repository.ErrAccessTokenRevoked
+	}
+	r.revoked[token.JTI] = true
+	return nil
+}
+
+func (r *fakeTokenRepo) IsAccessTokenRevoked(jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.revoked[jti], nil
+}
+
+func (r *fakeTokenRepo) familyRevoked(familyID uuid.UUID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			return false
+		}
+	}
+	return true
+}
+
+type fakeTokenUserRepo struct {
+	repository.UserRepository
+	user *domain.User
+}
+
+func (r *fakeTokenUserRepo) GetByID(id uuid.UUID) (*domain.User, error) {
+	if r.user == nil || r.user.ID != id {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.user, nil
+}
+
+// fakeSigningKeyRepo conserva las claves que genera keys.Manager
+type fakeSigningKeyRepo struct {
+	repository.SigningKeyRepository
+	keys []domain.SigningKey
+}
+
+func (r *fakeSigningKeyRepo) Create(key *domain.SigningKey) error {
+	r.keys = append(r.keys, *key)
+	return nil
+}
+
+func (r *fakeSigningKeyRepo) ListPublished(now time.Time) ([]domain.SigningKey, error) {
+	return r.keys, nil
+}
+
+func (r *fakeSigningKeyRepo) RotateOlderThan(key *domain.SigningKey, retiresAt time.Time) error {
+	return nil
+}
+
+func (r *fakeSigningKeyRepo) DeleteRetired(before time.Time) error {
+	return nil
+}
+
+func newTestAuthHandler(t *testing.T) (*AuthHandler, *fakeTokenRepo, *domain.User) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cipher, err := mfa.NewCipher("test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, err := keys.NewManager(&fakeSigningKeyRepo{}, cipher, keys.AlgorithmEdDSA, 24*time.Hour, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &domain.User{ID: uuid.New(), Email: "ana@clinica.local", Role: domain.RolePatient, IsActive: true}
+	tokenRepo := newFakeTokenRepo()
+	h := &AuthHandler{
+		userRepo:   &fakeTokenUserRepo{user: user},
+		tokenRepo:  tokenRepo,
+		keys:       manager,
+		accessTTL:  defaultAccessTokenTTL,
+		refreshTTL: defaultRefreshTokenTTL,
+	}
+	return h, tokenRepo, user
+}
+
+// login abre una sesión como lo hacen Login y Register
+func login(t *testing.T, h *AuthHandler, user *domain.User) *AuthResponse {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+	session, err := h.issueSession(c, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func refresh(t *testing.T, h *AuthHandler, refreshToken string) (int, AuthResponse) {
+	t.Helper()
+	body, _ := json.Marshal(RefreshRequest{RefreshToken: refreshToken})
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/refresh", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	h.Refresh(c)
+
+	var resp AuthResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestRefreshRotation(t *testing.T) {
+	h, tokenRepo, user := newTestAuthHandler(t)
+	session := login(t, h, user)
+
+	code, rotated := refresh(t, h, session.RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("refresh status = %d", code)
+	}
+	if rotated.RefreshToken == "" || rotated.RefreshToken == session.RefreshToken || rotated.Token == "" {
+		t.Fatal("refresh did not return a new refresh token and access token")
+	}
+
+	old, _ := tokenRepo.GetRefreshTokenByHash(hashToken(session.RefreshToken))
+	next, _ := tokenRepo.GetRefreshTokenByHash(hashToken(rotated.RefreshToken))
+	if old.UsedAt == nil || old.ReplacedByID == nil || *old.ReplacedByID != next.ID {
+		t.Errorf("old token not marked as replaced by the new one: %+v", old)
+	}
+	if next.FamilyID != old.FamilyID {
+		t.Errorf("rotated token left the family: %s != %s", next.FamilyID, old.FamilyID)
+	}
+
+	// El access token nuevo sigue ligado a la misma sesión (sid)
+	claims, err := h.parseToken(rotated.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims["sid"] != old.FamilyID.String() {
+		t.Errorf("sid = %v, want %s", claims["sid"], old.FamilyID)
+	}
+
+	if code, _ := refresh(t, h, rotated.RefreshToken); code != http.StatusOK {
+		t.Errorf("second rotation status = %d", code)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	tests := []struct {
+		name string
+		// reuse presenta un token ya usado; devuelve el token vigente de la familia
+		reuse func(t *testing.T, h *AuthHandler, tokenRepo *fakeTokenRepo, first string) (code int, current string)
+	}{
+		{
+			name: "token ya rotado",
+			reuse: func(t *testing.T, h *AuthHandler, tokenRepo *fakeTokenRepo, first string) (int, string) {
+				_, rotated := refresh(t, h, first)
+				code, _ := refresh(t, h, first)
+				return code, rotated.RefreshToken
+			},
+		},
+		{
+			name: "dos rotaciones simultáneas",
+			reuse: func(t *testing.T, h *AuthHandler, tokenRepo *fakeTokenRepo, first string) (int, string) {
+				// Otra petición rota el token entre la lectura y la rotación
+				var current string
+				tokenRepo.beforeRotate = func(old *domain.RefreshToken) {
+					tokenRepo.beforeRotate = nil
+					_, rotated := refresh(t, h, first)
+					current = rotated.RefreshToken
+				}
+				code, _ := refresh(t, h, first)
+				return code, current
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, tokenRepo, user := newTestAuthHandler(t)
+			session := login(t, h, user)
+			first, _ := tokenRepo.GetRefreshTokenByHash(hashToken(session.RefreshToken))
+
+			code, current := tt.reuse(t, h, tokenRepo, session.RefreshToken)
+			if code != http.StatusUnauthorized {
+				t.Fatalf("reuse status = %d, want 401", code)
+			}
+			if !tokenRepo.familyRevoked(first.FamilyID) {
+				t.Fatal("reuse did not revoke the token family")
+			}
+			if tokenRepo.sessions[first.FamilyID].RevokedAt == nil {
+				t.Error("reuse did not close the session")
+			}
+			// El token legítimo más reciente también queda inutilizado
+			if code, _ := refresh(t, h, current); code != http.StatusUnauthorized {
+				t.Errorf("refresh with the latest token after reuse status = %d, want 401", code)
+			}
+		})
+	}
+}
+
+func TestRefreshRejected(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(tokenRepo *fakeTokenRepo, user *domain.User, token *domain.RefreshToken)
+		wantRevoked bool
+	}{
+		{"token desconocido", nil, false},
+		{"token caducado", func(tokenRepo *fakeTokenRepo, user *domain.User, token *domain.RefreshToken) {
+			token.ExpiresAt = time.Now().Add(-time.Minute)
+		}, false},
+		{"cuenta desactivada", func(tokenRepo *fakeTokenRepo, user *domain.User, token *domain.RefreshToken) {
+			user.IsActive = false
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, tokenRepo, user := newTestAuthHandler(t)
+			session := login(t, h, user)
+			token := tokenRepo.tokens[hashToken(session.RefreshToken)]
+
+			presented := "otro-token"
+			if tt.setup != nil {
+				tt.setup(tokenRepo, user, token)
+				presented = session.RefreshToken
+			}
+
+			if code, _ := refresh(t, h, presented); code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want 401", code)
+			}
+			if got := tokenRepo.familyRevoked(token.FamilyID); got != tt.wantRevoked {
+				t.Errorf("family revoked = %v, want %v", got, tt.wantRevoked)
+			}
+		})
+	}
+}
+
+func TestRefreshCreatesMissingSession(t *testing.T) {
+	h, tokenRepo, user := newTestAuthHandler(t)
+	session := login(t, h, user)
+	// Familia abierta antes de existir las sesiones: sin fila en sessions
+	token := tokenRepo.tokens[hashToken(session.RefreshToken)]
+	delete(tokenRepo.sessions, token.FamilyID)
+
+	if code, _ := refresh(t, h, session.RefreshToken); code != http.StatusOK {
+		t.Fatalf("refresh status = %d", code)
+	}
+	created, ok := tokenRepo.sessions[token.FamilyID]
+	if !ok {
+		t.Fatal("refresh did not create the missing session")
+	}
+	if created.UserID != user.ID || created.RevokedAt != nil {
+		t.Errorf("created session = %+v", created)
+	}
+}
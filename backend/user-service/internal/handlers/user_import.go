@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxImportRows y maxImportSize limitan el tamaño de un CSV de importación
+	maxImportRows = 1000
+	maxImportSize = 5 << 20
+)
+
+// importColumns son las columnas admitidas en la cabecera del CSV; las cuatro
+// primeras son obligatorias
+var importColumns = []string{
+	"email", "first_name", "last_name", "role",
+	"medical_record_number", "date_of_birth", "family_of", "relationship_type",
+}
+
+var errImportFile = errors.New("Provide the CSV as a multipart \"file\" field or a text/csv body")
+
+// UserImportHandler da de alta cuentas de pacientes y familiares a partir de un
+// CSV. Las cuentas se crean sin contraseña y cada usuario recibe un enlace para
+// elegirla.
+type UserImportHandler struct {
+	importRepo repository.ImportRepository
+	userRepo   repository.UserRepository
+	auditRepo  repository.AuditRepository
+	account    *AccountHandler
+}
+
+// ImportRowError son los errores de validación de una fila; Line es la línea
+// del CSV (la cabecera es la 1)
+type ImportRowError struct {
+	Line   int      `json:"line"`
+	Email  string   `json:"email,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+// ImportedUser es una cuenta creada por la importación
+type ImportedUser struct {
+	Line         int             `json:"line"`
+	ID           uuid.UUID       `json:"id"`
+	Email        string          `json:"email"`
+	Role         domain.UserRole `json:"role"`
+	PatientID    *uuid.UUID      `json:"patient_id,omitempty"`
+	FamilyLinkID *uuid.UUID      `json:"family_link_id,omitempty"`
+}
+
+// ImportReport es el resultado de validar (y, si no es dry_run, crear) el CSV
+type ImportReport struct {
+	DryRun      bool             `json:"dry_run"`
+	Rows        int              `json:"rows"`
+	Valid       int              `json:"valid"`
+	Created     int              `json:"created"`
+	Errors      []ImportRowError `json:"errors"`
+	Users       []ImportedUser   `json:"users,omitempty"`
+	FamilyLinks int              `json:"family_links"`
+}
+
+// csvRecord es una fila del CSV con sus valores por columna
+type csvRecord struct {
+	line   int
+	values map[string]string
+}
+
+// importRow es una fila del CSV ya normalizada
+type importRow struct {
+	line             int
+	email            string
+	firstName        string
+	lastName         string
+	role             domain.UserRole
+	mrn              string
+	dateOfBirth      *domain.Date
+	familyOf         string
+	relationshipType string
+}
+
+func NewUserImportHandler(importRepo repository.ImportRepository, userRepo repository.UserRepository, auditRepo repository.AuditRepository, account *AccountHandler) *UserImportHandler {
+	return &UserImportHandler{
+		importRepo: importRepo,
+		userRepo:   userRepo,
+		auditRepo:  auditRepo,
+		account:    account,
+	}
+}
+
+// ImportUsers - Importa cuentas de pacientes y familiares desde un CSV (solo
+// admin). Valida todas las filas antes de crear nada: con dry_run=true solo
+// devuelve el informe; si alguna fila tiene errores responde 422 sin crear
+// ninguna cuenta. Los vínculos familiares quedan pendientes de que el paciente
+// los apruebe, como cuando los solicita el familiar.
+func (h *UserImportHandler) ImportUsers(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true"
+
+	rows, err := h.readCSV(c)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("CSV exceeds %d bytes", maxImportSize)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := &ImportReport{DryRun: dryRun, Rows: len(rows), Errors: []ImportRowError{}}
+	batch, err := h.validate(rows, report)
+	if err != nil {
+		log.Printf("Error validating user import: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate import"})
+		return
+	}
+
+	if len(report.Errors) > 0 {
+		status := http.StatusUnprocessableEntity
+		if dryRun {
+			status = http.StatusOK
+		}
+		report.Users = nil
+		c.JSON(status, report)
+		return
+	}
+	if dryRun {
+		// Los ids solo se asignan al crear las cuentas
+		report.Users = nil
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	if err := h.importRepo.Create(batch); err != nil {
+		if errors.Is(err, repository.ErrImportConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Some emails or medical record numbers were registered meanwhile, validate the file again"})
+			return
+		}
+		log.Printf("Error creating user import: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import users"})
+		return
+	}
+	report.Created = len(batch.Users)
+
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	log.Printf("Admin %s imported %d users", adminID, report.Created)
+	writeAudit(h.auditRepo, c, domain.AuditUsersImported, &adminID, "user", "", gin.H{
+		"users":        report.Created,
+		"patients":     len(batch.Patients),
+		"family_links": len(batch.FamilyLinks),
+	})
+
+	// Los enlaces para elegir contraseña se envían en segundo plano; si alguno
+	// falla el usuario puede pedir otro con "He olvidado mi contraseña"
+	users := batch.Users
+	go func() {
+		for i := range users {
+			if err := h.account.SendAccountSetup(&users[i]); err != nil {
+				log.Printf("Error sending account setup link to user %s: %v", users[i].ID, err)
+			}
+		}
+	}()
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// readCSV lee el CSV de un campo multipart "file" o del cuerpo de la petición
+// y devuelve sus filas con los valores por columna
+func (h *UserImportHandler) readCSV(c *gin.Context) ([]csvRecord, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, err
+			}
+			return nil, errImportFile
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		body = file
+	} else if c.ContentType() != "text/csv" {
+		return nil, errImportFile
+	}
+
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns, err := importHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []csvRecord
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("CSV exceeds %d rows", maxImportRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := csvRecord{line: line, values: make(map[string]string, len(columns))}
+		for i, column := range columns {
+			row.values[column] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("CSV has no rows")
+	}
+	return rows, nil
+}
+
+// importHeader valida la cabecera: columnas conocidas, sin repetir y con las
+// obligatorias presentes
+func importHeader(header []string) ([]string, error) {
+	known := make(map[string]bool, len(importColumns))
+	for _, column := range importColumns {
+		known[column] = true
+	}
+
+	columns := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		column := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !known[column] {
+			return nil, fmt.Errorf("Unknown column %q", name)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("Duplicated column %q", name)
+		}
+		seen[column] = true
+		columns[i] = column
+	}
+	for _, column := range importColumns[:4] {
+		if !seen[column] {
+			return nil, fmt.Errorf("Missing required column %q", column)
+		}
+	}
+	return columns, nil
+}
+
+// validate comprueba todas las filas y prepara el lote. Los errores de cada
+// fila se acumulan en el informe; solo devuelve error si falla la base de datos.
+func (h *UserImportHandler) validate(records []csvRecord, report *ImportReport) (*repository.UserImport, error) {
+	rows := make([]*importRow, len(records))
+	rowErrors := make([][]string, len(records))
+	byEmail := make(map[string]int, len(records))
+	byMRN := make(map[string]int)
+	var emails, mrns []string
+
+	for i, record := range records {
+		row, errs := parseImportRow(record)
+		rows[i] = row
+		if row.email != "" {
+			if first, ok := byEmail[row.email]; ok {
+				errs = append(errs, fmt.Sprintf("email is duplicated in line %d", rows[first].line))
+			} else {
+				byEmail[row.email] = i
+				emails = append(emails, row.email)
+			}
+		}
+		if row.mrn != "" {
+			if first, ok := byMRN[row.mrn]; ok {
+				errs = append(errs, fmt.Sprintf("medical_record_number is duplicated in line %d", rows[first].line))
+			} else {
+				byMRN[row.mrn] = i
+				mrns = append(mrns, row.mrn)
+			}
+		}
+		rowErrors[i] = errs
+	}
+
+	existingEmails, err := h.importRepo.ExistingEmails(emails)
+	if err != nil {
+		return nil, err
+	}
+	for email, deleted := range existingEmails {
+		i, ok := byEmail[email]
+		if !ok {
+			continue
+		}
+		if deleted {
+			rowErrors[i] = append(rowErrors[i], "email belongs to a deleted user; restore it instead")
+		} else {
+			rowErrors[i] = append(rowErrors[i], "email is already registered")
+		}
+	}
+	existingMRNs, err := h.importRepo.ExistingMRNs(mrns)
+	if err != nil {
+		return nil, err
+	}
+	for _, mrn := range existingMRNs {
+		rowErrors[byMRN[mrn]] = append(rowErrors[byMRN[mrn]], "medical_record_number is already in use")
+	}
+
+	now := time.Now()
+	batch := &repository.UserImport{}
+	users := make([]ImportedUser, len(rows))
+	for i, row := range rows {
+		users[i] = ImportedUser{Line: row.line, ID: uuid.New(), Email: row.email, Role: row.role}
+	}
+
+	for i, row := range rows {
+		if row.familyOf == "" {
+			continue
+		}
+		patientID, err := h.resolvePatient(row.familyOf, rows, users, byEmail)
+		if err != nil {
+			rowErrors[i] = append(rowErrors[i], err.Error())
+			continue
+		}
+		link := domain.FamilyRelationship{
+			ID:               uuid.New(),
+			PatientID:        patientID,
+			FamilyMemberID:   users[i].ID,
+			RelationshipType: row.relationshipType,
+			Status:           domain.FamilyLinkPending,
+			RequestedAt:      now,
+			CreatedAt:        now,
+			UpdatedAt:        now,
+		}
+		batch.FamilyLinks = append(batch.FamilyLinks, link)
+		users[i].FamilyLinkID = &link.ID
+	}
+
+	for i, row := range rows {
+		if len(rowErrors[i]) > 0 {
+			report.Errors = append(report.Errors, ImportRowError{Line: row.line, Email: row.email, Errors: rowErrors[i]})
+			continue
+		}
+		report.Valid++
+		report.Users = append(report.Users, users[i])
+
+		batch.Users = append(batch.Users, domain.User{
+			ID:        users[i].ID,
+			Email:     row.email,
+			FirstName: row.firstName,
+			LastName:  row.lastName,
+			Role:      row.role,
+			IsActive:  true,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		if row.mrn != "" {
+			patient := domain.Patient{
+				ID:                  uuid.New(),
+				UserID:              users[i].ID,
+				MedicalRecordNumber: row.mrn,
+				DateOfBirth:         row.dateOfBirth,
+				CreatedAt:           now,
+				UpdatedAt:           now,
+			}
+			batch.Patients = append(batch.Patients, patient)
+			users[i].PatientID = &patient.ID
+		}
+	}
+	report.FamilyLinks = len(batch.FamilyLinks)
+	return batch, nil
+}
+
+// resolvePatient busca el paciente de family_of primero en el propio CSV y
+// después entre las cuentas existentes
+func (h *UserImportHandler) resolvePatient(email string, rows []*importRow, users []ImportedUser, byEmail map[string]int) (uuid.UUID, error) {
+	if i, ok := byEmail[email]; ok {
+		if rows[i].role != domain.RolePatient {
+			return uuid.Nil, errors.New("family_of must reference a patient")
+		}
+		return users[i].ID, nil
+	}
+
+	patient, err := h.userRepo.GetByEmail(email)
+	if err != nil {
+		return uuid.Nil, errors.New("family_of patient not found")
+	}
+	if patient.Role != domain.RolePatient || !patient.IsActive {
+		return uuid.Nil, errors.New("family_of must reference an active patient")
+	}
+	return patient.ID, nil
+}
+
+// parseImportRow normaliza una fila y devuelve los errores que no dependen del
+// resto del fichero ni de la base de datos
+func parseImportRow(record csvRecord) (*importRow, []string) {
+	var errs []string
+	values := record.values
+	row := &importRow{
+		line:             record.line,
+		email:            domain.NormalizeEmail(values["email"]),
+		firstName:        values["first_name"],
+		lastName:         values["last_name"],
+		role:             domain.UserRole(strings.ToLower(values["role"])),
+		mrn:              values["medical_record_number"],
+		familyOf:         domain.NormalizeEmail(values["family_of"]),
+		relationshipType: strings.ToLower(values["relationship_type"]),
+	}
+	if row.email == "" {
+		errs = append(errs, "email is required")
+	} else if !validEmail(row.email) {
+		errs = append(errs, "email is invalid")
+	}
+	if row.firstName == "" {
+		errs = append(errs, "first_name is required")
+	}
+	if row.lastName == "" {
+		errs = append(errs, "last_name is required")
+	}
+
+	switch row.role {
+	case domain.RolePatient:
+		if row.familyOf != "" {
+			errs = append(errs, "family_of is only allowed for family members")
+		}
+	case domain.RoleFamily:
+		if row.mrn != "" || values["date_of_birth"] != "" {
+			errs = append(errs, "medical_record_number and date_of_birth are only allowed for patients")
+		}
+	default:
+		errs = append(errs, "role must be patient or family")
+	}
+
+	if row.mrn != "" && !mrnPattern.MatchString(row.mrn) {
+		errs = append(errs, "medical_record_number is invalid")
+	}
+	if value := values["date_of_birth"]; value != "" {
+		date, err := domain.ParseDate(value)
+		switch {
+		case err != nil:
+			errs = append(errs, "date_of_birth must be YYYY-MM-DD")
+		case row.mrn == "":
+			errs = append(errs, "date_of_birth requires medical_record_number")
+		default:
+			row.dateOfBirth = &date
+			if err := validatePatient(&domain.Patient{DateOfBirth: row.dateOfBirth}); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if row.familyOf != "" {
+		if row.familyOf == row.email {
+			errs = append(errs, "family_of cannot reference the same user")
+		}
+		if !domain.ValidRelationshipType(row.relationshipType) {
+			errs = append(errs, "relationship_type must be one of "+strings.Join(domain.RelationshipTypes, ", "))
+		}
+	} else if row.relationshipType != "" {
+		errs = append(errs, "relationship_type requires family_of")
+	}
+
+	return row, errs
+}
+
+// validEmail acepta solo una dirección simple, sin nombre ni ángulos
+func validEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
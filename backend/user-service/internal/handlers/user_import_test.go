@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseImportRow(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]string
+		errs   int
+	}{
+		{"paciente con MRN", map[string]string{"email": "Ana@Example.com", "first_name": "Ana", "last_name": "López", "role": "patient", "medical_record_number": "MRN-001", "date_of_birth": "1980-02-01"}, 0},
+		{"familiar vinculado", map[string]string{"email": "luis@example.com", "first_name": "Luis", "last_name": "López", "role": "family", "family_of": "ana@example.com", "relationship_type": "child"}, 0},
+		{"rol de personal", map[string]string{"email": "e@example.com", "first_name": "E", "last_name": "E", "role": "admin"}, 1},
+		{"email inválido y sin nombre", map[string]string{"email": "Ana <ana@example.com>", "last_name": "López", "role": "patient"}, 2},
+		{"fecha sin MRN", map[string]string{"email": "a@example.com", "first_name": "A", "last_name": "A", "role": "patient", "date_of_birth": "1980-02-01"}, 1},
+		{"fecha futura", map[string]string{"email": "a@example.com", "first_name": "A", "last_name": "A", "role": "patient", "medical_record_number": "M1", "date_of_birth": "2999-01-01"}, 1},
+		{"familiar con MRN", map[string]string{"email": "f@example.com", "first_name": "F", "last_name": "F", "role": "family", "medical_record_number": "M2"}, 1},
+		{"parentesco sin paciente", map[string]string{"email": "f@example.com", "first_name": "F", "last_name": "F", "role": "family", "relationship_type": "child"}, 1},
+		{"parentesco desconocido", map[string]string{"email": "f@example.com", "first_name": "F", "last_name": "F", "role": "family", "family_of": "ana@example.com", "relationship_type": "vecino"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, errs := parseImportRow(csvRecord{line: 2, values: tt.values})
+			if len(errs) != tt.errs {
+				t.Errorf("parseImportRow() errors = %v, want %d", errs, tt.errs)
+			}
+			if row.email != strings.ToLower(tt.values["email"]) {
+				t.Errorf("parseImportRow() email = %q, want lowercase", row.email)
+			}
+		})
+	}
+}
+
+func TestImportHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  []string
+		wantErr bool
+	}{
+		{"obligatorias", []string{"email", "first_name", "last_name", "role"}, false},
+		{"con BOM y mayúsculas", []string{"\ufeffEmail", "First_Name", "last_name", "role", "family_of"}, false},
+		{"falta el rol", []string{"email", "first_name", "last_name"}, true},
+		{"columna desconocida", []string{"email", "first_name", "last_name", "role", "password"}, true},
+		{"columna repetida", []string{"email", "first_name", "last_name", "role", "email"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := importHeader(tt.header); (err != nil) != tt.wantErr {
+				t.Errorf("importHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
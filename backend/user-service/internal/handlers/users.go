@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultUsersPageSize = 50
+	maxUsersPageSize     = 200
+)
+
+// parseUserFilter lee los parámetros del listado de usuarios:
+//
+//	role, is_active          filtros exactos
+//	created_from, created_to fecha (2006-01-02, ambos días incluidos) o RFC3339
+//	q                        búsqueda en nombre, apellidos y email
+//	sort                     created_at, email, first_name o last_name; con "-" descendente (por defecto -created_at)
+//	deleted                  true para listar los usuarios borrados (solo admin)
+//	limit, cursor            tamaño de página y X-Next-Cursor de la anterior
+func parseUserFilter(c *gin.Context) (repository.UserFilter, error) {
+	filter := repository.UserFilter{
+		SortBy:     "created_at",
+		Descending: true,
+		Limit:      defaultUsersPageSize,
+		Search:     c.Query("q"),
+		Cursor:     c.Query("cursor"),
+	}
+
+	if role := c.Query("role"); role != "" {
+		if !validRole(domain.UserRole(role)) {
+			return filter, errInvalidRole
+		}
+		filter.Role = domain.UserRole(role)
+	}
+
+	if value := c.Query("is_active"); value != "" {
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid is_active %q", value)
+		}
+		filter.IsActive = &active
+	}
+
+	if value := c.Query("deleted"); value != "" {
+		deleted, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid deleted %q", value)
+		}
+		if principal, ok := principalFrom(c); deleted && (!ok || !principal.Is(domain.RoleAdmin)) {
+			return filter, errForbidden
+		}
+		filter.Deleted = deleted
+	}
+
+	if value := c.Query("created_from"); value != "" {
+		from, _, err := parseDateParam(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid created_from %q", value)
+		}
+		filter.CreatedFrom = &from
+	}
+
+	if value := c.Query("created_to"); value != "" {
+		to, dateOnly, err := parseDateParam(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid created_to %q", value)
+		}
+		// Una fecha sin hora incluye todo ese día
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		filter.CreatedTo = &to
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		filter.Descending = strings.HasPrefix(sort, "-")
+		filter.SortBy = strings.TrimPrefix(sort, "-")
+		valid := false
+		for _, column := range repository.UserSortColumns {
+			valid = valid || column == filter.SortBy
+		}
+		if !valid {
+			return filter, fmt.Errorf("invalid sort %q, expected one of %s", sort, strings.Join(repository.UserSortColumns, ", "))
+		}
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxUsersPageSize {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxUsersPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// parseDateParam acepta una fecha (2006-01-02, en UTC) o un instante RFC3339
+func parseDateParam(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// EraseUserRequest exige el motivo de la supresión, que queda en la auditoría
+type EraseUserRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// RestoreUser - Recupera un usuario borrado (solo admin)
+func (h *AuthHandler) RestoreUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.userRepo.Restore(id); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Deleted user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore user"})
+		return
+	}
+
+	user, err := h.userRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uuid.UUID)
+	h.guard.audit(c, domain.AuditUserRestored, &adminID, id.String(), nil)
+	c.JSON(http.StatusOK, h.toUserResponse(user))
+}
+
+// EraseUser - Derecho de supresión: anonimiza al usuario de forma irreversible,
+// conservando el registro mínimo de sus llamadas (solo admin). Funciona también
+// sobre usuarios ya borrados.
+func (h *AuthHandler) EraseUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req EraseUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	principal, ok := principalFrom(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if err := authorizeUserDelete(principal, id); err != nil {
+		forbidden(c, err)
+		return
+	}
+
+	// Primero se cierran las sesiones para que no quede ningún token en uso
+	if err := h.tokenRepo.RevokeAllForUser(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	if err := h.userRepo.Erase(id, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found or already erased"})
+			return
+		}
+		log.Printf("Error erasing user %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to erase user"})
+		return
+	}
+
+	log.Printf("User %s erased by admin %s", id, principal.UserID)
+	h.guard.audit(c, domain.AuditUserErased, &principal.UserID, id.String(), gin.H{"reason": req.Reason})
+	c.JSON(http.StatusOK, gin.H{"message": "User erased successfully"})
+}
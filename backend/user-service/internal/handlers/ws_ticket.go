@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const wsTicketTTL = 30 * time.Second
+
+// WebSocketTicketHandler canjea el access token por un ticket de un solo uso
+// con el que se abre el WebSocket. La identidad de la conexión sale siempre
+// del ticket, nunca de parámetros enviados por el cliente.
+type WebSocketTicketHandler struct {
+	ticketRepo repository.WebSocketTicketRepository
+	userRepo   repository.UserRepository
+	tokenRepo  repository.TokenRepository
+}
+
+type WebSocketTicketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func NewWebSocketTicketHandler(ticketRepo repository.WebSocketTicketRepository, userRepo repository.UserRepository, tokenRepo repository.TokenRepository) *WebSocketTicketHandler {
+	return &WebSocketTicketHandler{
+		ticketRepo: ticketRepo,
+		userRepo:   userRepo,
+		tokenRepo:  tokenRepo,
+	}
+}
+
+// IssueTicket - Emite un ticket de WebSocket válido durante 30 segundos
+func (h *WebSocketTicketHandler) IssueTicket(c *gin.Context) {
+	raw, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate ticket"})
+		return
+	}
+
+	now := time.Now()
+	ticket := &domain.WebSocketTicket{
+		ID:         uuid.New(),
+		UserID:     c.MustGet("user_id").(uuid.UUID),
+		TicketHash: hashToken(raw),
+		ExpiresAt:  now.Add(wsTicketTTL),
+		CreatedAt:  now,
+	}
+	if sessionID, ok := c.Get("session_id"); ok {
+		sid := sessionID.(uuid.UUID)
+		ticket.SessionID = &sid
+	}
+
+	if err := h.ticketRepo.Create(ticket); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate ticket"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, WebSocketTicketResponse{Ticket: raw, ExpiresAt: ticket.ExpiresAt})
+}
+
+// WebSocketAuthMiddleware consume el ticket de la query y carga la identidad
+// del usuario al que se emitió
+func (h *WebSocketTicketHandler) WebSocketAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.Query("ticket")
+		if raw == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Ticket required"})
+			return
+		}
+
+		ticket, err := h.ticketRepo.Consume(hashToken(raw))
+		if err != nil {
+			if !errors.Is(err, repository.ErrTicketInvalid) {
+				log.Printf("Error consuming websocket ticket: %v", err)
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired ticket"})
+			return
+		}
+
+		// La sesión pudo cerrarse entre la emisión del ticket y la conexión
+		if ticket.SessionID != nil {
+			revoked, err := h.tokenRepo.IsFamilyRevoked(*ticket.SessionID)
+			if err != nil || revoked {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+				return
+			}
+		}
+
+		user, err := h.userRepo.GetByID(ticket.UserID)
+		if err != nil || !user.IsActive {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not found or inactive"})
+			return
+		}
+
+		c.Set("user_id", user.ID.String())
+		c.Set("user_role", string(user.Role))
+		c.Next()
+	}
+}
+
+// CleanupExpiredTickets borra periódicamente los tickets caducados
+func (h *WebSocketTicketHandler) CleanupExpiredTickets(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := h.ticketRepo.DeleteExpired(time.Now()); err != nil {
+			log.Printf("Error cleaning up expired websocket tickets: %v", err)
+		}
+	}
+}
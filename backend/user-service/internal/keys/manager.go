@@ -0,0 +1,387 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/mfa"
+	"user-service/internal/repository"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algoritmos de firma admitidos
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+
+	defaultRotationInterval = 30 * 24 * time.Hour
+	defaultOverlap          = 7 * 24 * time.Hour
+
+	rsaKeyBits = 2048
+	// Un kid desconocido fuerza a releer la base de datos (otra instancia pudo
+	// rotar), como mucho una vez por este intervalo
+	reloadCooldown = 10 * time.Second
+)
+
+var (
+	ErrUnknownKey         = errors.New("unknown signing key")
+	errNoSigningKey       = errors.New("no signing key available")
+	errUnsupportedKeyType = errors.New("unsupported signing key type")
+)
+
+// Manager firma los JWT con la clave activa y publica las claves vigentes.
+//
+// La clave activa es la más reciente sin rotar. Al cumplir rotationInterval
+// se genera otra; la anterior deja de firmar pero sigue publicada durante
+// overlap para que los tokens ya emitidos sigan validando.
+type Manager struct {
+	repo             repository.SigningKeyRepository
+	cipher           *mfa.Cipher
+	algorithm        string
+	rotationInterval time.Duration
+	overlap          time.Duration
+
+	mu         sync.RWMutex
+	current    *signingKey
+	published  map[string]*signingKey
+	lastReload time.Time
+}
+
+type signingKey struct {
+	kid       string
+	algorithm string
+	private   crypto.Signer
+	public    crypto.PublicKey
+	retiresAt *time.Time
+}
+
+func NewManager(repo repository.SigningKeyRepository, cipher *mfa.Cipher, algorithm string, rotationInterval, overlap time.Duration) (*Manager, error) {
+	if algorithm != AlgorithmRS256 && algorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("unsupported JWT signing algorithm %q", algorithm)
+	}
+
+	m := &Manager{
+		repo:             repo,
+		cipher:           cipher,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		overlap:          overlap,
+		published:        make(map[string]*signingKey),
+	}
+	if err := m.Rotate(time.Now()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewFromEnv configura el Manager con JWT_SIGNING_ALG (RS256 por defecto o EdDSA),
+// JWT_KEY_ROTATION_INTERVAL y JWT_KEY_OVERLAP. El solapamiento debe cubrir el
+// token de vida más larga firmado con estas claves (las invitaciones, 72h por defecto).
+func NewFromEnv(repo repository.SigningKeyRepository, cipher *mfa.Cipher) (*Manager, error) {
+	algorithm := os.Getenv("JWT_SIGNING_ALG")
+	if algorithm == "" {
+		algorithm = AlgorithmRS256
+	}
+
+	rotationInterval, err := durationFromEnv("JWT_KEY_ROTATION_INTERVAL", defaultRotationInterval)
+	if err != nil {
+		return nil, err
+	}
+	overlap, err := durationFromEnv("JWT_KEY_OVERLAP", defaultOverlap)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Signing keys: %s, rotating every %s with %s overlap", algorithm, rotationInterval, overlap)
+	return NewManager(repo, cipher, algorithm, rotationInterval, overlap)
+}
+
+// Sign firma los claims con la clave activa e incluye su kid en la cabecera
+func (m *Manager) Sign(claims jwt.MapClaims) (string, error) {
+	m.mu.RLock()
+	key := m.current
+	m.mu.RUnlock()
+	if key == nil {
+		return "", errNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(signingMethod(key.algorithm), claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// Keyfunc resuelve la clave pública por kid para jwt.Parse
+func (m *Manager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrUnknownKey
+	}
+
+	key := m.lookup(kid)
+	if key == nil {
+		if err := m.reloadIfStale(); err != nil {
+			log.Printf("Error reloading signing keys: %v", err)
+		}
+		if key = m.lookup(kid); key == nil {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	if token.Method.Alg() != key.algorithm {
+		return nil, fmt.Errorf("signing method %s does not match key %s", token.Method.Alg(), kid)
+	}
+	return key.public, nil
+}
+
+// Methods son los algoritmos que aceptan los parsers (jwt.WithValidMethods)
+func (m *Manager) Methods() []string {
+	return []string{AlgorithmRS256, AlgorithmEdDSA}
+}
+
+// JWKS devuelve las claves públicas publicadas
+func (m *Manager) JWKS() JWKSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(m.published))}
+	for _, key := range m.published {
+		if jwk, err := newJWK(key.kid, key.algorithm, key.public); err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// Rotate genera una clave nueva si la activa ha cumplido el intervalo (o no hay
+// ninguna), retira las anteriores y recarga las publicadas
+func (m *Manager) Rotate(now time.Time) error {
+	keys, err := m.repo.ListPublished(now)
+	if err != nil {
+		return err
+	}
+
+	var active *domain.SigningKey
+	for i := range keys {
+		if keys[i].RotatedAt == nil {
+			active = &keys[i]
+			break
+		}
+	}
+
+	if m.rotationDue(active, now) {
+		key, err := m.generate(now)
+		if err != nil {
+			return fmt.Errorf("generate signing key: %w", err)
+		}
+		if err := m.repo.Create(key); err != nil {
+			return err
+		}
+		if err := m.repo.RotateOlderThan(key, now.Add(m.overlap)); err != nil {
+			return err
+		}
+		log.Printf("Signing key %s (%s) generated", key.ID, key.Algorithm)
+	}
+
+	if err := m.repo.DeleteRetired(now); err != nil {
+		log.Printf("Error deleting retired signing keys: %v", err)
+	}
+	return m.reload(now)
+}
+
+// rotationDue indica si hay que generar clave: no hay activa, ha cumplido el
+// intervalo, cambió el algoritmo configurado o no se puede descifrar
+func (m *Manager) rotationDue(active *domain.SigningKey, now time.Time) bool {
+	if active == nil || active.Algorithm != m.algorithm || now.Sub(active.CreatedAt) >= m.rotationInterval {
+		return true
+	}
+	if _, err := m.decode(*active); err != nil {
+		log.Printf("Active signing key %s is unusable, rotating: %v", active.ID, err)
+		return true
+	}
+	return false
+}
+
+// RotateKeys comprueba periódicamente si toca rotar y recoge las claves que
+// hayan generado otras instancias
+func (m *Manager) RotateKeys(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if err := m.Rotate(now); err != nil {
+			log.Printf("Error rotating signing keys: %v", err)
+		}
+	}
+}
+
+func (m *Manager) lookup(kid string) *signingKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key := m.published[kid]
+	if key == nil || (key.retiresAt != nil && time.Now().After(*key.retiresAt)) {
+		return nil
+	}
+	return key
+}
+
+func (m *Manager) reloadIfStale() error {
+	m.mu.RLock()
+	stale := time.Since(m.lastReload) >= reloadCooldown
+	m.mu.RUnlock()
+	if !stale {
+		return nil
+	}
+	return m.reload(time.Now())
+}
+
+func (m *Manager) reload(now time.Time) error {
+	keys, err := m.repo.ListPublished(now)
+	if err != nil {
+		return err
+	}
+
+	published := make(map[string]*signingKey, len(keys))
+	var current *signingKey
+	for _, stored := range keys {
+		key, err := m.decode(stored)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", stored.ID, err)
+			continue
+		}
+		published[key.kid] = key
+		// ListPublished ordena de más nueva a más antigua
+		if current == nil && stored.RotatedAt == nil {
+			current = key
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.published = published
+	m.lastReload = now
+	if current != nil {
+		m.current = current
+	}
+	if m.current == nil {
+		return errNoSigningKey
+	}
+	return nil
+}
+
+func (m *Manager) generate(now time.Time) (*domain.SigningKey, error) {
+	var private crypto.Signer
+	switch m.algorithm {
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	default:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := m.cipher.Encrypt(base64.StdEncoding.EncodeToString(privateDER))
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.SigningKey{
+		ID:         keyID(publicDER),
+		Algorithm:  m.algorithm,
+		PrivateKey: encrypted,
+		PublicKey:  base64.StdEncoding.EncodeToString(publicDER),
+		CreatedAt:  now,
+	}, nil
+}
+
+func (m *Manager) decode(stored domain.SigningKey) (*signingKey, error) {
+	plain, err := m.cipher.Decrypt(stored.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	privateDER, err := base64.StdEncoding.DecodeString(plain)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(privateDER)
+	if err != nil {
+		return nil, err
+	}
+
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errUnsupportedKeyType
+	}
+	switch private.(type) {
+	case *rsa.PrivateKey:
+		if stored.Algorithm != AlgorithmRS256 {
+			return nil, errUnsupportedKeyType
+		}
+	case ed25519.PrivateKey:
+		if stored.Algorithm != AlgorithmEdDSA {
+			return nil, errUnsupportedKeyType
+		}
+	default:
+		return nil, errUnsupportedKeyType
+	}
+
+	return &signingKey{
+		kid:       stored.ID,
+		algorithm: stored.Algorithm,
+		private:   private,
+		public:    private.Public(),
+		retiresAt: stored.RetiresAt,
+	}, nil
+}
+
+func signingMethod(algorithm string) jwt.SigningMethod {
+	if algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// keyID deriva el kid de la huella SHA-256 de la clave pública
+func keyID(publicDER []byte) string {
+	sum := sha256.Sum256(publicDER)
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
+func durationFromEnv(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s=%q", key, value)
+	}
+	return d, nil
+}
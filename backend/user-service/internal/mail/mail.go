@@ -0,0 +1,109 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message es un correo de texto plano
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// EmailSender envía correos transaccionales (verificación, recuperación, invitaciones)
+type EmailSender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewFromEnv elige el sender según MAIL_DRIVER: "smtp" o "log" (por defecto)
+func NewFromEnv() EmailSender {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Vincula <no-reply@vincula.local>"
+	}
+
+	switch strings.ToLower(os.Getenv("MAIL_DRIVER")) {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			host = "localhost"
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "1025" // MailHog
+		}
+		log.Printf("Mail: sending through SMTP at %s:%s", host, port)
+		return NewSMTPSender(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from)
+	default:
+		log.Printf("Mail: MAIL_DRIVER is not smtp, emails will only be logged")
+		return NewLogSender()
+	}
+}
+
+// SMTPSender envía por SMTP. Sin credenciales no autentica, como necesita MailHog;
+// con credenciales usa PLAIN, que net/smtp solo permite sobre TLS o localhost.
+type SMTPSender struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPSender(host, port, username, password, from string) *SMTPSender {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPSender{
+		addr: net.JoinHostPort(host, port),
+		host: host,
+		auth: auth,
+		from: from,
+	}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	envelopeFrom := s.from
+	if i := strings.LastIndex(envelopeFrom, "<"); i >= 0 {
+		envelopeFrom = strings.TrimSuffix(envelopeFrom[i+1:], ">")
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", s.from)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	body.WriteString("\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	// net/smtp no admite contexto: se respeta al menos la cancelación previa
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := smtp.SendMail(s.addr, s.auth, envelopeFrom, []string{msg.To}, []byte(body.String())); err != nil {
+		return fmt.Errorf("smtp send to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// LogSender solo registra el correo; pensado para desarrollo
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	log.Printf("Mail (log) to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
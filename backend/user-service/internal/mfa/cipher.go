@@ -0,0 +1,83 @@
+package mfa
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// Cipher cifra secretos en reposo (TOTP y claves privadas de firma) con AES-256-GCM.
+// Cifra siempre con la clave actual; las anteriores solo sirven para descifrar
+// lo guardado antes de rotarla.
+type Cipher struct {
+	aead     cipher.AEAD
+	previous []cipher.AEAD
+}
+
+// NewCipher deriva cada clave AES-256 de su valor (cualquier longitud) con SHA-256
+func NewCipher(key string, previous ...string) (*Cipher, error) {
+	if key == "" {
+		return nil, errors.New("mfa encryption key is empty")
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cipher{aead: aead}
+	for _, old := range previous {
+		if old == "" {
+			continue
+		}
+		aead, err := newAEAD(old)
+		if err != nil {
+			return nil, err
+		}
+		c.previous = append(c.previous, aead)
+	}
+	return c, nil
+}
+
+func newAEAD(key string) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt devuelve nonce||ciphertext en base64
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) Decrypt(encoded string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < c.aead.NonceSize() {
+		return "", errors.New("mfa ciphertext too short")
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	for _, aead := range c.previous {
+		if err == nil {
+			break
+		}
+		plaintext, err = aead.Open(nil, nonce, ciphertext, nil)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,56 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// RecoveryCodeCount es el número de códigos de recuperación por usuario
+const RecoveryCodeCount = 10
+
+// Sin caracteres ambiguos (0/O, 1/I/L) para que se puedan copiar a mano
+const recoveryAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// GenerateRecoveryCodes devuelve códigos de un solo uso con formato XXXXX-XXXXX
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		var b strings.Builder
+		for j := 0; j < 10; j++ {
+			if j == 5 {
+				b.WriteByte('-')
+			}
+			c, err := randomChar()
+			if err != nil {
+				return nil, err
+			}
+			b.WriteByte(c)
+		}
+		codes[i] = b.String()
+	}
+	return codes, nil
+}
+
+// randomChar elige un carácter del alfabeto sin sesgo de módulo
+func randomChar() (byte, error) {
+	limit := 256 - 256%len(recoveryAlphabet)
+	var buf [1]byte
+	for {
+		if _, err := rand.Read(buf[:]); err != nil {
+			return 0, err
+		}
+		if int(buf[0]) < limit {
+			return recoveryAlphabet[int(buf[0])%len(recoveryAlphabet)], nil
+		}
+	}
+}
+
+// HashRecoveryCode normaliza (mayúsculas, sin guiones ni espacios) y aplica SHA-256.
+// Los códigos tienen ~49 bits de entropía y un solo uso, así que no hace falta bcrypt.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
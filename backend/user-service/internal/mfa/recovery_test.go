@@ -0,0 +1,45 @@
+package mfa
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("generated %d codes, want %d", len(codes), RecoveryCodeCount)
+	}
+
+	format := regexp.MustCompile(`^[` + recoveryAlphabet + `]{5}-[` + recoveryAlphabet + `]{5}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q does not match XXXXX-XXXXX with the unambiguous alphabet", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q generated twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	hash := HashRecoveryCode("ABCDE-FGHJK")
+
+	// Se acepta tal como se copia a mano: minúsculas, sin guion o con espacios
+	for _, variant := range []string{"abcde-fghjk", "ABCDEFGHJK", "abcde fghjk"} {
+		if HashRecoveryCode(variant) != hash {
+			t.Errorf("HashRecoveryCode(%q) differs from the canonical code", variant)
+		}
+	}
+	if HashRecoveryCode("ABCDE-FGHJM") == hash {
+		t.Error("different codes share a hash")
+	}
+	if len(hash) != 64 {
+		t.Errorf("hash length = %d, want a hex SHA-256", len(hash))
+	}
+}
@@ -0,0 +1,291 @@
+// Package mockoidc es un proveedor OpenID Connect mínimo para probar el SSO:
+// lo sirve cmd/mock-oidc en local y lo usan los tests del flujo en handlers.
+//
+// Implementa discovery, authorize (un formulario donde se elige email, nombre y
+// grupos), token con verificación PKCE S256 y JWKS. No tiene usuarios ni
+// contraseñas: no debe desplegarse fuera de un entorno de desarrollo.
+package mockoidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	keyID   = "mock-oidc"
+	codeTTL = time.Minute
+)
+
+// Server es el proveedor; Handler expone sus endpoints
+type Server struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+// authorization es un código emitido en /authorize pendiente de canjear
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	challenge     string
+	email         string
+	givenName     string
+	familyName    string
+	groups        []string
+	emailVerified bool
+	expiresAt     time.Time
+}
+
+var authorizeForm = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html lang="es">
+<head><meta charset="utf-8"><title>Mock OIDC</title>
+<style>body{font-family:sans-serif;max-width:420px;margin:40px auto}label{display:block;margin-top:12px}input{width:100%;padding:6px}</style>
+</head>
+<body>
+<h2>Proveedor OIDC de pruebas</h2>
+<form method="post">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}
+<label>Email <input name="email" value="empleado@clinica.local" required></label>
+<label>Nombre <input name="given_name" value="Ana"></label>
+<label>Apellidos <input name="family_name" value="Pérez"></label>
+<label>Grupos (separados por comas) <input name="groups" value="clinic-staff"></label>
+<label><input type="checkbox" name="email_verified" value="true" checked style="width:auto"> Email verificado</label>
+<p><button type="submit">Iniciar sesión</button> <button type="submit" name="deny" value="1">Denegar</button></p>
+</form>
+</body>
+</html>`))
+
+// New genera la clave de firma; clientSecret vacío admite clientes públicos
+func New(issuer, clientID, clientSecret string) (*Server, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		issuer:       strings.TrimRight(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		key:          key,
+		codes:        make(map[string]authorization),
+	}, nil
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/jwks", s.jwks)
+	return mux
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile", "groups"},
+		"claims_supported":                      []string{"sub", "email", "email_verified", "given_name", "family_name", "groups"},
+	})
+}
+
+// authorize muestra el formulario (GET) y emite el código al enviarlo (POST)
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	params := map[string]string{}
+	for _, name := range []string{"client_id", "redirect_uri", "response_type", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+		params[name] = r.Form.Get(name)
+	}
+
+	if params["client_id"] != s.clientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(params["redirect_uri"])
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	query := redirectURI.Query()
+	query.Set("state", params["state"])
+	switch {
+	case params["response_type"] != "code":
+		query.Set("error", "unsupported_response_type")
+	case params["code_challenge"] == "" || params["code_challenge_method"] != "S256":
+		query.Set("error", "invalid_request")
+		query.Set("error_description", "PKCE S256 is required")
+	case r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = authorizeForm.Execute(w, map[string]interface{}{"Params": params})
+		return
+	case r.Form.Get("deny") != "":
+		query.Set("error", "access_denied")
+	default:
+		code := randomString()
+		s.mu.Lock()
+		s.codes[code] = authorization{
+			clientID:      params["client_id"],
+			redirectURI:   params["redirect_uri"],
+			nonce:         params["nonce"],
+			challenge:     params["code_challenge"],
+			email:         strings.TrimSpace(r.Form.Get("email")),
+			givenName:     strings.TrimSpace(r.Form.Get("given_name")),
+			familyName:    strings.TrimSpace(r.Form.Get("family_name")),
+			groups:        splitGroups(r.Form.Get("groups")),
+			emailVerified: r.Form.Get("email_verified") == "true",
+			expiresAt:     time.Now().Add(codeTTL),
+		}
+		s.mu.Unlock()
+		query.Set("code", code)
+	}
+
+	redirectURI.RawQuery = query.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token canjea el código comprobando cliente, redirect_uri y el verificador PKCE
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.Form.Get("client_id"), r.Form.Get("client_secret")
+	}
+	if clientID != s.clientID || (s.clientSecret != "" && clientSecret != s.clientSecret) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.Form.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	code := r.Form.Get("code")
+	s.mu.Lock()
+	auth, found := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+
+	if !found || time.Now().After(auth.expiresAt) || auth.clientID != clientID || auth.redirectURI != r.Form.Get("redirect_uri") {
+		tokenError(w, "invalid_grant")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            s.issuer,
+		"sub":            "mock|" + strings.ToLower(auth.email),
+		"aud":            clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"email":          auth.email,
+		"email_verified": auth.emailVerified,
+		"given_name":     auth.givenName,
+		"family_name":    auth.familyName,
+		"groups":         auth.groups,
+	}
+	if auth.nonce != "" {
+		claims["nonce"] = auth.nonce
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	log.Printf("Issued id_token for %s with groups %v", auth.email, auth.groups)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	public := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func randomString() string {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		log.Fatal("Failed to read random bytes:", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func splitGroups(value string) []string {
+	groups := []string{}
+	for _, group := range strings.Split(value, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
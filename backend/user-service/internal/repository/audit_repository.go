@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"user-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditFilter describe una consulta a audit_logs. From es inclusivo y To
+// exclusivo; StatusMin y StatusMax (inclusivos, 0 = sin límite) permiten pedir
+// un código exacto o una clase como 4xx.
+type AuditFilter struct {
+	UserID           *uuid.UUID
+	ServiceAccountID *uuid.UUID
+	ResourceType     string
+	ResourceID       string
+	Action           string
+	From             *time.Time
+	To               *time.Time
+	StatusMin        int
+	StatusMax        int
+	Limit            int
+	Cursor           string // NextCursor de la página anterior
+}
+
+// AuditPage es una página de registros, del más reciente al más antiguo
+type AuditPage struct {
+	Entries    []domain.AuditLog
+	NextCursor string
+}
+
+// auditCursor es la posición del último registro devuelto
+type auditCursor struct {
+	Timestamp time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+type AuditRepository interface {
+	Create(entry *domain.AuditLog) error
+	CreateBatch(entries []*domain.AuditLog) error
+	List(filter AuditFilter) (*AuditPage, error)
+	Stream(filter AuditFilter, fn func(entry *domain.AuditLog) error) error
+
+	ChainHead() (int64, string, error)
+	ChainTriggerEnabled() (bool, error)
+	CanRewriteHistory() (bool, error)
+	LastCheckpoint() (*domain.AuditCheckpoint, error)
+	CreateCheckpoint(checkpoint *domain.AuditCheckpoint) error
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Create(entry *domain.AuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *auditRepository) CreateBatch(entries []*domain.AuditLog) error {
+	return r.db.CreateInBatches(entries, len(entries)).Error
+}
+
+// List pagina por keyset sobre (timestamp, id) en orden descendente
+func (r *auditRepository) List(filter AuditFilter) (*AuditPage, error) {
+	query, err := r.filtered(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &AuditPage{}
+	// Se pide uno de más para saber si hay página siguiente
+	err = query.Order("timestamp DESC, id DESC").Limit(filter.Limit + 1).Find(&page.Entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.Entries) > filter.Limit {
+		page.Entries = page.Entries[:filter.Limit]
+		last := page.Entries[len(page.Entries)-1]
+		raw, _ := json.Marshal(auditCursor{Timestamp: last.Timestamp, ID: last.ID})
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	return page, nil
+}
+
+// Stream recorre todos los registros del filtro (desde el cursor, si lo hay) sin
+// cargarlos en memoria; se usa para las exportaciones
+func (r *auditRepository) Stream(filter AuditFilter, fn func(entry *domain.AuditLog) error) error {
+	query, err := r.filtered(filter)
+	if err != nil {
+		return err
+	}
+
+	rows, err := query.Order("timestamp DESC, id DESC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry domain.AuditLog
+		if err := r.db.ScanRows(rows, &entry); err != nil {
+			return err
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ChainHead devuelve la posición y el hash de la última fila encadenada
+// (audit_chain_head, migración 019)
+func (r *auditRepository) ChainHead() (int64, string, error) {
+	var head struct {
+		Seq  int64
+		Hash string
+	}
+	err := r.db.Raw("SELECT seq, hash FROM audit_chain_head WHERE id = 1").Scan(&head).Error
+	return head.Seq, head.Hash, err
+}
+
+// ChainTriggerEnabled indica si el trigger que encadena las filas está instalado
+// y activo, con la función del esquema audit (migraciones 019 y 024): sin él las
+// inserciones quedan fuera de la cadena
+func (r *auditRepository) ChainTriggerEnabled() (bool, error) {
+	var enabled bool
+	err := r.db.Raw(`SELECT EXISTS (
+		SELECT 1 FROM pg_trigger t JOIN pg_proc p ON p.oid = t.tgfoid
+		WHERE t.tgrelid = 'audit_logs'::regclass AND t.tgname = 'audit_logs_chain' AND t.tgenabled <> 'D'
+			AND p.pronamespace::regnamespace::text = 'audit'
+	)`).Scan(&enabled).Error
+	return enabled, err
+}
+
+// CanRewriteHistory indica si el rol de la conexión es superusuario o
+// propietario de audit_logs (migración 020): podría vaciar la tabla o
+// desactivar sus triggers
+func (r *auditRepository) CanRewriteHistory() (bool, error) {
+	var can bool
+	err := r.db.Raw(`SELECT r.rolsuper OR pg_has_role(c.relowner, 'USAGE')
+		FROM pg_class c, pg_roles r
+		WHERE c.oid = 'audit_logs'::regclass AND r.rolname = current_user`).Scan(&can).Error
+	return can, err
+}
+
+// LastCheckpoint devuelve nil si todavía no hay ninguno
+func (r *auditRepository) LastCheckpoint() (*domain.AuditCheckpoint, error) {
+	var checkpoint domain.AuditCheckpoint
+	err := r.db.Order("chain_seq DESC").Limit(1).Find(&checkpoint).Error
+	if err != nil || checkpoint.ID == uuid.Nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+func (r *auditRepository) CreateCheckpoint(checkpoint *domain.AuditCheckpoint) error {
+	return r.db.Create(checkpoint).Error
+}
+
+// filtered aplica los filtros y el cursor (sin orden ni límite)
+func (r *auditRepository) filtered(filter AuditFilter) (*gorm.DB, error) {
+	query := r.db.Model(&domain.AuditLog{})
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.ServiceAccountID != nil {
+		query = query.Where("service_account_id = ?", *filter.ServiceAccountID)
+	}
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.From != nil {
+		query = query.Where("timestamp >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("timestamp < ?", *filter.To)
+	}
+	if filter.StatusMin > 0 {
+		query = query.Where("response_status >= ?", filter.StatusMin)
+	}
+	if filter.StatusMax > 0 {
+		query = query.Where("response_status <= ?", filter.StatusMax)
+	}
+
+	if filter.Cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		var cursor auditCursor
+		if err := json.Unmarshal(raw, &cursor); err != nil || cursor.ID == uuid.Nil {
+			return nil, ErrInvalidCursor
+		}
+		query = query.Where("(timestamp, id) < (?, ?)", cursor.Timestamp, cursor.ID)
+	}
+	return query, nil
+}
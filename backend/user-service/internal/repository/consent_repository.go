@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"time"
+	"user-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ConsentRepository interface {
+	Record(userID uuid.UUID, records []domain.ConsentRecord, flags map[string]interface{}) error
+	ListForUser(userID uuid.UUID) ([]domain.ConsentRecord, error)
+	ExpireOutdated(version string, at time.Time) (int64, error)
+}
+
+type consentRepository struct {
+	db *gorm.DB
+}
+
+func NewConsentRepository(db *gorm.DB) ConsentRepository {
+	return &consentRepository{db: db}
+}
+
+// Record inserta los registros y actualiza las columnas de consentimiento de
+// users en la misma transacción, para que el estado y su historial no diverjan
+func (r *consentRepository) Record(userID uuid.UUID, records []domain.ConsentRecord, flags map[string]interface{}) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&records).Error; err != nil {
+			return err
+		}
+		return tx.Model(&domain.User{}).Where("id = ?", userID).Updates(flags).Error
+	})
+}
+
+func (r *consentRepository) ListForUser(userID uuid.UUID) ([]domain.ConsentRecord, error) {
+	var records []domain.ConsentRecord
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&records).Error
+	return records, err
+}
+
+// ExpireOutdated retira los permisos de grabación y livestream concedidos con una
+// versión del documento distinta de version, dejando un registro expired por
+// cada uno. Devuelve cuántos usuarios se vieron afectados.
+func (r *consentRepository) ExpireOutdated(version string, at time.Time) (int64, error) {
+	var affected int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		outdated := "(consent_version IS NULL OR consent_version <> ?) AND deleted_at IS NULL"
+		for column, consentType := range map[string]string{
+			"recording_consent":  domain.ConsentRecording,
+			"livestream_consent": domain.ConsentLivestream,
+		} {
+			err := tx.Exec(`INSERT INTO consent_records (id, user_id, consent_type, action, document_version, created_at)
+				SELECT gen_random_uuid(), id, ?, ?, COALESCE(consent_version, ''), ?
+				FROM users WHERE `+column+` = true AND `+outdated,
+				consentType, domain.ConsentExpired, at, version).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&domain.User{}).
+			Where("(recording_consent = true OR livestream_consent = true) AND "+outdated, version).
+			Updates(map[string]interface{}{"recording_consent": false, "livestream_consent": false})
+		affected = result.RowsAffected
+		return result.Error
+	})
+	return affected, err
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"errors"
+	"time"
+	"user-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrFamilyLinkExists indica que ya hay un vínculo pendiente o aprobado con ese paciente
+	ErrFamilyLinkExists = errors.New("family link already exists")
+	// ErrFamilyLinkNotFound indica que el vínculo no existe, no es del usuario o no
+	// está en un estado que permita la operación
+	ErrFamilyLinkNotFound = errors.New("family link not found")
+)
+
+type FamilyRepository interface {
+	RequestLink(link *domain.FamilyRelationship) error
+	GetByID(id uuid.UUID) (*domain.FamilyRelationship, error)
+	ListForPatient(patientID uuid.UUID) ([]domain.FamilyRelationship, error)
+	ListForFamilyMember(memberID uuid.UUID) ([]domain.FamilyRelationship, error)
+	Respond(id, patientID uuid.UUID, status string, at time.Time) error
+	Revoke(id, actorID uuid.UUID, at time.Time) error
+	IsApproved(patientID, memberID uuid.UUID) (bool, error)
+}
+
+type familyRepository struct {
+	db *gorm.DB
+}
+
+func NewFamilyRepository(db *gorm.DB) FamilyRepository {
+	return &familyRepository{db: db}
+}
+
+// RequestLink crea la solicitud pendiente. Si el par ya existía rechazado o
+// revocado se reutiliza la fila (family_relationships es único por par).
+func (r *familyRepository) RequestLink(link *domain.FamilyRelationship) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing domain.FamilyRelationship
+		err := tx.Where("patient_id = ? AND family_member_id = ?", link.PatientID, link.FamilyMemberID).
+			First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(link).Error
+		}
+		if err != nil {
+			return err
+		}
+		if existing.Status == domain.FamilyLinkPending || existing.Status == domain.FamilyLinkApproved {
+			return ErrFamilyLinkExists
+		}
+
+		link.ID = existing.ID
+		link.CreatedAt = existing.CreatedAt
+		return tx.Model(&domain.FamilyRelationship{}).Where("id = ?", existing.ID).
+			Updates(map[string]interface{}{
+				"relationship_type": link.RelationshipType,
+				"status":            link.Status,
+				"requested_at":      link.RequestedAt,
+				"responded_at":      nil,
+				"revoked_at":        nil,
+				"revoked_by":        nil,
+				"updated_at":        link.UpdatedAt,
+			}).Error
+	})
+}
+
+func (r *familyRepository) GetByID(id uuid.UUID) (*domain.FamilyRelationship, error) {
+	var link domain.FamilyRelationship
+	err := r.db.Preload("Patient").Preload("FamilyMember").Where("id = ?", id).First(&link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFamilyLinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// ListForPatient devuelve los vínculos pendientes y aprobados del paciente
+func (r *familyRepository) ListForPatient(patientID uuid.UUID) ([]domain.FamilyRelationship, error) {
+	return r.list("patient_id = ?", patientID)
+}
+
+// ListForFamilyMember devuelve las solicitudes y vínculos del familiar,
+// incluidas las rechazadas para que sepa la respuesta
+func (r *familyRepository) ListForFamilyMember(memberID uuid.UUID) ([]domain.FamilyRelationship, error) {
+	return r.list("family_member_id = ?", memberID)
+}
+
+func (r *familyRepository) list(condition string, userID uuid.UUID) ([]domain.FamilyRelationship, error) {
+	var links []domain.FamilyRelationship
+	err := r.db.Preload("Patient").Preload("FamilyMember").
+		Where(condition, userID).
+		Where("status <> ?", domain.FamilyLinkRevoked).
+		Order("requested_at DESC").
+		Find(&links).Error
+	return links, err
+}
+
+// Respond aprueba o rechaza una solicitud pendiente dirigida al paciente
+func (r *familyRepository) Respond(id, patientID uuid.UUID, status string, at time.Time) error {
+	result := r.db.Model(&domain.FamilyRelationship{}).
+		Where("id = ? AND patient_id = ? AND status = ?", id, patientID, domain.FamilyLinkPending).
+		Updates(map[string]interface{}{"status": status, "responded_at": at, "updated_at": at})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrFamilyLinkNotFound
+	}
+	return nil
+}
+
+// Revoke retira un vínculo pendiente o aprobado; puede hacerlo el paciente o el familiar
+func (r *familyRepository) Revoke(id, actorID uuid.UUID, at time.Time) error {
+	result := r.db.Model(&domain.FamilyRelationship{}).
+		Where("id = ? AND (patient_id = ? OR family_member_id = ?) AND status IN ?",
+			id, actorID, actorID, []string{domain.FamilyLinkPending, domain.FamilyLinkApproved}).
+		Updates(map[string]interface{}{
+			"status":     domain.FamilyLinkRevoked,
+			"revoked_at": at,
+			"revoked_by": actorID,
+			"updated_at": at,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrFamilyLinkNotFound
+	}
+	return nil
+}
+
+// IsApproved indica si el familiar tiene un vínculo aprobado con el paciente
+func (r *familyRepository) IsApproved(patientID, memberID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.FamilyRelationship{}).
+		Where("patient_id = ? AND family_member_id = ? AND status = ?", patientID, memberID, domain.FamilyLinkApproved).
+		Count(&count).Error
+	return count > 0, err
+}
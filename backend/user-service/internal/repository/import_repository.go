@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+// ErrImportConflict indica que un email o MRN del lote se registró entre la
+// validación y la creación
+var ErrImportConflict = errors.New("email or medical record number already in use")
+
+// importBatchSize es el número de filas por INSERT al crear el lote
+const importBatchSize = 100
+
+// UserImport es un lote de cuentas importadas: usuarios, fichas de paciente y
+// vínculos familiares, que se crean todos o ninguno
+type UserImport struct {
+	Users       []domain.User
+	Patients    []domain.Patient
+	FamilyLinks []domain.FamilyRelationship
+}
+
+type ImportRepository interface {
+	ExistingEmails(emails []string) (map[string]bool, error)
+	ExistingMRNs(mrns []string) ([]string, error)
+	Create(batch *UserImport) error
+}
+
+type importRepository struct {
+	db *gorm.DB
+}
+
+func NewImportRepository(db *gorm.DB) ImportRepository {
+	return &importRepository{db: db}
+}
+
+// ExistingEmails devuelve, en minúsculas, los emails que ya tienen cuenta y si
+// esa cuenta está borrada (el índice único también cubre las borradas)
+func (r *importRepository) ExistingEmails(emails []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(emails) == 0 {
+		return existing, nil
+	}
+	var rows []struct {
+		Email   string
+		Deleted bool
+	}
+	err := r.db.Unscoped().Model(&domain.User{}).
+		Select("LOWER(email) AS email, deleted_at IS NOT NULL AS deleted").
+		Where("LOWER(email) IN ?", lowerAll(emails)).
+		Scan(&rows).Error
+	for _, row := range rows {
+		existing[row.Email] = row.Deleted
+	}
+	return existing, err
+}
+
+// ExistingMRNs devuelve los números de historia clínica ya asignados
+func (r *importRepository) ExistingMRNs(mrns []string) ([]string, error) {
+	var existing []string
+	if len(mrns) == 0 {
+		return existing, nil
+	}
+	err := r.db.Model(&domain.Patient{}).
+		Where("medical_record_number IN ?", mrns).
+		Pluck("medical_record_number", &existing).Error
+	return existing, err
+}
+
+// Create inserta el lote en una transacción. Si otro alta ocupa un email o MRN
+// entre la validación y la creación no se crea nada y devuelve ErrImportConflict.
+func (r *importRepository) Create(batch *UserImport) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&batch.Users, importBatchSize).Error; err != nil {
+			return err
+		}
+		if len(batch.Patients) > 0 {
+			if err := tx.CreateInBatches(&batch.Patients, importBatchSize).Error; err != nil {
+				return err
+			}
+		}
+		if len(batch.FamilyLinks) > 0 {
+			if err := tx.CreateInBatches(&batch.FamilyLinks, importBatchSize).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrImportConflict
+	}
+	return err
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(value)
+	}
+	return lowered
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"errors"
+	"time"
+	"user-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvitationUnavailable indica que la invitación ya fue aceptada, revocada o expiró
+var ErrInvitationUnavailable = errors.New("invitation is no longer available")
+
+type InvitationRepository interface {
+	Create(invitation *domain.Invitation) error
+	GetByID(id uuid.UUID) (*domain.Invitation, error)
+	ListPending() ([]domain.Invitation, error)
+	Revoke(id uuid.UUID) error
+	RevokePendingForEmail(email string) error
+	Accept(invitation *domain.Invitation, user *domain.User) error
+}
+
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) InvitationRepository {
+	return &invitationRepository{db: db}
+}
+
+func (r *invitationRepository) Create(invitation *domain.Invitation) error {
+	return r.db.Create(invitation).Error
+}
+
+func (r *invitationRepository) GetByID(id uuid.UUID) (*domain.Invitation, error) {
+	var invitation domain.Invitation
+	err := r.db.Where("id = ?", id).First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *invitationRepository) ListPending() ([]domain.Invitation, error) {
+	var invitations []domain.Invitation
+	err := r.db.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// Revoke invalida una invitación pendiente; devuelve ErrInvitationUnavailable si ya no lo estaba
+func (r *invitationRepository) Revoke(id uuid.UUID) error {
+	result := r.db.Model(&domain.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvitationUnavailable
+	}
+	return nil
+}
+
+func (r *invitationRepository) RevokePendingForEmail(email string) error {
+	return r.db.Model(&domain.Invitation{}).
+		Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL", email).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Accept crea el usuario y marca la invitación como aceptada en una misma
+// transacción, de modo que un token no pueda canjearse dos veces
+func (r *invitationRepository) Accept(invitation *domain.Invitation, user *domain.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&domain.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", invitation.ID, now).
+			Updates(map[string]interface{}{
+				"accepted_at": now,
+				"user_id":     user.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationUnavailable
+		}
+		return tx.Create(user).Error
+	})
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"errors"
+	"time"
+	"user-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrMFACodeReused indica que el código TOTP ya se usó (mismo paso o anterior)
+	ErrMFACodeReused = errors.New("mfa code already used")
+	// ErrRecoveryCodeInvalid indica que el código de recuperación no existe o ya se usó
+	ErrRecoveryCodeInvalid = errors.New("recovery code is invalid or already used")
+)
+
+type MFARepository interface {
+	Enable(userID uuid.UUID) error
+	ClaimStep(userID uuid.UUID, step int64) error
+	ReplaceRecoveryCodes(userID uuid.UUID, hashes []string) error
+	UseRecoveryCode(userID uuid.UUID, hash string) error
+	CountRecoveryCodes(userID uuid.UUID) (int64, error)
+	Disable(userID uuid.UUID) error
+}
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+// Enable activa MFA una vez confirmado el secreto pendiente
+func (r *mfaRepository) Enable(userID uuid.UUID) error {
+	now := time.Now()
+	return r.db.Model(&domain.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"mfa_enabled":    true,
+		"mfa_enabled_at": now,
+		"updated_at":     now,
+	}).Error
+}
+
+// ClaimStep guarda el paso TOTP más alto aceptado; un paso menor o igual que
+// ese es una reutilización (el mismo código o uno anterior aún dentro de la
+// ventana de desfase) y se rechaza
+func (r *mfaRepository) ClaimStep(userID uuid.UUID, step int64) error {
+	result := r.db.Model(&domain.User{}).
+		Where("id = ? AND NOT (? <= COALESCE(mfa_last_step, 0))", userID, step).
+		Update("mfa_last_step", gorm.Expr("GREATEST(COALESCE(mfa_last_step, 0), ?)", step))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMFACodeReused
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes invalida los códigos anteriores y guarda los nuevos
+func (r *mfaRepository) ReplaceRecoveryCodes(userID uuid.UUID, hashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]domain.MFARecoveryCode, len(hashes))
+		for i, hash := range hashes {
+			codes[i] = domain.MFARecoveryCode{
+				ID:        uuid.New(),
+				UserID:    userID,
+				CodeHash:  hash,
+				CreatedAt: time.Now(),
+			}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *mfaRepository) UseRecoveryCode(userID uuid.UUID, hash string) error {
+	result := r.db.Model(&domain.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecoveryCodeInvalid
+	}
+	return nil
+}
+
+func (r *mfaRepository) CountRecoveryCodes(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// Disable borra el secreto y los códigos de recuperación del usuario
+func (r *mfaRepository) Disable(userID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"mfa_enabled":    false,
+			"mfa_secret":     "",
+			"mfa_last_step":  0,
+			"mfa_enabled_at": nil,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&domain.MFARecoveryCode{}).Error
+	})
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"errors"
+	"user-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrDuplicateMRN indica que el número de historia clínica ya está asignado a otro paciente
+var ErrDuplicateMRN = errors.New("medical record number already in use")
+
+// ErrPatientNotFound indica que el usuario no tiene ficha de paciente
+var ErrPatientNotFound = errors.New("patient profile not found")
+
+type PatientRepository interface {
+	Create(patient *domain.Patient) error
+	GetByUserID(userID uuid.UUID) (*domain.Patient, error)
+	Update(patient *domain.Patient) error
+	DeleteByUserID(userID uuid.UUID) error
+}
+
+type patientRepository struct {
+	db *gorm.DB
+}
+
+func NewPatientRepository(db *gorm.DB) PatientRepository {
+	return &patientRepository{db: db}
+}
+
+func (r *patientRepository) Create(patient *domain.Patient) error {
+	return translatePatientError(r.db.Create(patient).Error)
+}
+
+func (r *patientRepository) GetByUserID(userID uuid.UUID) (*domain.Patient, error) {
+	var patient domain.Patient
+	err := r.db.Where("user_id = ?", userID).First(&patient).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPatientNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &patient, nil
+}
+
+func (r *patientRepository) Update(patient *domain.Patient) error {
+	return translatePatientError(r.db.Save(patient).Error)
+}
+
+func (r *patientRepository) DeleteByUserID(userID uuid.UUID) error {
+	result := r.db.Where("user_id = ?", userID).Delete(&domain.Patient{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPatientNotFound
+	}
+	return nil
+}
+
+// translatePatientError convierte la violación del índice único de MRN (con
+// TranslateError activado en la conexión) en ErrDuplicateMRN. La unicidad por
+// usuario se comprueba antes de crear, así que es la única que puede saltar.
+func translatePatientError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicateMRN
+	}
+	return err
+}
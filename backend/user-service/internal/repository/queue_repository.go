@@ -43,7 +43,7 @@ func (r *queueRepository) CreateQueueEntry(entry *domain.QueueEntry) error {
 
 func (r *queueRepository) GetQueueEntries() ([]domain.QueueEntry, error) {
 	var entries []domain.QueueEntry
-	err := r.db.Preload("Patient").Preload("Employee").
+	err := r.db.Preload("Patient").Preload("PatientProfile").Preload("Employee").
 		Where("status = ?", "waiting").
 		Order("priority ASC, created_at ASC").
 		Find(&entries).Error
@@ -52,7 +52,7 @@ func (r *queueRepository) GetQueueEntries() ([]domain.QueueEntry, error) {
 
 func (r *queueRepository) GetQueueEntryByID(id uuid.UUID) (*domain.QueueEntry, error) {
 	var entry domain.QueueEntry
-	err := r.db.Preload("Patient").Preload("Employee").
+	err := r.db.Preload("Patient").Preload("PatientProfile").Preload("Employee").
 		First(&entry, "id = ?", id).Error
 	if err != nil {
 		return nil, err
@@ -71,7 +71,7 @@ func (r *queueRepository) DeleteQueueEntry(id uuid.UUID) error {
 
 func (r *queueRepository) GetQueueByStatus(status string) ([]domain.QueueEntry, error) {
 	var entries []domain.QueueEntry
-	err := r.db.Preload("Patient").Preload("Employee").
+	err := r.db.Preload("Patient").Preload("PatientProfile").Preload("Employee").
 		Where("status = ?", status).
 		Order("priority ASC, created_at ASC").
 		Find(&entries).Error
@@ -92,7 +92,7 @@ func (r *queueRepository) AssignQueueEntry(entryID, employeeID uuid.UUID) error
 
 func (r *queueRepository) GetNextQueueEntry() (*domain.QueueEntry, error) {
 	var entry domain.QueueEntry
-	err := r.db.Preload("Patient").
+	err := r.db.Preload("Patient").Preload("PatientProfile").
 		Where("status = ?", "waiting").
 		Order("priority ASC, created_at ASC").
 		First(&entry).Error
@@ -109,12 +109,12 @@ func (r *queueRepository) CreateCall(call *domain.Call) error {
 		return err
 	}
 	// Cargar las relaciones después de crear
-	return r.db.Preload("Patient").Preload("Employee").Preload("QueueEntry").First(call, call.ID).Error
+	return r.db.Preload("Patient").Preload("PatientProfile").Preload("Employee").Preload("QueueEntry").First(call, call.ID).Error
 }
 
 func (r *queueRepository) GetActiveCalls() ([]domain.Call, error) {
 	var calls []domain.Call
-	err := r.db.Preload("Patient").Preload("Employee").Preload("QueueEntry").
+	err := r.db.Preload("Patient").Preload("PatientProfile").Preload("Employee").Preload("QueueEntry").
 		Where("status = ?", "active").
 		Order("started_at DESC").
 		Find(&calls).Error
@@ -123,7 +123,7 @@ func (r *queueRepository) GetActiveCalls() ([]domain.Call, error) {
 
 func (r *queueRepository) GetCallByID(id uuid.UUID) (*domain.Call, error) {
 	var call domain.Call
-	err := r.db.Preload("Patient").Preload("Employee").Preload("QueueEntry").
+	err := r.db.Preload("Patient").Preload("PatientProfile").Preload("Employee").Preload("QueueEntry").
 		First(&call, "id = ?", id).Error
 	if err != nil {
 		return nil, err
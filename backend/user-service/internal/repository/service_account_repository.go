@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"errors"
+	"time"
+	"user-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrAPIKeyNotFound indica que la key no existe o no pertenece a la cuenta de servicio
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+type ServiceAccountRepository interface {
+	Create(account *domain.ServiceAccount) error
+	GetByID(id uuid.UUID) (*domain.ServiceAccount, error)
+	List() ([]domain.ServiceAccount, error)
+	Deactivate(id uuid.UUID, at time.Time) error
+
+	CreateKey(key *domain.APIKey) error
+	GetKeyByHash(hash string) (*domain.APIKey, error)
+	RevokeKey(accountID, keyID uuid.UUID, at time.Time) error
+	TouchKey(id uuid.UUID, at time.Time, ip string, every time.Duration) error
+}
+
+type serviceAccountRepository struct {
+	db *gorm.DB
+}
+
+func NewServiceAccountRepository(db *gorm.DB) ServiceAccountRepository {
+	return &serviceAccountRepository{db: db}
+}
+
+func (r *serviceAccountRepository) Create(account *domain.ServiceAccount) error {
+	return r.db.Create(account).Error
+}
+
+func (r *serviceAccountRepository) GetByID(id uuid.UUID) (*domain.ServiceAccount, error) {
+	var account domain.ServiceAccount
+	err := r.db.Preload("APIKeys", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	}).Where("id = ?", id).First(&account).Error
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *serviceAccountRepository) List() ([]domain.ServiceAccount, error) {
+	var accounts []domain.ServiceAccount
+	err := r.db.Preload("APIKeys", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	}).Order("name").Find(&accounts).Error
+	return accounts, err
+}
+
+// Deactivate desactiva la cuenta y revoca todas sus keys
+func (r *serviceAccountRepository) Deactivate(id uuid.UUID, at time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.ServiceAccount{}).Where("id = ?", id).
+			Updates(map[string]interface{}{"is_active": false, "updated_at": at}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&domain.APIKey{}).
+			Where("service_account_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", at).Error
+	})
+}
+
+func (r *serviceAccountRepository) CreateKey(key *domain.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *serviceAccountRepository) GetKeyByHash(hash string) (*domain.APIKey, error) {
+	var key domain.APIKey
+	err := r.db.Where("key_hash = ?", hash).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *serviceAccountRepository) RevokeKey(accountID, keyID uuid.UUID, at time.Time) error {
+	result := r.db.Model(&domain.APIKey{}).
+		Where("id = ? AND service_account_id = ? AND revoked_at IS NULL", keyID, accountID).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// TouchKey anota el último uso de la key, como mucho una vez por intervalo every
+// para no escribir en cada petición
+func (r *serviceAccountRepository) TouchKey(id uuid.UUID, at time.Time, ip string, every time.Duration) error {
+	return r.db.Model(&domain.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, at.Add(-every)).
+		Updates(map[string]interface{}{"last_used_at": at, "last_used_ip": ip}).Error
+}
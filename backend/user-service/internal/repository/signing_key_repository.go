@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type SigningKeyRepository interface {
+	Create(key *domain.SigningKey) error
+	ListPublished(now time.Time) ([]domain.SigningKey, error)
+	RotateOlderThan(key *domain.SigningKey, retiresAt time.Time) error
+	DeleteRetired(before time.Time) error
+}
+
+type signingKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) SigningKeyRepository {
+	return &signingKeyRepository{db: db}
+}
+
+func (r *signingKeyRepository) Create(key *domain.SigningKey) error {
+	return r.db.Create(key).Error
+}
+
+// ListPublished devuelve las claves aún publicadas, de la más nueva a la más antigua
+func (r *signingKeyRepository) ListPublished(now time.Time) ([]domain.SigningKey, error) {
+	var keys []domain.SigningKey
+	err := r.db.Where("retires_at IS NULL OR retires_at > ?", now).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// RotateOlderThan deja de usar para firmar las claves anteriores a key; siguen
+// publicadas hasta retiresAt para validar los tokens ya emitidos
+func (r *signingKeyRepository) RotateOlderThan(key *domain.SigningKey, retiresAt time.Time) error {
+	return r.db.Model(&domain.SigningKey{}).
+		Where("rotated_at IS NULL AND id <> ? AND created_at <= ?", key.ID, key.CreatedAt).
+		Updates(map[string]interface{}{
+			"rotated_at": key.CreatedAt,
+			"retires_at": retiresAt,
+		}).Error
+}
+
+func (r *signingKeyRepository) DeleteRetired(before time.Time) error {
+	return r.db.Where("retires_at IS NOT NULL AND retires_at < ?", before).Delete(&domain.SigningKey{}).Error
+}
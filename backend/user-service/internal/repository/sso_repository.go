@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"errors"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrLoginStateInvalid indica que el state del login SSO no existe, ya se usó o expiró
+var ErrLoginStateInvalid = errors.New("sso login state is invalid or expired")
+
+type SSORepository interface {
+	GetIdentity(issuer, subject string) (*domain.ExternalIdentity, error)
+	CreateIdentity(identity *domain.ExternalIdentity) error
+	CreateUserWithIdentity(user *domain.User, identity *domain.ExternalIdentity) error
+	TouchIdentity(identity *domain.ExternalIdentity, email string, at time.Time) error
+
+	CreateState(state *domain.OIDCLoginState) error
+	ConsumeState(hash string) (*domain.OIDCLoginState, error)
+	DeleteExpiredStates(before time.Time) error
+}
+
+type ssoRepository struct {
+	db *gorm.DB
+}
+
+func NewSSORepository(db *gorm.DB) SSORepository {
+	return &ssoRepository{db: db}
+}
+
+func (r *ssoRepository) GetIdentity(issuer, subject string) (*domain.ExternalIdentity, error) {
+	var identity domain.ExternalIdentity
+	err := r.db.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *ssoRepository) CreateIdentity(identity *domain.ExternalIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+// CreateUserWithIdentity da de alta un usuario aprovisionado por SSO junto con
+// su identidad externa en la misma transacción
+func (r *ssoRepository) CreateUserWithIdentity(user *domain.User, identity *domain.ExternalIdentity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(identity).Error
+	})
+}
+
+// TouchIdentity anota el último login y el email actual que informa el IdP
+func (r *ssoRepository) TouchIdentity(identity *domain.ExternalIdentity, email string, at time.Time) error {
+	return r.db.Model(identity).Updates(map[string]interface{}{
+		"email":         email,
+		"last_login_at": at,
+	}).Error
+}
+
+func (r *ssoRepository) CreateState(state *domain.OIDCLoginState) error {
+	return r.db.Create(state).Error
+}
+
+// ConsumeState borra el state y lo devuelve; un segundo callback con el mismo
+// state no encuentra nada
+func (r *ssoRepository) ConsumeState(hash string) (*domain.OIDCLoginState, error) {
+	var states []domain.OIDCLoginState
+	err := r.db.Clauses(clause.Returning{}).
+		Where("state_hash = ?", hash).
+		Delete(&states).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(states) == 0 || time.Now().After(states[0].ExpiresAt) {
+		return nil, ErrLoginStateInvalid
+	}
+	return &states[0], nil
+}
+
+func (r *ssoRepository) DeleteExpiredStates(before time.Time) error {
+	return r.db.Where("expires_at < ?", before).Delete(&domain.OIDCLoginState{}).Error
+}
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"errors"
+	"time"
+	"user-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrTokenAlreadyUsed indica que el refresh token ya fue rotado o revocado
+var ErrTokenAlreadyUsed = errors.New("refresh token already used")
+
+// ErrAccessTokenRevoked indica que el jti ya estaba revocado o gastado
+var ErrAccessTokenRevoked = errors.New("access token already revoked")
+
+// ErrSessionNotFound indica que la sesión no existe, no es del usuario o ya está cerrada
+var ErrSessionNotFound = errors.New("session not found")
+
+type TokenRepository interface {
+	CreateRefreshToken(token *domain.RefreshToken) error
+	CreateSession(session *domain.Session, token *domain.RefreshToken) error
+	GetRefreshTokenByHash(hash string) (*domain.RefreshToken, error)
+	RotateRefreshToken(old *domain.RefreshToken, next *domain.RefreshToken, session *domain.Session) error
+	RevokeFamily(familyID uuid.UUID) error
+	RevokeAllForUser(userID uuid.UUID) error
+	IsFamilyRevoked(familyID uuid.UUID) (bool, error)
+
+	ListActiveSessions(userID uuid.UUID, now time.Time) ([]domain.Session, error)
+	TouchSession(id uuid.UUID, at time.Time, ip string, every time.Duration) error
+	RevokeSession(userID, id uuid.UUID) error
+	RevokeOtherSessions(userID, keep uuid.UUID) (int64, error)
+
+	RevokeAccessToken(token *domain.RevokedToken) error
+	ConsumeAccessToken(token *domain.RevokedToken) error
+	IsAccessTokenRevoked(jti string) (bool, error)
+	DeleteExpired(before time.Time) error
+}
+
+type tokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) TokenRepository {
+	return &tokenRepository{db: db}
+}
+
+func (r *tokenRepository) CreateRefreshToken(token *domain.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+// CreateSession registra la sesión y el primer refresh token de su familia
+func (r *tokenRepository) CreateSession(session *domain.Session, token *domain.RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+func (r *tokenRepository) GetRefreshTokenByHash(hash string) (*domain.RefreshToken, error) {
+	var token domain.RefreshToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken marca el token anterior como usado y crea el siguiente en una
+// transacción. Si otro request ya lo rotó devuelve ErrTokenAlreadyUsed. session
+// trae la IP de la petición; si la familia se abrió antes de existir las
+// sesiones y no tiene fila, se crea con ella.
+func (r *tokenRepository) RotateRefreshToken(old *domain.RefreshToken, next *domain.RefreshToken, session *domain.Session) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&domain.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", old.ID).
+			Updates(map[string]interface{}{
+				"used_at":        now,
+				"replaced_by_id": next.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenAlreadyUsed
+		}
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		// La sesión vive mientras su último refresh token
+		result = tx.Model(&domain.Session{}).Where("id = ?", next.FamilyID).
+			Updates(map[string]interface{}{
+				"expires_at":       next.ExpiresAt,
+				"last_activity_at": now,
+				"ip_address":       session.IPAddress,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+		return tx.Create(session).Error
+	})
+}
+
+// RevokeFamily revoca los refresh tokens de la familia y cierra su sesión
+func (r *tokenRepository) RevokeFamily(familyID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Model(&domain.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&domain.Session{}).
+			Where("id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error
+	})
+}
+
+// RevokeAllForUser cierra todas las sesiones del usuario (p. ej. tras cambiar la contraseña)
+func (r *tokenRepository) RevokeAllForUser(userID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Model(&domain.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&domain.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error
+	})
+}
+
+func (r *tokenRepository) IsFamilyRevoked(familyID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NOT NULL", familyID).
+		Limit(1).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ListActiveSessions devuelve las sesiones abiertas del usuario, la más reciente primero
+func (r *tokenRepository) ListActiveSessions(userID uuid.UUID, now time.Time) ([]domain.Session, error) {
+	var sessions []domain.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_activity_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// TouchSession anota la última actividad de la sesión, como mucho una vez por
+// intervalo every para no escribir en cada petición
+func (r *tokenRepository) TouchSession(id uuid.UUID, at time.Time, ip string, every time.Duration) error {
+	return r.db.Model(&domain.Session{}).
+		Where("id = ? AND revoked_at IS NULL AND last_activity_at < ?", id, at.Add(-every)).
+		Updates(map[string]interface{}{"last_activity_at": at, "ip_address": ip}).Error
+}
+
+// RevokeSession cierra una sesión del usuario y revoca su familia de tokens
+func (r *tokenRepository) RevokeSession(userID, id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&domain.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSessionNotFound
+		}
+		return tx.Model(&domain.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", now).Error
+	})
+}
+
+// RevokeOtherSessions cierra todas las sesiones del usuario salvo keep y
+// devuelve cuántas se cerraron
+func (r *tokenRepository) RevokeOtherSessions(userID, keep uuid.UUID) (int64, error) {
+	var revoked int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&domain.Session{}).
+			Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keep).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		revoked = result.RowsAffected
+		return tx.Model(&domain.RefreshToken{}).
+			Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, keep).
+			Update("revoked_at", now).Error
+	})
+	return revoked, err
+}
+
+func (r *tokenRepository) RevokeAccessToken(token *domain.RevokedToken) error {
+	token.RevokedAt = time.Now()
+	return r.db.Save(token).Error
+}
+
+// ConsumeAccessToken revoca un token de un solo uso; si otra petición ya lo
+// gastó la clave primaria lo impide y devuelve ErrAccessTokenRevoked
+func (r *tokenRepository) ConsumeAccessToken(token *domain.RevokedToken) error {
+	token.RevokedAt = time.Now()
+	err := r.db.Create(token).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrAccessTokenRevoked
+	}
+	return err
+}
+
+func (r *tokenRepository) IsAccessTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&domain.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteExpired limpia tokens que ya no pueden usarse aunque no estén revocados
+func (r *tokenRepository) DeleteExpired(before time.Time) error {
+	if err := r.db.Where("expires_at < ?", before).Delete(&domain.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	if err := r.db.Where("expires_at < ?", before).Delete(&domain.RefreshToken{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("expires_at < ?", before).Delete(&domain.Session{}).Error
+}
@@ -1,19 +1,75 @@
 package repository
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 	"user-service/internal/domain"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// ErrUserNotFound indica que el usuario no existe o no está en el estado esperado
+// (p. ej. restaurar un usuario que no está borrado)
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrInvalidCursor indica un cursor mal formado o de otra ordenación
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// UserSortColumns son las columnas por las que se puede ordenar el listado
+var UserSortColumns = []string{"created_at", "email", "first_name", "last_name"}
+
+// UserFilter describe una página del listado de usuarios. CreatedFrom es
+// inclusivo y CreatedTo exclusivo; Search busca sin distinguir mayúsculas en
+// nombre, apellidos y email.
+type UserFilter struct {
+	Role        domain.UserRole
+	IsActive    *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Search      string
+	Deleted     bool   // solo usuarios borrados (papelera) en lugar de los activos
+	SortBy      string // una de UserSortColumns; por defecto created_at
+	Descending  bool
+	Limit       int
+	Cursor      string // NextCursor de la página anterior
+}
+
+// UserPage es una página del listado. Total cuenta todos los usuarios que
+// cumplen el filtro y NextCursor queda vacío en la última página.
+type UserPage struct {
+	Users      []domain.User
+	Total      int64
+	NextCursor string
+}
+
+// userCursor es el contenido del cursor opaco: la posición del último usuario
+// devuelto (valor de la columna de orden e id) y la ordenación que lo generó
+type userCursor struct {
+	SortBy     string    `json:"s"`
+	Descending bool      `json:"d"`
+	Value      string    `json:"v"`
+	ID         uuid.UUID `json:"id"`
+}
+
 type UserRepository interface {
 	Create(user *domain.User) error
 	GetByID(id uuid.UUID) (*domain.User, error)
 	GetByEmail(email string) (*domain.User, error)
+	EmailTaken(email string) (bool, error)
 	Update(user *domain.User) error
 	Delete(id uuid.UUID) error
-	GetAll() ([]domain.User, error)
+	Restore(id uuid.UUID) error
+	Erase(id uuid.UUID, at time.Time) error
+	List(filter UserFilter) (*UserPage, error)
+
+	RecordLoginFailure(id uuid.UUID, at time.Time, window time.Duration) (int, error)
+	LockUntil(id uuid.UUID, until time.Time) error
+	ResetLoginFailures(id uuid.UUID) error
 }
 
 type userRepository struct {
@@ -37,25 +93,307 @@ func (r *userRepository) GetByID(id uuid.UUID) (*domain.User, error) {
 	return &user, nil
 }
 
+// GetByEmail busca sin distinguir mayúsculas (índice único sobre LOWER(email),
+// migración 022)
 func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
 	var user domain.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.Where("LOWER(email) = ?", domain.NormalizeEmail(email)).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
+// EmailTaken incluye a los usuarios borrados, que conservan su email mientras
+// pueden restaurarse
+func (r *userRepository) EmailTaken(email string) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&domain.User{}).Where("LOWER(email) = ?", domain.NormalizeEmail(email)).Count(&count).Error
+	return count > 0, err
+}
+
 func (r *userRepository) Update(user *domain.User) error {
 	return r.db.Save(user).Error
 }
 
+// Delete hace un borrado lógico: el historial de colas, llamadas y relaciones
+// familiares sigue apuntando al usuario
 func (r *userRepository) Delete(id uuid.UUID) error {
-	return r.db.Delete(&domain.User{}, id).Error
+	result := r.db.Delete(&domain.User{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// Restore recupera un usuario borrado; los usuarios suprimidos (Erase) no se restauran
+func (r *userRepository) Restore(id uuid.UUID) error {
+	result := r.db.Unscoped().Model(&domain.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL AND erased_at IS NULL", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// Erase ejerce el derecho de supresión: anonimiza los datos personales del
+// usuario, sus notas en colas y llamadas, su ficha de paciente, el email de su
+// invitación y la IP y el navegador de sus sesiones y consentimientos, y borra
+// sus vínculos (familiares, identidades SSO, códigos MFA y tokens de cuenta).
+// Se conserva el registro mínimo exigido: id, rol, fechas de alta y supresión,
+// las llamadas con sus horas y duración, qué consintió y cuándo, y el registro
+// de auditoría (encadenado e inmutable).
+func (r *userRepository) Erase(id uuid.UUID, at time.Time) error {
+	erasedEmail := fmt.Sprintf("erased-%s@erased.invalid", id)
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&domain.User{}).
+			Where("id = ? AND erased_at IS NULL", id).
+			Updates(map[string]interface{}{
+				"email":              erasedEmail,
+				"password_hash":      "",
+				"first_name":         "Erased",
+				"last_name":          "User",
+				"is_active":          false,
+				"email_verified":     false,
+				"email_verified_at":  nil,
+				"mfa_enabled":        false,
+				"mfa_secret":         "",
+				"mfa_enabled_at":     nil,
+				"recording_consent":  false,
+				"livestream_consent": false,
+				"erased_at":          at,
+				"deleted_at":         gorm.Expr("COALESCE(deleted_at, ?)", at),
+				"updated_at":         at,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+
+		// Texto libre que puede contener datos clínicos o personales
+		err := tx.Model(&domain.QueueEntry{}).Where("patient_id = ?", id).
+			Updates(map[string]interface{}{"reason": "", "notes": ""}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&domain.Call{}).Where("patient_id = ?", id).
+			Updates(existingColumns(tx, &domain.Call{}, map[string]interface{}{
+				"notes":  "",
+				"reason": nil,
+			})).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&domain.Patient{}).Where("user_id = ?", id).
+			Updates(existingColumns(tx, &domain.Patient{}, map[string]interface{}{
+				"date_of_birth":     nil,
+				"emergency_contact": nil,
+				"insurance_info":    nil,
+				"family_members":    gorm.Expr("'{}'"),
+				"updated_at":        at,
+			})).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Exec("DELETE FROM family_relationships WHERE patient_id = ? OR family_member_id = ?", id, id).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&domain.ExternalIdentity{}, &domain.MFARecoveryCode{}, &domain.UserToken{}} {
+			if err := tx.Where("user_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		err = tx.Model(&domain.Session{}).Where("user_id = ?", id).
+			Updates(map[string]interface{}{"ip_address": "", "user_agent": "", "device": ""}).Error
+		if err != nil {
+			return err
+		}
+		// El trigger de consent_records (migración 023) solo admite esta
+		// actualización: vaciar la IP y el navegador sin tocar el resto
+		err = tx.Model(&domain.ConsentRecord{}).Where("user_id = ?", id).
+			Updates(map[string]interface{}{"ip_address": nil, "user_agent": nil}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&domain.Invitation{}).Where("user_id = ?", id).
+			Update("email", erasedEmail).Error
+	})
+}
+
+// existingColumns descarta las columnas que no tiene la tabla del modelo:
+// calls.reason y patients.family_members solo existen si la base se creó con
+// las migraciones SQL y no con AutoMigrate
+func existingColumns(tx *gorm.DB, model interface{}, values map[string]interface{}) map[string]interface{} {
+	existing := make(map[string]interface{}, len(values))
+	for column, value := range values {
+		if tx.Migrator().HasColumn(model, column) {
+			existing[column] = value
+		}
+	}
+	return existing
+}
+
+// List pagina por keyset sobre (columna de orden, id), así que el coste no crece
+// con la profundidad de la página y no se repiten ni saltan usuarios
+func (r *userRepository) List(filter UserFilter) (*UserPage, error) {
+	column := "created_at"
+	if filter.SortBy != "" {
+		if !validSortColumn(filter.SortBy) {
+			return nil, fmt.Errorf("invalid sort column %q", filter.SortBy)
+		}
+		column = filter.SortBy
+	}
+
+	page := &UserPage{}
+	if err := r.filtered(filter).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	direction, comparison := "ASC", ">"
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	query := r.filtered(filter)
+	if filter.Cursor != "" {
+		cursor, err := decodeUserCursor(filter.Cursor)
+		if err != nil || cursor.SortBy != column || cursor.Descending != filter.Descending {
+			return nil, ErrInvalidCursor
+		}
+		var value interface{} = cursor.Value
+		if column == "created_at" {
+			at, err := time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			value = at
+		}
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, comparison), value, cursor.ID)
+	}
+
+	// Se pide uno de más para saber si hay página siguiente
+	err := query.Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(filter.Limit + 1).
+		Find(&page.Users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.Users) > filter.Limit {
+		page.Users = page.Users[:filter.Limit]
+		last := page.Users[len(page.Users)-1]
+		page.NextCursor = encodeUserCursor(userCursor{
+			SortBy:     column,
+			Descending: filter.Descending,
+			Value:      sortValue(&last, column),
+			ID:         last.ID,
+		})
+	}
+	return page, nil
+}
+
+// filtered aplica los filtros del listado (sin cursor ni orden)
+func (r *userRepository) filtered(filter UserFilter) *gorm.DB {
+	query := r.db.Model(&domain.User{})
+	if filter.Deleted {
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.IsActive != nil {
+		query = query.Where("is_active = ?", *filter.IsActive)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+		query = query.Where(
+			"first_name ILIKE ? OR last_name ILIKE ? OR email ILIKE ? OR (first_name || ' ' || last_name) ILIKE ?",
+			pattern, pattern, pattern, pattern,
+		)
+	}
+	return query
+}
+
+// RecordLoginFailure suma un fallo de login y devuelve el total. Los fallos más
+// antiguos que window dejan de contar y el contador vuelve a empezar.
+func (r *userRepository) RecordLoginFailure(id uuid.UUID, at time.Time, window time.Duration) (int, error) {
+	var attempts int
+	err := r.db.Raw(`UPDATE users SET
+			failed_login_attempts = CASE WHEN last_failed_login_at > ? THEN failed_login_attempts + 1 ELSE 1 END,
+			last_failed_login_at = ?
+		WHERE id = ? RETURNING failed_login_attempts`, at.Add(-window), at, id).Scan(&attempts).Error
+	return attempts, err
+}
+
+func (r *userRepository) LockUntil(id uuid.UUID, until time.Time) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Update("locked_until", until).Error
+}
+
+func (r *userRepository) ResetLoginFailures(id uuid.UUID) error {
+	return r.db.Model(&domain.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"last_failed_login_at":  nil,
+		"locked_until":          nil,
+	}).Error
+}
+
+func validSortColumn(column string) bool {
+	for _, c := range UserSortColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+func sortValue(user *domain.User, column string) string {
+	switch column {
+	case "email":
+		return user.Email
+	case "first_name":
+		return user.FirstName
+	case "last_name":
+		return user.LastName
+	default:
+		return user.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+func encodeUserCursor(cursor userCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeUserCursor(value string) (*userCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor userCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
 }
 
-func (r *userRepository) GetAll() ([]domain.User, error) {
-	var users []domain.User
-	err := r.db.Find(&users).Error
-	return users, err
+// escapeLike escapa los comodines de LIKE para buscar el texto literal
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
 }
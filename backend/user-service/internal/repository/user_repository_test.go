@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"user-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB abre USER_TEST_DATABASE_URL, una base de datos con las migraciones
+// aplicadas; sin ella los tests de repositorio no se ejecutan
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("USER_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("USER_TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// TestEraseLeavesNoPersonalData comprueba que tras la supresión ninguna fila
+// ligada al usuario conserva su email, nombre, IP o navegador. audit_logs queda
+// fuera: es el registro encadenado que se conserva por obligación legal.
+func TestEraseLeavesNoPersonalData(t *testing.T) {
+	db := testDB(t)
+	repo := NewUserRepository(db)
+	now := time.Now()
+
+	user := &domain.User{
+		ID:        uuid.New(),
+		Email:     fmt.Sprintf("ana-%d@clinica.local", now.UnixNano()),
+		FirstName: "Ana",
+		LastName:  "Pérez",
+		Role:      domain.RolePatient,
+		IsActive:  true,
+	}
+	if err := repo.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	related := []interface{}{
+		&domain.Patient{
+			ID: uuid.New(), UserID: user.ID, MedicalRecordNumber: fmt.Sprintf("MRN-%d", now.UnixNano()),
+			EmergencyContact: &domain.EmergencyContact{Name: "Luis Pérez", Phone: "600000000"},
+		},
+		&domain.Session{
+			ID: uuid.New(), UserID: user.ID, IPAddress: "10.0.0.7", UserAgent: "Mozilla/5.0", Device: "Firefox en Linux",
+			CreatedAt: now, LastActivityAt: now, ExpiresAt: now.Add(time.Hour),
+		},
+		&domain.ConsentRecord{
+			ID: uuid.New(), UserID: user.ID, ConsentType: domain.ConsentTerms, Action: domain.ConsentGranted,
+			DocumentVersion: "2024-01", IPAddress: "10.0.0.7", UserAgent: "Mozilla/5.0", CreatedAt: now,
+		},
+		&domain.Invitation{
+			ID: uuid.New(), Email: user.Email, Role: domain.RolePatient, InvitedBy: uuid.New(),
+			ExpiresAt: now.Add(time.Hour), CreatedAt: now, AcceptedAt: &now, UserID: &user.ID,
+		},
+	}
+	for _, row := range related {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repo.Erase(user.ID, now); err != nil {
+		t.Fatalf("Erase() error = %v", err)
+	}
+
+	leftovers := []struct {
+		table string
+		where string
+	}{
+		{"users", "id = @id AND (email = @email OR first_name = 'Ana' OR last_name = 'Pérez')"},
+		{"patients", "user_id = @id AND (emergency_contact IS NOT NULL OR date_of_birth IS NOT NULL)"},
+		{"sessions", "user_id = @id AND (ip_address <> '' OR user_agent <> '' OR device <> '')"},
+		{"consent_records", "user_id = @id AND (ip_address IS NOT NULL OR user_agent IS NOT NULL)"},
+		{"invitations", "user_id = @id AND email = @email"},
+	}
+	for _, tt := range leftovers {
+		t.Run(tt.table, func(t *testing.T) {
+			var count int64
+			err := db.Table(tt.table).Where(tt.where, map[string]interface{}{"id": user.ID, "email": user.Email}).
+				Count(&count).Error
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 0 {
+				t.Errorf("%d %s rows still hold personal data", count, tt.table)
+			}
+		})
+	}
+
+	// El historial de consentimientos sigue sin poder reescribirse
+	err := db.Model(&domain.ConsentRecord{}).Where("user_id = ?", user.ID).Update("action", domain.ConsentWithdrawn).Error
+	if err == nil {
+		t.Error("consent_records accepted an update other than clearing IP and user agent")
+	}
+
+	if err := repo.Erase(user.ID, now); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("second Erase() error = %v, want ErrUserNotFound", err)
+	}
+}
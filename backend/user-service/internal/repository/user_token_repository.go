@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"errors"
+	"time"
+	"user-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrUserTokenInvalid indica que el token no existe, ya se usó o expiró
+var ErrUserTokenInvalid = errors.New("token is invalid or expired")
+
+type UserTokenRepository interface {
+	Create(token *domain.UserToken) error
+	Consume(hash string, purpose domain.UserTokenPurpose) (*domain.UserToken, error)
+	ConsumeForUser(userID uuid.UUID, purpose domain.UserTokenPurpose) error
+	DeleteExpired(before time.Time) error
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+// Create guarda el token e invalida los anteriores del mismo propósito, de modo
+// que solo el último enlace enviado al usuario sea válido
+func (r *userTokenRepository) Create(token *domain.UserToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// Consume marca el token como usado de forma atómica y lo devuelve
+func (r *userTokenRepository) Consume(hash string, purpose domain.UserTokenPurpose) (*domain.UserToken, error) {
+	var token domain.UserToken
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_hash = ? AND purpose = ?", hash, purpose).First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserTokenInvalid
+			}
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&domain.UserToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserTokenInvalid
+		}
+		token.UsedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ConsumeForUser gasta el token pendiente del usuario para tokens que no se
+// entregan a nadie (Create deja como mucho uno sin usar por propósito)
+func (r *userTokenRepository) ConsumeForUser(userID uuid.UUID, purpose domain.UserTokenPurpose) error {
+	now := time.Now()
+	result := r.db.Model(&domain.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", userID, purpose, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserTokenInvalid
+	}
+	return nil
+}
+
+func (r *userTokenRepository) DeleteExpired(before time.Time) error {
+	return r.db.Where("expires_at < ?", before).Delete(&domain.UserToken{}).Error
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"errors"
+	"time"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+// ErrTicketInvalid indica que el ticket no existe, ya se usó o expiró
+var ErrTicketInvalid = errors.New("websocket ticket is invalid or expired")
+
+type WebSocketTicketRepository interface {
+	Create(ticket *domain.WebSocketTicket) error
+	Consume(hash string) (*domain.WebSocketTicket, error)
+	DeleteExpired(before time.Time) error
+}
+
+type webSocketTicketRepository struct {
+	db *gorm.DB
+}
+
+func NewWebSocketTicketRepository(db *gorm.DB) WebSocketTicketRepository {
+	return &webSocketTicketRepository{db: db}
+}
+
+func (r *webSocketTicketRepository) Create(ticket *domain.WebSocketTicket) error {
+	return r.db.Create(ticket).Error
+}
+
+// Consume marca el ticket como usado de forma atómica y lo devuelve
+func (r *webSocketTicketRepository) Consume(hash string) (*domain.WebSocketTicket, error) {
+	var ticket domain.WebSocketTicket
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("ticket_hash = ?", hash).First(&ticket).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTicketInvalid
+			}
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&domain.WebSocketTicket{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", ticket.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTicketInvalid
+		}
+		ticket.UsedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+func (r *webSocketTicketRepository) DeleteExpired(before time.Time) error {
+	return r.db.Where("expires_at < ?", before).Delete(&domain.WebSocketTicket{}).Error
+}
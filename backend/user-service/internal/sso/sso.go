@@ -0,0 +1,327 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"user-service/internal/domain"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrEmailNotVerified indica que el IdP no garantiza el email del usuario
+	ErrEmailNotVerified = errors.New("identity provider did not verify the email")
+	// ErrDomainNotAllowed indica que el dominio del email no está en OIDC_ALLOWED_EMAIL_DOMAINS
+	ErrDomainNotAllowed = errors.New("email domain is not allowed for single sign-on")
+	errNonceMismatch    = errors.New("id token nonce does not match")
+	errMissingIDToken   = errors.New("token response has no id_token")
+)
+
+// Config es la configuración del proveedor OIDC de las clínicas
+type Config struct {
+	IssuerURL    string
+	InternalURL  string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string
+	DisplayName  string
+
+	// RoleMapping asigna roles a grupos del IdP; DefaultRole se usa si ningún grupo coincide
+	RoleMapping    map[string]domain.UserRole
+	DefaultRole    domain.UserRole
+	AllowedDomains []string
+}
+
+// Identity son los datos del usuario verificados en el ID token
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Groups        []string
+}
+
+// Client implementa el flujo authorization code + PKCE contra un IdP OIDC.
+// El discovery se hace en el primer uso para no depender del IdP al arrancar.
+type Client struct {
+	config     Config
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewFromEnv lee OIDC_* y devuelve nil si el SSO no está configurado
+func NewFromEnv() (*Client, error) {
+	issuer := os.Getenv("OIDC_ISSUER_URL")
+	if issuer == "" {
+		return nil, nil
+	}
+
+	config := Config{
+		IssuerURL:    issuer,
+		InternalURL:  os.Getenv("OIDC_INTERNAL_URL"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       splitList(os.Getenv("OIDC_SCOPES"), " "),
+		GroupsClaim:  os.Getenv("OIDC_GROUPS_CLAIM"),
+		DisplayName:  os.Getenv("OIDC_DISPLAY_NAME"),
+		RoleMapping:  make(map[string]domain.UserRole),
+		DefaultRole:  domain.UserRole(os.Getenv("OIDC_DEFAULT_ROLE")),
+	}
+	for _, domainName := range splitList(os.Getenv("OIDC_ALLOWED_EMAIL_DOMAINS"), ",") {
+		config.AllowedDomains = append(config.AllowedDomains, strings.ToLower(strings.TrimPrefix(domainName, "@")))
+	}
+
+	if config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER_URL is set")
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{oidc.ScopeOpenID, "email", "profile", "groups"}
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if config.DisplayName == "" {
+		config.DisplayName = "SSO"
+	}
+
+	// OIDC_ROLE_MAPPING="grupo=rol,otro-grupo=rol"
+	for _, pair := range splitList(os.Getenv("OIDC_ROLE_MAPPING"), ",") {
+		group, role, ok := strings.Cut(pair, "=")
+		if !ok || !validRole(domain.UserRole(role)) {
+			return nil, fmt.Errorf("invalid OIDC_ROLE_MAPPING entry %q", pair)
+		}
+		config.RoleMapping[strings.TrimSpace(group)] = domain.UserRole(strings.TrimSpace(role))
+	}
+	if config.DefaultRole != "" && !validRole(config.DefaultRole) {
+		return nil, fmt.Errorf("invalid OIDC_DEFAULT_ROLE %q", config.DefaultRole)
+	}
+
+	return NewClient(config), nil
+}
+
+func NewClient(config Config) *Client {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	if config.InternalURL != "" {
+		// El IdP se anuncia con una URL pública (la que abre el navegador) pero el
+		// servicio lo alcanza por otra, p. ej. el nombre del contenedor en Docker
+		httpClient.Transport = &rewriteTransport{from: config.IssuerURL, to: config.InternalURL, base: http.DefaultTransport}
+	}
+
+	return &Client{config: config, httpClient: httpClient}
+}
+
+// DisplayName es el nombre del proveedor que se muestra en el login
+func (c *Client) DisplayName() string {
+	return c.config.DisplayName
+}
+
+// SecureCallback indica si el callback se sirve por HTTPS (cookies con Secure)
+func (c *Client) SecureCallback() bool {
+	return strings.HasPrefix(c.config.RedirectURL, "https://")
+}
+
+// AuthCodeURL construye la URL de autorización con state, nonce y el reto PKCE
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	oauthConfig, _, err := c.setup(ctx)
+	if err != nil {
+		return "", err
+	}
+	return oauthConfig.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange canjea el código, valida el ID token (firma, audiencia, nonce) y
+// devuelve la identidad del usuario
+func (c *Client) Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error) {
+	oauthConfig, idVerifier, err := c.setup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = oidc.ClientContext(ctx, c.httpClient)
+	token, err := oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errMissingIDToken
+	}
+	idToken, err := idVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify id token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errNonceMismatch
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Issuer:     idToken.Issuer,
+		Subject:    idToken.Subject,
+		Email:      domain.NormalizeEmail(stringClaim(claims, "email")),
+		GivenName:  stringClaim(claims, "given_name"),
+		FamilyName: stringClaim(claims, "family_name"),
+		Groups:     listClaim(claims, c.config.GroupsClaim),
+	}
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+	if !c.domainAllowed(identity.Email) {
+		return nil, ErrDomainNotAllowed
+	}
+	return identity, nil
+}
+
+// RoleFor traduce los grupos del IdP al rol con más privilegios que tenga asignado
+func (c *Client) RoleFor(groups []string) (domain.UserRole, bool) {
+	var best domain.UserRole
+	for _, group := range groups {
+		if role, ok := c.config.RoleMapping[group]; ok && rolePriority(role) > rolePriority(best) {
+			best = role
+		}
+	}
+	if best != "" {
+		return best, true
+	}
+	if c.config.DefaultRole != "" {
+		return c.config.DefaultRole, true
+	}
+	return "", false
+}
+
+func (c *Client) setup(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.provider == nil {
+		discoveryCtx, cancel := context.WithTimeout(oidc.ClientContext(ctx, c.httpClient), 10*time.Second)
+		defer cancel()
+
+		provider, err := oidc.NewProvider(discoveryCtx, c.config.IssuerURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("oidc discovery: %w", err)
+		}
+		c.provider = provider
+		c.verifier = provider.Verifier(&oidc.Config{ClientID: c.config.ClientID})
+		log.Printf("OIDC provider %s discovered", c.config.IssuerURL)
+	}
+
+	return &oauth2.Config{
+		ClientID:     c.config.ClientID,
+		ClientSecret: c.config.ClientSecret,
+		RedirectURL:  c.config.RedirectURL,
+		Endpoint:     c.provider.Endpoint(),
+		Scopes:       c.config.Scopes,
+	}, c.verifier, nil
+}
+
+func (c *Client) domainAllowed(email string) bool {
+	if len(c.config.AllowedDomains) == 0 {
+		return true
+	}
+	_, domainName, _ := strings.Cut(email, "@")
+	for _, allowed := range c.config.AllowedDomains {
+		if domainName == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteTransport redirige las peticiones dirigidas a la URL pública del IdP a su URL interna
+type rewriteTransport struct {
+	from string
+	to   string
+	base http.RoundTripper
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target := req.URL.String()
+	if strings.HasPrefix(target, t.from) {
+		rewritten, err := url.Parse(t.to + strings.TrimPrefix(target, t.from))
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.URL = rewritten
+		req.Host = ""
+	}
+	return t.base.RoundTrip(req)
+}
+
+func rolePriority(role domain.UserRole) int {
+	switch role {
+	case domain.RoleAdmin:
+		return 4
+	case domain.RoleEmployee:
+		return 3
+	case domain.RoleFamily:
+		return 2
+	case domain.RolePatient:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func validRole(role domain.UserRole) bool {
+	return rolePriority(role) > 0
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return strings.TrimSpace(value)
+}
+
+// listClaim admite el claim de grupos como lista o como cadena separada por comas
+func listClaim(claims map[string]interface{}, name string) []string {
+	switch value := claims[name].(type) {
+	case []interface{}:
+		groups := make([]string, 0, len(value))
+		for _, item := range value {
+			if group, ok := item.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+		return groups
+	case string:
+		return splitList(value, ",")
+	default:
+		return nil
+	}
+}
+
+func splitList(value, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
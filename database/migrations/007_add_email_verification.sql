@@ -0,0 +1,30 @@
+-- Verificación de email y tokens de un solo uso (recuperación de contraseña / verificación).
+-- Las cuentas existentes se dan por verificadas para no bloquearlas en la cola,
+-- solo al añadir la columna: si la migración se repite, las cuentas pendientes
+-- de verificar siguen pendientes.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'email_verified'
+    ) THEN
+        ALTER TABLE users ADD COLUMN email_verified BOOLEAN DEFAULT FALSE;
+        UPDATE users SET email_verified = TRUE, email_verified_at = CURRENT_TIMESTAMP;
+    END IF;
+END $$;
+
+COMMENT ON COLUMN users.email_verified IS 'El usuario confirmó su dirección de correo; necesario para unirse a la cola';
+
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(30) NOT NULL, -- 'password_reset', 'email_verification'
+    token_hash VARCHAR(64) UNIQUE NOT NULL, -- SHA-256 del token enviado por correo
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id ON user_tokens(user_id);
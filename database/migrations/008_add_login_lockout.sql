@@ -0,0 +1,9 @@
+-- Protección contra fuerza bruta: fallos de login por cuenta y bloqueo temporal
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_login_attempts INTEGER DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_failed_login_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+
+COMMENT ON COLUMN users.locked_until IS 'Bloqueo temporal tras superar LOGIN_MAX_ATTEMPTS; un admin puede levantarlo antes';
+
+-- Bloqueos y patrones sospechosos se registran en audit_logs
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);
@@ -0,0 +1,12 @@
+-- Claves asimétricas de firma de JWT publicadas en /.well-known/jwks.json
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id VARCHAR(64) PRIMARY KEY, -- kid: huella de la clave pública
+    algorithm VARCHAR(10) NOT NULL, -- 'RS256', 'EdDSA'
+    private_key TEXT NOT NULL, -- PKCS#8 cifrado con MFA_ENCRYPTION_KEY
+    public_key TEXT NOT NULL, -- PKIX en base64
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    rotated_at TIMESTAMP, -- deja de firmar
+    retires_at TIMESTAMP -- deja de publicarse en el JWKS
+);
+
+CREATE INDEX IF NOT EXISTS idx_signing_keys_retires_at ON signing_keys(retires_at);
@@ -0,0 +1,13 @@
+-- Tickets de un solo uso (30s) para abrir el WebSocket sin exponer el access token en la URL
+CREATE TABLE IF NOT EXISTS web_socket_tickets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id UUID, -- familia de refresh tokens del JWT con el que se pidió
+    ticket_hash VARCHAR(64) UNIQUE NOT NULL, -- SHA-256 del ticket
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_web_socket_tickets_user_id ON web_socket_tickets(user_id);
+CREATE INDEX IF NOT EXISTS idx_web_socket_tickets_expires_at ON web_socket_tickets(expires_at);
@@ -0,0 +1,33 @@
+-- Cuentas de servicio para integraciones (p. ej. el sistema de citas) y sus API keys
+CREATE TABLE IF NOT EXISTS service_accounts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    is_active BOOLEAN DEFAULT true,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    service_account_id UUID NOT NULL REFERENCES service_accounts(id) ON DELETE CASCADE,
+    name VARCHAR(100),
+    prefix VARCHAR(16) UNIQUE NOT NULL, -- parte pública de la key: vk_<prefix>_<secreto>
+    key_hash VARCHAR(64) UNIQUE NOT NULL, -- SHA-256 de la key completa
+    scopes TEXT NOT NULL, -- separados por espacios, p. ej. 'queue:read calls:write'
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(45),
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_service_account_id ON api_keys(service_account_id);
+
+-- El principal de cada entrada de auditoría puede ser un usuario o una cuenta de servicio
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS principal_type VARCHAR(20) NOT NULL DEFAULT 'user';
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS service_account_id UUID REFERENCES service_accounts(id);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_service_account_id ON audit_logs(service_account_id);
@@ -0,0 +1,15 @@
+-- Sesiones por dispositivo: una por familia de refresh tokens (claim sid del JWT)
+CREATE TABLE IF NOT EXISTS sessions (
+    id UUID PRIMARY KEY, -- family_id de refresh_tokens
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    device VARCHAR(100), -- p. ej. 'Chrome on Windows', derivado del user agent
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_activity_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL, -- caducidad del último refresh token de la familia
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
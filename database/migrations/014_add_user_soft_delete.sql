@@ -0,0 +1,7 @@
+-- Borrado lógico de usuarios: calls, queue_entries y family_relationships siguen
+-- apuntando a la fila. erased_at marca el ejercicio del derecho de supresión
+-- (datos personales anonimizados, no restaurable).
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS erased_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at);
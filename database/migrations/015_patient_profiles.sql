@@ -0,0 +1,4 @@
+-- Una ficha clínica por usuario: la API de fichas (/api/users/:id/patient-profile)
+-- la busca por user_id
+ALTER TABLE patients ALTER COLUMN user_id SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_patients_user_id ON patients(user_id);
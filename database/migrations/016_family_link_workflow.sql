@@ -0,0 +1,10 @@
+-- Vínculos familiares con aprobación del paciente. Los vínculos existentes se
+-- consideran aprobados; call-service solo da acceso con status = 'approved'.
+ALTER TABLE family_relationships ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'approved'
+    CHECK (status IN ('pending', 'approved', 'rejected', 'revoked'));
+ALTER TABLE family_relationships ADD COLUMN IF NOT EXISTS requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE family_relationships ADD COLUMN IF NOT EXISTS responded_at TIMESTAMP;
+ALTER TABLE family_relationships ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP;
+ALTER TABLE family_relationships ADD COLUMN IF NOT EXISTS revoked_by UUID REFERENCES users(id);
+
+CREATE INDEX IF NOT EXISTS idx_family_relationships_status ON family_relationships(status);
@@ -0,0 +1,31 @@
+-- Historial de consentimientos: cada concesión o retirada es una fila inmutable
+-- con la versión del documento aceptado. Las columnas de users (migración 006)
+-- guardan el estado vigente.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS consent_version VARCHAR(50);
+
+CREATE TABLE IF NOT EXISTS consent_records (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    consent_type VARCHAR(20) NOT NULL CHECK (consent_type IN ('terms', 'recording', 'livestream')),
+    action VARCHAR(20) NOT NULL CHECK (action IN ('granted', 'withdrawn', 'expired')),
+    document_version VARCHAR(50) NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_consent_records_user_id ON consent_records(user_id);
+
+-- Los registros son la prueba del consentimiento: no se modifican ni se borran
+CREATE OR REPLACE FUNCTION prevent_consent_record_changes() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'consent_records is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS consent_records_immutable ON consent_records;
+CREATE TRIGGER consent_records_immutable
+    BEFORE UPDATE OR DELETE ON consent_records
+    FOR EACH ROW EXECUTE FUNCTION prevent_consent_record_changes();
+
+COMMENT ON COLUMN users.consent_version IS 'Versión del documento de consentimiento aceptada por el usuario';
@@ -0,0 +1,4 @@
+-- Consultas de cumplimiento sobre audit_logs: por recurso accedido (p. ej. las
+-- grabaciones de una llamada) y paginación por (timestamp, id)
+CREATE INDEX IF NOT EXISTS idx_audit_logs_resource ON audit_logs(resource_type, resource_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_timestamp_id ON audit_logs(timestamp DESC, id DESC);
@@ -0,0 +1,112 @@
+-- Cadena de hashes en audit_logs: cada fila guarda el hash de su contenido
+-- encadenado al de la fila anterior (prev_hash). El trigger asigna chain_seq y
+-- los hashes en el INSERT, así que user-service y call-service no calculan nada.
+-- audit_checkpoints guarda cabezas de la cadena firmadas con Ed25519 por
+-- user-service; cmd/audit-verify recorre la cadena y comprueba las firmas.
+--
+-- audit_log_content debe coincidir con auditchain.Content (user-service).
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS chain_seq BIGINT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS row_hash VARCHAR(64);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_chain_seq ON audit_logs(chain_seq);
+
+-- Última fila de la cadena; su bloqueo serializa las inserciones concurrentes
+CREATE TABLE IF NOT EXISTS audit_chain_head (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    seq BIGINT NOT NULL,
+    hash VARCHAR(64) NOT NULL
+);
+INSERT INTO audit_chain_head (id, seq, hash) VALUES (1, 0, '') ON CONFLICT (id) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    chain_seq BIGINT NOT NULL,
+    row_hash VARCHAR(64) NOT NULL,
+    key_id VARCHAR(32) NOT NULL,
+    signature TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_checkpoints_chain_seq ON audit_checkpoints(chain_seq);
+
+-- Cada campo como "<bytes>:<valor>" (o "-" si es NULL) para que no haya dos
+-- contenidos distintos con la misma representación
+CREATE OR REPLACE FUNCTION audit_chain_field(value TEXT) RETURNS TEXT AS $$
+    SELECT CASE WHEN value IS NULL THEN '-' ELSE octet_length(value)::text || ':' || value END;
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION audit_log_content(r audit_logs) RETURNS TEXT AS $$
+    SELECT audit_chain_field(r.chain_seq::text)
+        || audit_chain_field(r.id::text)
+        || audit_chain_field(r.principal_type)
+        || audit_chain_field(r.user_id::text)
+        || audit_chain_field(r.service_account_id::text)
+        || audit_chain_field(r.action)
+        || audit_chain_field(r.resource_type)
+        || audit_chain_field(r.resource_id)
+        || audit_chain_field(r.ip_address::text)
+        || audit_chain_field(r.user_agent)
+        || audit_chain_field(r.request_details::text)
+        || audit_chain_field(r.response_status::text)
+        || audit_chain_field(extract(epoch FROM r.timestamp)::text)
+        || audit_chain_field(r.session_id);
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION audit_log_hash(prev TEXT, r audit_logs) RETURNS TEXT AS $$
+    SELECT encode(sha256(convert_to(prev || E'\n' || audit_log_content(r), 'UTF8')), 'hex');
+$$ LANGUAGE sql STABLE;
+
+-- Encadenar las filas existentes en orden de llegada
+DO $$
+DECLARE
+    entry audit_logs;
+    head_seq BIGINT;
+    head_hash TEXT;
+BEGIN
+    SELECT seq, hash INTO head_seq, head_hash FROM audit_chain_head WHERE id = 1 FOR UPDATE;
+    FOR entry IN SELECT * FROM audit_logs WHERE chain_seq IS NULL ORDER BY timestamp, id LOOP
+        head_seq := head_seq + 1;
+        entry.chain_seq := head_seq;
+        entry.prev_hash := head_hash;
+        head_hash := audit_log_hash(head_hash, entry);
+        UPDATE audit_logs SET chain_seq = head_seq, prev_hash = entry.prev_hash, row_hash = head_hash
+            WHERE id = entry.id;
+    END LOOP;
+    UPDATE audit_chain_head SET seq = head_seq, hash = head_hash WHERE id = 1;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION audit_logs_chain() RETURNS TRIGGER AS $$
+DECLARE
+    head_seq BIGINT;
+    head_hash TEXT;
+BEGIN
+    SELECT seq, hash INTO head_seq, head_hash FROM audit_chain_head WHERE id = 1 FOR UPDATE;
+    NEW.chain_seq := head_seq + 1;
+    NEW.prev_hash := head_hash;
+    NEW.row_hash := audit_log_hash(head_hash, NEW);
+    UPDATE audit_chain_head SET seq = NEW.chain_seq, hash = NEW.row_hash WHERE id = 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_logs_chain ON audit_logs;
+CREATE TRIGGER audit_logs_chain
+    BEFORE INSERT ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION audit_logs_chain();
+
+-- El historial no se modifica; cualquier cambio hecho saltándose esto lo detecta audit-verify
+CREATE OR REPLACE FUNCTION prevent_audit_log_changes() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_logs_immutable ON audit_logs;
+CREATE TRIGGER audit_logs_immutable
+    BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION prevent_audit_log_changes();
+
+DROP TRIGGER IF EXISTS audit_checkpoints_immutable ON audit_checkpoints;
+CREATE TRIGGER audit_checkpoints_immutable
+    BEFORE UPDATE OR DELETE ON audit_checkpoints
+    FOR EACH ROW EXECUTE FUNCTION prevent_audit_log_changes();
@@ -0,0 +1,50 @@
+-- Las tablas de auditoría pasan a un rol propietario sin login. Los servicios
+-- escriben con vincula_audit_writer (AUDIT_DATABASE_URL), que solo puede leer e
+-- insertar: sin ser propietario no puede hacer TRUNCATE, desactivar o borrar los
+-- triggers ni reescribir la cabeza de la cadena.
+--
+-- Debe ejecutarla un superusuario. El rol de escritura se crea sin login; para
+-- activarlo (lo hace 020_audit_writer_login.sh en docker compose):
+--   ALTER ROLE vincula_audit_writer LOGIN PASSWORD '...';
+-- El rol de DATABASE_URL no debe ser superusuario en producción: un superusuario
+-- puede saltarse todo esto y solo los checkpoints firmados lo detectarían.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'vincula_audit_owner') THEN
+        CREATE ROLE vincula_audit_owner NOLOGIN;
+    END IF;
+    IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'vincula_audit_writer') THEN
+        CREATE ROLE vincula_audit_writer NOLOGIN;
+    END IF;
+END;
+$$;
+
+GRANT USAGE ON SCHEMA public TO vincula_audit_writer;
+
+ALTER TABLE audit_logs OWNER TO vincula_audit_owner;
+ALTER TABLE audit_checkpoints OWNER TO vincula_audit_owner;
+ALTER TABLE audit_chain_head OWNER TO vincula_audit_owner;
+ALTER FUNCTION audit_logs_chain() OWNER TO vincula_audit_owner;
+ALTER FUNCTION prevent_audit_log_changes() OWNER TO vincula_audit_owner;
+
+-- El trigger de la cadena actualiza audit_chain_head con los permisos del propietario
+ALTER FUNCTION audit_logs_chain() SECURITY DEFINER SET search_path = public;
+
+REVOKE ALL ON audit_logs, audit_checkpoints, audit_chain_head FROM PUBLIC;
+GRANT SELECT, INSERT ON audit_logs, audit_checkpoints TO vincula_audit_writer;
+GRANT SELECT ON audit_chain_head TO vincula_audit_writer;
+
+-- TRUNCATE no dispara los triggers por fila: se bloquea con uno por sentencia
+DROP TRIGGER IF EXISTS audit_logs_no_truncate ON audit_logs;
+CREATE TRIGGER audit_logs_no_truncate
+    BEFORE TRUNCATE ON audit_logs
+    FOR EACH STATEMENT EXECUTE FUNCTION prevent_audit_log_changes();
+
+DROP TRIGGER IF EXISTS audit_checkpoints_no_truncate ON audit_checkpoints;
+CREATE TRIGGER audit_checkpoints_no_truncate
+    BEFORE TRUNCATE ON audit_checkpoints
+    FOR EACH STATEMENT EXECUTE FUNCTION prevent_audit_log_changes();
+
+-- Hora de firma del checkpoint: forma parte del mensaje firmado (ver auditchain)
+-- para que audit-verify detecte checkpoints antiguos presentados como recientes
+ALTER TABLE audit_checkpoints ADD COLUMN IF NOT EXISTS signed_at BIGINT;
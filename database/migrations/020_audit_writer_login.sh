@@ -0,0 +1,12 @@
+#!/bin/sh
+# Activa el login de vincula_audit_writer (020_audit_log_roles.sql) con
+# AUDIT_DB_PASSWORD. Lo ejecuta la imagen de postgres al crear la base de datos.
+set -e
+
+if [ -n "$AUDIT_DB_PASSWORD" ]; then
+	psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" -v password="$AUDIT_DB_PASSWORD" <<-'EOSQL'
+		ALTER ROLE vincula_audit_writer LOGIN PASSWORD :'password';
+	EOSQL
+else
+	echo "AUDIT_DB_PASSWORD no configurada: vincula_audit_writer queda sin login"
+fi
@@ -0,0 +1,10 @@
+-- Identidades SSO que crearon la cuenta (aprovisionamiento JIT): solo en esas
+-- cuentas se sincroniza el rol con los grupos del IdP. Las vinculadas por email
+-- a una cuenta local conservan el rol que tenían.
+ALTER TABLE user_identities ADD COLUMN IF NOT EXISTS provisioned BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Las cuentas aprovisionadas hasta ahora son las que no tienen contraseña
+UPDATE user_identities i
+SET provisioned = TRUE
+FROM users u
+WHERE u.id = i.user_id AND u.password_hash = '';
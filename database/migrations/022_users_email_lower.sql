@@ -0,0 +1,26 @@
+-- Los emails se guardan normalizados (sin espacios, en minúsculas) y se buscan
+-- por LOWER(email): dos cuentas no pueden diferir solo en mayúsculas.
+
+-- Normaliza las cuentas existentes salvo las que chocarían con otra
+UPDATE users u
+SET email = LOWER(TRIM(u.email))
+WHERE u.email <> LOWER(TRIM(u.email))
+  AND NOT EXISTS (
+      SELECT 1 FROM users o
+      WHERE o.id <> u.id AND LOWER(TRIM(o.email)) = LOWER(TRIM(u.email))
+  );
+
+-- Las cuentas que solo difieren en mayúsculas hay que fusionarlas o renombrarlas
+-- a mano: sin eso no se puede crear el índice y la migración se detiene
+DO $$
+DECLARE
+    duplicated TEXT;
+BEGIN
+    SELECT string_agg(email, ', ') INTO duplicated
+    FROM (SELECT LOWER(TRIM(email)) AS email FROM users GROUP BY 1 HAVING COUNT(*) > 1) d;
+    IF duplicated IS NOT NULL THEN
+        RAISE EXCEPTION 'Cuentas con el mismo email salvo mayúsculas: %', duplicated;
+    END IF;
+END $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email));
@@ -0,0 +1,19 @@
+-- Derecho de supresión: consent_records sigue siendo inmutable salvo para vaciar
+-- la IP y el navegador de una fila. Qué se consintió, en qué versión y cuándo
+-- se conserva como prueba; los datos personales no.
+CREATE OR REPLACE FUNCTION prevent_consent_record_changes() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+        AND NEW.ip_address IS NULL AND NEW.user_agent IS NULL
+        AND NEW.id = OLD.id
+        AND NEW.user_id = OLD.user_id
+        AND NEW.consent_type = OLD.consent_type
+        AND NEW.action = OLD.action
+        AND NEW.document_version = OLD.document_version
+        AND NEW.created_at = OLD.created_at
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'consent_records is append-only';
+END;
+$$ LANGUAGE plpgsql;
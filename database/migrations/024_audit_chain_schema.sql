@@ -0,0 +1,105 @@
+-- Las funciones de la cadena de auditoría pasan a un esquema propio de
+-- vincula_audit_owner. audit_logs_chain es SECURITY DEFINER: si otro rol pudiera
+-- reemplazar las funciones a las que llama (audit_log_hash, audit_log_content,
+-- audit_chain_field), o crear en public una sobrecarga más exacta (por ejemplo
+-- audit_chain_field(varchar)), su código se ejecutaría con los permisos del
+-- propietario. En el esquema audit solo crea y reemplaza el propietario, y las
+-- funciones se llaman cualificadas con un search_path que no incluye public.
+--
+-- Debe ejecutarla un superusuario, después de 019 y 020.
+CREATE SCHEMA IF NOT EXISTS audit AUTHORIZATION vincula_audit_owner;
+ALTER SCHEMA audit OWNER TO vincula_audit_owner;
+REVOKE ALL ON SCHEMA audit FROM PUBLIC;
+-- Consultar audit.audit_log_hash (audit-verify, tests) no da permiso para crear
+GRANT USAGE ON SCHEMA audit TO PUBLIC;
+
+-- El resto de roles tampoco crea objetos en public (PostgreSQL < 15 lo permite
+-- por defecto)
+REVOKE CREATE ON SCHEMA public FROM PUBLIC;
+
+-- Mismas definiciones que en 019, cualificadas
+CREATE OR REPLACE FUNCTION audit.audit_chain_field(value TEXT) RETURNS TEXT AS $$
+    SELECT CASE WHEN value IS NULL THEN '-' ELSE octet_length(value)::text || ':' || value END;
+$$ LANGUAGE sql IMMUTABLE SET search_path = pg_catalog, pg_temp;
+
+CREATE OR REPLACE FUNCTION audit.audit_log_content(r public.audit_logs) RETURNS TEXT AS $$
+    SELECT audit.audit_chain_field(r.chain_seq::text)
+        || audit.audit_chain_field(r.id::text)
+        || audit.audit_chain_field(r.principal_type)
+        || audit.audit_chain_field(r.user_id::text)
+        || audit.audit_chain_field(r.service_account_id::text)
+        || audit.audit_chain_field(r.action)
+        || audit.audit_chain_field(r.resource_type)
+        || audit.audit_chain_field(r.resource_id)
+        || audit.audit_chain_field(r.ip_address::text)
+        || audit.audit_chain_field(r.user_agent)
+        || audit.audit_chain_field(r.request_details::text)
+        || audit.audit_chain_field(r.response_status::text)
+        || audit.audit_chain_field(extract(epoch FROM r.timestamp)::text)
+        || audit.audit_chain_field(r.session_id);
+$$ LANGUAGE sql STABLE SET search_path = pg_catalog, pg_temp;
+
+CREATE OR REPLACE FUNCTION audit.audit_log_hash(prev TEXT, r public.audit_logs) RETURNS TEXT AS $$
+    SELECT encode(sha256(convert_to(prev || E'\n' || audit.audit_log_content(r), 'UTF8')), 'hex');
+$$ LANGUAGE sql STABLE SET search_path = pg_catalog, pg_temp;
+
+CREATE OR REPLACE FUNCTION audit.audit_logs_chain() RETURNS TRIGGER AS $$
+DECLARE
+    head_seq BIGINT;
+    head_hash TEXT;
+BEGIN
+    SELECT seq, hash INTO head_seq, head_hash FROM public.audit_chain_head WHERE id = 1 FOR UPDATE;
+    NEW.chain_seq := head_seq + 1;
+    NEW.prev_hash := head_hash;
+    NEW.row_hash := audit.audit_log_hash(head_hash, NEW);
+    UPDATE public.audit_chain_head SET seq = NEW.chain_seq, hash = NEW.row_hash WHERE id = 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql SECURITY DEFINER SET search_path = pg_catalog, pg_temp;
+
+CREATE OR REPLACE FUNCTION audit.prevent_audit_log_changes() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql SET search_path = pg_catalog, pg_temp;
+
+ALTER FUNCTION audit.audit_chain_field(TEXT) OWNER TO vincula_audit_owner;
+ALTER FUNCTION audit.audit_log_content(public.audit_logs) OWNER TO vincula_audit_owner;
+ALTER FUNCTION audit.audit_log_hash(TEXT, public.audit_logs) OWNER TO vincula_audit_owner;
+ALTER FUNCTION audit.audit_logs_chain() OWNER TO vincula_audit_owner;
+ALTER FUNCTION audit.prevent_audit_log_changes() OWNER TO vincula_audit_owner;
+
+REVOKE ALL ON FUNCTION audit.audit_logs_chain(), audit.prevent_audit_log_changes() FROM PUBLIC;
+
+-- Los triggers pasan a las funciones nuevas (mismos nombres que en 019 y 020)
+DROP TRIGGER IF EXISTS audit_logs_chain ON audit_logs;
+CREATE TRIGGER audit_logs_chain
+    BEFORE INSERT ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION audit.audit_logs_chain();
+
+DROP TRIGGER IF EXISTS audit_logs_immutable ON audit_logs;
+CREATE TRIGGER audit_logs_immutable
+    BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION audit.prevent_audit_log_changes();
+
+DROP TRIGGER IF EXISTS audit_checkpoints_immutable ON audit_checkpoints;
+CREATE TRIGGER audit_checkpoints_immutable
+    BEFORE UPDATE OR DELETE ON audit_checkpoints
+    FOR EACH ROW EXECUTE FUNCTION audit.prevent_audit_log_changes();
+
+DROP TRIGGER IF EXISTS audit_logs_no_truncate ON audit_logs;
+CREATE TRIGGER audit_logs_no_truncate
+    BEFORE TRUNCATE ON audit_logs
+    FOR EACH STATEMENT EXECUTE FUNCTION audit.prevent_audit_log_changes();
+
+DROP TRIGGER IF EXISTS audit_checkpoints_no_truncate ON audit_checkpoints;
+CREATE TRIGGER audit_checkpoints_no_truncate
+    BEFORE TRUNCATE ON audit_checkpoints
+    FOR EACH STATEMENT EXECUTE FUNCTION audit.prevent_audit_log_changes();
+
+-- Las versiones de public ya no las usa ningún trigger
+DROP FUNCTION IF EXISTS public.audit_logs_chain();
+DROP FUNCTION IF EXISTS public.prevent_audit_log_changes();
+DROP FUNCTION IF EXISTS public.audit_log_hash(TEXT, public.audit_logs);
+DROP FUNCTION IF EXISTS public.audit_log_content(public.audit_logs);
+DROP FUNCTION IF EXISTS public.audit_chain_field(TEXT);
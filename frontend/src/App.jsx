@@ -6,6 +6,11 @@ import { theme } from './theme/theme';
 import { useAuthStore } from './stores/authStore';
 import { Login } from './components/auth/Login';
 import { Register } from './components/auth/Register';
+import { AcceptInvitation } from './components/auth/AcceptInvitation';
+import { ForgotPassword } from './components/auth/ForgotPassword';
+import { ResetPassword } from './components/auth/ResetPassword';
+import { VerifyEmail } from './components/auth/VerifyEmail';
+import { SSOCallback } from './components/auth/SSOCallback';
 import { LandingPage } from './components/landing/LandingPage';
 import PatientDashboard from './components/dashboard/PatientDashboard';
 import { EmployeeDashboard } from './components/dashboard/EmployeeDashboard';
@@ -102,6 +107,19 @@ function App() {
               <Register />
             </PublicRoute>
           } />
+          <Route path="/accept-invitation" element={
+            <PublicRoute>
+              <AcceptInvitation />
+            </PublicRoute>
+          } />
+          <Route path="/forgot-password" element={
+            <PublicRoute>
+              <ForgotPassword />
+            </PublicRoute>
+          } />
+          <Route path="/reset-password" element={<ResetPassword />} />
+          <Route path="/verify-email" element={<VerifyEmail />} />
+          <Route path="/sso/callback" element={<SSOCallback />} />
 
           {/* Redirección general al dashboard */}
           <Route path="/dashboard" element={
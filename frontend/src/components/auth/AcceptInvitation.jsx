@@ -0,0 +1,211 @@
+import React, { useEffect, useState } from 'react';
+import { useNavigate, useSearchParams, Link } from 'react-router-dom';
+import {
+  Container,
+  Paper,
+  Box,
+  TextField,
+  Button,
+  Typography,
+  Alert,
+  Stack,
+  Divider,
+  CircularProgress,
+  Checkbox,
+  FormControlLabel,
+} from '@mui/material';
+import {
+  Lock,
+  Person,
+  PersonAdd,
+} from '@mui/icons-material';
+import { useAuthStore } from '../../stores/authStore';
+
+// Alta de cuentas de personal a partir del enlace de invitación enviado por un admin
+export const AcceptInvitation = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+
+  const [formData, setFormData] = useState({
+    firstName: '',
+    lastName: '',
+    password: '',
+    confirmPassword: '',
+    acceptTerms: false,
+  });
+  const [loading, setLoading] = useState(false);
+  const [formError, setFormError] = useState(null);
+  const [terms, setTerms] = useState(null);
+
+  const navigate = useNavigate();
+  const { acceptInvitation, getConsentTerms, error } = useAuthStore();
+
+  useEffect(() => {
+    getConsentTerms().then(setTerms).catch(() => setTerms(null));
+  }, [getConsentTerms]);
+
+  const handleChange = (e) => {
+    const { name, value, type, checked } = e.target;
+    setFormData({ ...formData, [name]: type === 'checkbox' ? checked : value });
+  };
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setFormError(null);
+
+    if (formData.password !== formData.confirmPassword) {
+      setFormError('Las contraseñas no coinciden');
+      return;
+    }
+    if (!formData.acceptTerms) {
+      setFormError('Debes aceptar los términos de consentimiento para activar la cuenta');
+      return;
+    }
+
+    setLoading(true);
+    try {
+      const result = await acceptInvitation({
+        token,
+        password: formData.password,
+        first_name: formData.firstName,
+        last_name: formData.lastName,
+        accept_terms: formData.acceptTerms,
+        terms_version: terms?.terms_version,
+      });
+
+      if (result.success) {
+        navigate('/dashboard', { replace: true });
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <Container component="main" maxWidth="sm">
+      <Box
+        sx={{
+          minHeight: '100vh',
+          display: 'flex',
+          flexDirection: 'column',
+          justifyContent: 'center',
+          alignItems: 'center',
+          py: 4,
+        }}
+      >
+        <Paper elevation={8} sx={{ p: 4, width: '100%', maxWidth: 400, borderRadius: 3 }}>
+          <Box sx={{ textAlign: 'center', mb: 4 }}>
+            <Typography variant="h4" component="h1" fontWeight="bold" color="primary" gutterBottom>
+              Vincula
+            </Typography>
+            <Typography variant="h5" component="h2" gutterBottom>
+              Aceptar Invitación
+            </Typography>
+            <Typography variant="body2" color="text.secondary">
+              Completa tus datos para activar tu cuenta de personal
+            </Typography>
+          </Box>
+
+          <Divider sx={{ mb: 3 }} />
+
+          {!token ? (
+            <Alert severity="error">
+              El enlace de invitación no es válido. Solicita uno nuevo a un administrador.
+            </Alert>
+          ) : (
+            <Box component="form" onSubmit={handleSubmit}>
+              {(formError || error) && (
+                <Alert severity="error" sx={{ mb: 3 }}>
+                  {formError || error}
+                </Alert>
+              )}
+
+              <Stack spacing={2}>
+                <TextField
+                  required
+                  fullWidth
+                  name="firstName"
+                  label="Nombre"
+                  value={formData.firstName}
+                  onChange={handleChange}
+                  InputProps={{ startAdornment: <Person sx={{ color: 'action.active', mr: 1 }} /> }}
+                />
+                <TextField
+                  required
+                  fullWidth
+                  name="lastName"
+                  label="Apellido"
+                  value={formData.lastName}
+                  onChange={handleChange}
+                  InputProps={{ startAdornment: <Person sx={{ color: 'action.active', mr: 1 }} /> }}
+                />
+                <TextField
+                  required
+                  fullWidth
+                  name="password"
+                  label="Contraseña"
+                  type="password"
+                  value={formData.password}
+                  onChange={handleChange}
+                  InputProps={{ startAdornment: <Lock sx={{ color: 'action.active', mr: 1 }} /> }}
+                />
+                <TextField
+                  required
+                  fullWidth
+                  name="confirmPassword"
+                  label="Confirmar Contraseña"
+                  type="password"
+                  value={formData.confirmPassword}
+                  onChange={handleChange}
+                  InputProps={{ startAdornment: <Lock sx={{ color: 'action.active', mr: 1 }} /> }}
+                />
+                <FormControlLabel
+                  control={
+                    <Checkbox
+                      name="acceptTerms"
+                      checked={formData.acceptTerms}
+                      onChange={handleChange}
+                      color="primary"
+                    />
+                  }
+                  label={
+                    <Typography variant="body2">
+                      He leído y acepto los{' '}
+                      {terms?.document_url ? (
+                        <a href={terms.document_url} target="_blank" rel="noopener noreferrer">
+                          términos de consentimiento
+                        </a>
+                      ) : 'términos de consentimiento'}
+                      {terms?.terms_version && ` (versión ${terms.terms_version})`}.
+                    </Typography>
+                  }
+                />
+
+                <Button
+                  type="submit"
+                  fullWidth
+                  variant="contained"
+                  size="large"
+                  disabled={loading}
+                  startIcon={loading ? <CircularProgress size={20} /> : <PersonAdd />}
+                  sx={{ py: 1.5, fontWeight: 600 }}
+                >
+                  {loading ? 'Activando cuenta...' : 'Activar Cuenta'}
+                </Button>
+              </Stack>
+            </Box>
+          )}
+
+          <Divider sx={{ my: 3 }} />
+
+          <Typography variant="body2" color="text.secondary" align="center">
+            ¿Ya tienes cuenta?{' '}
+            <Link to="/login" style={{ color: '#1976D2', textDecoration: 'none', fontWeight: 500 }}>
+              Iniciar Sesión
+            </Link>
+          </Typography>
+        </Paper>
+      </Box>
+    </Container>
+  );
+};
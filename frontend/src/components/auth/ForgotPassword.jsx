@@ -0,0 +1,102 @@
+import React, { useState } from 'react';
+import { Link } from 'react-router-dom';
+import {
+  Container,
+  Paper,
+  Box,
+  TextField,
+  Button,
+  Typography,
+  Alert,
+  Divider,
+  CircularProgress,
+} from '@mui/material';
+import { Email, Send } from '@mui/icons-material';
+
+// Solicitud de enlace de recuperación de contraseña
+export const ForgotPassword = () => {
+  const [email, setEmail] = useState('');
+  const [loading, setLoading] = useState(false);
+  const [sent, setSent] = useState(false);
+  const [error, setError] = useState(null);
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setLoading(true);
+    setError(null);
+
+    try {
+      const apiUrl = process.env.REACT_APP_API_URL || '/api';
+      const response = await fetch(`${apiUrl}/auth/password/forgot`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ email: email.trim() }),
+      });
+
+      if (!response.ok) {
+        const errorData = await response.json().catch(() => ({}));
+        throw new Error(errorData.error || 'No se pudo enviar el enlace');
+      }
+
+      setSent(true);
+    } catch (err) {
+      setError(err.message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <Container component="main" maxWidth="sm">
+      <Box sx={{ minHeight: '100vh', display: 'flex', alignItems: 'center', justifyContent: 'center', py: 4 }}>
+        <Paper elevation={8} sx={{ p: 4, width: '100%', maxWidth: 400, borderRadius: 3 }}>
+          <Box sx={{ textAlign: 'center', mb: 3 }}>
+            <Typography variant="h4" component="h1" fontWeight="bold" color="primary" gutterBottom>
+              Vincula
+            </Typography>
+            <Typography variant="h5" component="h2" gutterBottom>
+              Recuperar Contraseña
+            </Typography>
+          </Box>
+
+          {sent ? (
+            <Alert severity="success">
+              Si existe una cuenta con ese correo, recibirás un enlace para restablecer la contraseña.
+            </Alert>
+          ) : (
+            <Box component="form" onSubmit={handleSubmit}>
+              {error && <Alert severity="error" sx={{ mb: 2 }}>{error}</Alert>}
+              <TextField
+                required
+                fullWidth
+                type="email"
+                label="Correo Electrónico"
+                value={email}
+                onChange={(e) => setEmail(e.target.value)}
+                InputProps={{ startAdornment: <Email sx={{ color: 'action.active', mr: 1 }} /> }}
+                sx={{ mb: 3 }}
+              />
+              <Button
+                type="submit"
+                fullWidth
+                variant="contained"
+                size="large"
+                disabled={loading}
+                startIcon={loading ? <CircularProgress size={20} /> : <Send />}
+              >
+                Enviar Enlace
+              </Button>
+            </Box>
+          )}
+
+          <Divider sx={{ my: 3 }} />
+          <Typography variant="body2" align="center">
+            <Link to="/login" style={{ color: '#1976D2', textDecoration: 'none', fontWeight: 500 }}>
+              Volver a Iniciar Sesión
+            </Link>
+          </Typography>
+        </Paper>
+      </Box>
+    </Container>
+  );
+};
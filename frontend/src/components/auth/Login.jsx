@@ -1,5 +1,5 @@
-import React, { useState } from 'react';
-import { useNavigate, Link } from 'react-router-dom';
+import React, { useEffect, useRef, useState } from 'react';
+import { useNavigate, useLocation, Link } from 'react-router-dom';
 import {
   Container,
   Paper,
@@ -17,6 +17,7 @@ import {
   Email,
   Lock,
   Login as LoginIcon,
+  Business,
 } from '@mui/icons-material';
 import { useAuthStore } from '../../stores/authStore';
 
@@ -24,9 +25,43 @@ export const Login = () => {
   const [email, setEmail] = useState('');
   const [password, setPassword] = useState('');
   const [loading, setLoading] = useState(false);
+  // Segundo factor: { token, enrollment, secret, otpauthUrl, recoveryCodes }
+  const [mfa, setMfa] = useState(null);
+  const [mfaCode, setMfaCode] = useState('');
+  const [useRecoveryCode, setUseRecoveryCode] = useState(false);
+  // SSO del personal de clínicas: { enabled, name }
+  const [sso, setSso] = useState(null);
+  const challengeStarted = useRef(false);
   
   const navigate = useNavigate();
-  const { login, error } = useAuthStore();
+  const location = useLocation();
+  const { login, completeMfaLogin, startMfaEnrollment, confirmMfaEnrollment, error } = useAuthStore();
+  const apiUrl = process.env.REACT_APP_API_URL || '/api';
+
+  useEffect(() => {
+    fetch(`${apiUrl}/auth/oidc/config`)
+      .then((response) => (response.ok ? response.json() : null))
+      .then(setSso)
+      .catch(() => setSso(null));
+  }, [apiUrl]);
+
+  const startMfaChallenge = async (result) => {
+    const challenge = { token: result.mfaToken, enrollment: result.mfaEnrollmentRequired };
+    if (challenge.enrollment) {
+      const enrollment = await startMfaEnrollment(result.mfaToken);
+      Object.assign(challenge, enrollment);
+    }
+    setMfa(challenge);
+  };
+
+  // Un login SSO que exige segundo factor llega aquí con el reto ya emitido
+  useEffect(() => {
+    const challenge = location.state?.mfaChallenge;
+    if (!challenge || challengeStarted.current) return;
+    challengeStarted.current = true;
+    startMfaChallenge(challenge);
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [location.state]);
 
   const handleSubmit = async (e) => {
     e.preventDefault();
@@ -39,6 +74,8 @@ export const Login = () => {
       if (result.success) {
         console.log('Login successful, navigating to dashboard...');
         navigate('/dashboard', { replace: true });
+      } else if (result.mfaRequired) {
+        await startMfaChallenge(result);
       } else {
         console.error('Login failed:', result.error);
       }
@@ -49,6 +86,31 @@ export const Login = () => {
     }
   };
 
+  const handleMfaSubmit = async (e) => {
+    e.preventDefault();
+    setLoading(true);
+
+    try {
+      if (mfa.enrollment) {
+        const result = await confirmMfaEnrollment(mfa.token, mfaCode.trim());
+        if (result.success) {
+          // Los códigos de recuperación solo se muestran una vez
+          setMfa({ ...mfa, recoveryCodes: result.recoveryCodes });
+        }
+        return;
+      }
+
+      const result = await completeMfaLogin(mfa.token, useRecoveryCode
+        ? { recoveryCode: mfaCode.trim() }
+        : { code: mfaCode.trim() });
+      if (result.success) {
+        navigate('/dashboard', { replace: true });
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
   return (
     <Container component="main" maxWidth="sm">
       <Box
@@ -86,8 +148,95 @@ export const Login = () => {
 
           <Divider sx={{ mb: 3 }} />
 
+          {/* Segundo factor */}
+          {mfa && (
+            <Box component="form" onSubmit={handleMfaSubmit} noValidate>
+              {error && (
+                <Alert severity="error" sx={{ mb: 3 }}>
+                  {error}
+                </Alert>
+              )}
+
+              {mfa.recoveryCodes ? (
+                <Stack spacing={2}>
+                  <Alert severity="success">
+                    Verificación en dos pasos activada. Guarda estos códigos de recuperación en un lugar
+                    seguro: cada uno sirve una sola vez si pierdes el teléfono.
+                  </Alert>
+                  <Paper variant="outlined" sx={{ p: 2, fontFamily: 'monospace', textAlign: 'center' }}>
+                    {mfa.recoveryCodes.map((code) => (
+                      <Typography key={code} sx={{ fontFamily: 'monospace' }}>{code}</Typography>
+                    ))}
+                  </Paper>
+                  <Button variant="contained" onClick={() => navigate('/dashboard', { replace: true })}>
+                    Continuar
+                  </Button>
+                </Stack>
+              ) : (
+                <Stack spacing={2}>
+                  {mfa.enrollment ? (
+                    <>
+                      <Alert severity="info">
+                        Tu cuenta requiere verificación en dos pasos. Añádela a tu app de autenticación
+                        y escribe el código que genera.
+                      </Alert>
+                      {mfa.otpauthUrl && (
+                        <Button href={mfa.otpauthUrl} variant="outlined">
+                          Abrir en la app de autenticación
+                        </Button>
+                      )}
+                      {mfa.secret && (
+                        <Typography variant="body2" sx={{ fontFamily: 'monospace', wordBreak: 'break-all', textAlign: 'center' }}>
+                          {mfa.secret}
+                        </Typography>
+                      )}
+                    </>
+                  ) : (
+                    <Typography variant="body2" color="text.secondary">
+                      {useRecoveryCode
+                        ? 'Introduce uno de tus códigos de recuperación.'
+                        : 'Introduce el código de 6 dígitos de tu app de autenticación.'}
+                    </Typography>
+                  )}
+
+                  <TextField
+                    required
+                    fullWidth
+                    autoFocus
+                    label={useRecoveryCode ? 'Código de recuperación' : 'Código de verificación'}
+                    value={mfaCode}
+                    onChange={(e) => setMfaCode(e.target.value)}
+                    inputProps={{ autoComplete: 'one-time-code' }}
+                    InputProps={{
+                      startAdornment: (
+                        <Lock sx={{ color: 'action.active', mr: 1 }} />
+                      ),
+                    }}
+                  />
+
+                  <Button
+                    type="submit"
+                    fullWidth
+                    variant="contained"
+                    size="large"
+                    disabled={loading || !mfaCode}
+                    startIcon={loading ? <CircularProgress size={20} /> : <LoginIcon />}
+                  >
+                    Verificar
+                  </Button>
+
+                  {!mfa.enrollment && (
+                    <Button size="small" onClick={() => { setUseRecoveryCode(!useRecoveryCode); setMfaCode(''); }}>
+                      {useRecoveryCode ? 'Usar código de la app' : 'Usar un código de recuperación'}
+                    </Button>
+                  )}
+                </Stack>
+              )}
+            </Box>
+          )}
+
           {/* Formulario */}
-          <Box component="form" onSubmit={handleSubmit} noValidate>
+          <Box component="form" onSubmit={handleSubmit} noValidate sx={{ display: mfa ? 'none' : 'block' }}>
             {error && (
               <Alert severity="error" sx={{ mb: 3 }}>
                 {error}
@@ -149,10 +298,35 @@ export const Login = () => {
               {loading ? 'Iniciando sesión...' : 'Iniciar Sesión'}
             </Button>
 
+            {sso?.enabled && (
+              <Button
+                fullWidth
+                variant="outlined"
+                size="large"
+                href={`${apiUrl}/auth/oidc/login`}
+                startIcon={<Business />}
+                sx={{ mb: 3 }}
+              >
+                Entrar con {sso.name}
+              </Button>
+            )}
+
             <Divider sx={{ mb: 3 }} />
 
             {/* Links */}
             <Stack spacing={2} alignItems="center">
+              <Typography variant="body2">
+                <Link 
+                  to="/forgot-password" 
+                  style={{ 
+                    color: '#1976D2', 
+                    textDecoration: 'none',
+                    fontWeight: 500,
+                  }}
+                >
+                  ¿Olvidaste tu contraseña?
+                </Link>
+              </Typography>
               <Typography variant="body2" color="text.secondary">
                 ¿No tienes cuenta?{' '}
                 <Link 
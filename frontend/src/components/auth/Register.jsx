@@ -1,4 +1,4 @@
-import React, { useState } from 'react';
+import React, { useEffect, useState } from 'react';
 import { useNavigate, Link } from 'react-router-dom';
 import {
   Container,
@@ -43,15 +43,21 @@ export const Register = () => {
     firstName: '',
     lastName: '',
     role: 'patient',
+    acceptTerms: false,
     recordingConsent: false,
     livestreamConsent: false
   });
   const [loading, setLoading] = useState(false);
   const [showConsentDialog, setShowConsentDialog] = useState(false);
   const [consentErrors, setConsentErrors] = useState({});
+  const [terms, setTerms] = useState(null);
   
   const navigate = useNavigate();
-  const { register, error } = useAuthStore();
+  const { register, getConsentTerms, error } = useAuthStore();
+
+  useEffect(() => {
+    getConsentTerms().then(setTerms).catch(() => setTerms(null));
+  }, [getConsentTerms]);
 
   const handleChange = (e) => {
     const { name, value, type, checked } = e.target;
@@ -79,6 +85,9 @@ export const Register = () => {
 
     // Validar consentimientos obligatorios
     const errors = {};
+    if (!formData.acceptTerms) {
+      errors.acceptTerms = 'Debe aceptar los términos de consentimiento para crear la cuenta';
+    }
     if (!formData.recordingConsent) {
       errors.recordingConsent = 'Debe aceptar la grabación automática de llamadas';
     }
@@ -100,9 +109,10 @@ export const Register = () => {
         first_name: formData.firstName,
         last_name: formData.lastName,
         role: formData.role,
+        accept_terms: formData.acceptTerms,
+        terms_version: terms?.terms_version,
         recording_consent: formData.recordingConsent,
-        livestream_consent: formData.livestreamConsent,
-        consent_accepted_at: new Date().toISOString()
+        livestream_consent: formData.livestreamConsent
       };
 
       const result = await register(userData);
@@ -237,12 +247,6 @@ export const Register = () => {
                       <Typography>Paciente</Typography>
                     </Stack>
                   </MenuItem>
-                  <MenuItem value="employee">
-                    <Stack direction="row" alignItems="center" spacing={1}>
-                      <PersonAdd fontSize="small" />
-                      <Typography>Empleado Médico</Typography>
-                    </Stack>
-                  </MenuItem>
                   <MenuItem value="family">
                     <Stack direction="row" alignItems="center" spacing={1}>
                       <Group fontSize="small" />
@@ -289,7 +293,7 @@ export const Register = () => {
                 bgcolor: 'grey.50', 
                 borderRadius: 2,
                 border: '1px solid',
-                borderColor: (consentErrors.recordingConsent || consentErrors.livestreamConsent) ? 'error.main' : 'grey.300'
+                borderColor: (consentErrors.acceptTerms || consentErrors.recordingConsent || consentErrors.livestreamConsent) ? 'error.main' : 'grey.300'
               }}>
                 <Stack spacing={1}>
                   <Typography variant="subtitle1" fontWeight="bold" color="text.primary">
@@ -297,6 +301,34 @@ export const Register = () => {
                   </Typography>
                   
                   <FormGroup>
+                    <FormControlLabel
+                      control={
+                        <Checkbox
+                          name="acceptTerms"
+                          checked={formData.acceptTerms}
+                          onChange={handleChange}
+                          color="primary"
+                          required
+                        />
+                      }
+                      label={
+                        <Typography variant="body2">
+                          He leído y acepto los{' '}
+                          {terms?.document_url ? (
+                            <a href={terms.document_url} target="_blank" rel="noopener noreferrer">
+                              términos de consentimiento
+                            </a>
+                          ) : 'términos de consentimiento'}
+                          {terms?.terms_version && ` (versión ${terms.terms_version})`}.
+                        </Typography>
+                      }
+                    />
+                    {consentErrors.acceptTerms && (
+                      <Typography variant="caption" color="error" sx={{ ml: 4 }}>
+                        {consentErrors.acceptTerms}
+                      </Typography>
+                    )}
+
                     <FormControlLabel
                       control={
                         <Checkbox 
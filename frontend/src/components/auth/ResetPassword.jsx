@@ -0,0 +1,123 @@
+import React, { useState } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import {
+  Container,
+  Paper,
+  Box,
+  TextField,
+  Button,
+  Typography,
+  Alert,
+  Stack,
+  Divider,
+  CircularProgress,
+} from '@mui/material';
+import { Lock, LockReset } from '@mui/icons-material';
+
+// Nueva contraseña a partir del enlace de recuperación
+export const ResetPassword = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+
+  const [password, setPassword] = useState('');
+  const [confirmPassword, setConfirmPassword] = useState('');
+  const [loading, setLoading] = useState(false);
+  const [done, setDone] = useState(false);
+  const [error, setError] = useState(null);
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    setError(null);
+
+    if (password !== confirmPassword) {
+      setError('Las contraseñas no coinciden');
+      return;
+    }
+
+    setLoading(true);
+    try {
+      const apiUrl = process.env.REACT_APP_API_URL || '/api';
+      const response = await fetch(`${apiUrl}/auth/password/reset`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ token, password }),
+      });
+
+      if (!response.ok) {
+        throw new Error('El enlace no es válido o ha expirado');
+      }
+
+      setDone(true);
+    } catch (err) {
+      setError(err.message);
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <Container component="main" maxWidth="sm">
+      <Box sx={{ minHeight: '100vh', display: 'flex', alignItems: 'center', justifyContent: 'center', py: 4 }}>
+        <Paper elevation={8} sx={{ p: 4, width: '100%', maxWidth: 400, borderRadius: 3 }}>
+          <Box sx={{ textAlign: 'center', mb: 3 }}>
+            <Typography variant="h4" component="h1" fontWeight="bold" color="primary" gutterBottom>
+              Vincula
+            </Typography>
+            <Typography variant="h5" component="h2" gutterBottom>
+              Nueva Contraseña
+            </Typography>
+          </Box>
+
+          {done ? (
+            <Alert severity="success">
+              Contraseña actualizada. Se han cerrado todas tus sesiones; inicia sesión de nuevo.
+            </Alert>
+          ) : !token ? (
+            <Alert severity="error">El enlace no es válido. Solicita uno nuevo.</Alert>
+          ) : (
+            <Box component="form" onSubmit={handleSubmit}>
+              {error && <Alert severity="error" sx={{ mb: 2 }}>{error}</Alert>}
+              <Stack spacing={2}>
+                <TextField
+                  required
+                  fullWidth
+                  type="password"
+                  label="Nueva Contraseña"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  InputProps={{ startAdornment: <Lock sx={{ color: 'action.active', mr: 1 }} /> }}
+                />
+                <TextField
+                  required
+                  fullWidth
+                  type="password"
+                  label="Confirmar Contraseña"
+                  value={confirmPassword}
+                  onChange={(e) => setConfirmPassword(e.target.value)}
+                  InputProps={{ startAdornment: <Lock sx={{ color: 'action.active', mr: 1 }} /> }}
+                />
+                <Button
+                  type="submit"
+                  fullWidth
+                  variant="contained"
+                  size="large"
+                  disabled={loading}
+                  startIcon={loading ? <CircularProgress size={20} /> : <LockReset />}
+                >
+                  Guardar Contraseña
+                </Button>
+              </Stack>
+            </Box>
+          )}
+
+          <Divider sx={{ my: 3 }} />
+          <Typography variant="body2" align="center">
+            <Link to="/login" style={{ color: '#1976D2', textDecoration: 'none', fontWeight: 500 }}>
+              Ir a Iniciar Sesión
+            </Link>
+          </Typography>
+        </Paper>
+      </Box>
+    </Container>
+  );
+};
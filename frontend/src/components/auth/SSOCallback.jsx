@@ -0,0 +1,84 @@
+import React, { useEffect, useRef, useState } from 'react';
+import { Link, useNavigate } from 'react-router-dom';
+import {
+  Container,
+  Paper,
+  Box,
+  Typography,
+  Alert,
+  CircularProgress,
+} from '@mui/material';
+import { useAuthStore } from '../../stores/authStore';
+
+const ssoErrors = {
+  access_denied: 'Se canceló el inicio de sesión en el proveedor de identidad.',
+  invalid_state: 'La sesión de inicio caducó. Vuelve a intentarlo.',
+  email_not_verified: 'El proveedor de identidad no ha verificado tu correo.',
+  domain_not_allowed: 'Tu correo no pertenece a una organización autorizada.',
+  no_role: 'Tu cuenta no tiene ningún grupo con acceso a Vincula. Contacta con tu administrador.',
+  account_inactive: 'Tu cuenta está desactivada.',
+  link_required: 'Ya existe una cuenta de personal con tu correo. Pide a un administrador que autorice su vinculación con el SSO.',
+  account_deleted: 'Tu cuenta está eliminada. Pide a un administrador que la restaure.',
+};
+
+// Destino del callback del SSO: canjea el código del fragmento por la sesión
+export const SSOCallback = () => {
+  const navigate = useNavigate();
+  const { exchangeSsoCode } = useAuthStore();
+  const [error, setError] = useState(null);
+  const requested = useRef(false);
+
+  useEffect(() => {
+    // El código es de un solo uso: evitar el doble envío de StrictMode
+    if (requested.current) return;
+    requested.current = true;
+
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    // Quitar el código de la barra de direcciones y del historial
+    window.history.replaceState(null, '', window.location.pathname);
+
+    if (params.get('error') || !params.get('code')) {
+      setError(ssoErrors[params.get('error')] || 'No se pudo iniciar sesión con SSO.');
+      return;
+    }
+
+    const redirect = params.get('redirect') || '/dashboard';
+    exchangeSsoCode(params.get('code')).then((result) => {
+      if (result.success) {
+        navigate(redirect === '/' ? '/dashboard' : redirect, { replace: true });
+      } else if (result.mfaRequired) {
+        // El segundo factor se completa en la pantalla de login
+        navigate('/login', { replace: true, state: { mfaChallenge: result } });
+      } else {
+        setError(result.error || 'No se pudo iniciar sesión con SSO.');
+      }
+    });
+  }, [exchangeSsoCode, navigate]);
+
+  return (
+    <Container component="main" maxWidth="sm">
+      <Box sx={{ minHeight: '100vh', display: 'flex', alignItems: 'center', justifyContent: 'center', py: 4 }}>
+        <Paper elevation={8} sx={{ p: 4, width: '100%', maxWidth: 400, borderRadius: 3, textAlign: 'center' }}>
+          <Typography variant="h4" component="h1" fontWeight="bold" color="primary" gutterBottom>
+            Vincula
+          </Typography>
+
+          {error ? (
+            <>
+              <Alert severity="error" sx={{ my: 2 }}>
+                {error}
+              </Alert>
+              <Typography variant="body2">
+                <Link to="/login" style={{ color: '#1976D2', textDecoration: 'none', fontWeight: 500 }}>
+                  Volver al inicio de sesión
+                </Link>
+              </Typography>
+            </>
+          ) : (
+            <CircularProgress sx={{ my: 3 }} />
+          )}
+        </Paper>
+      </Box>
+    </Container>
+  );
+};
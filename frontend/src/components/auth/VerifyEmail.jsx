@@ -0,0 +1,63 @@
+import React, { useEffect, useRef, useState } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import {
+  Container,
+  Paper,
+  Box,
+  Typography,
+  Alert,
+  CircularProgress,
+} from '@mui/material';
+
+// Confirma el correo con el token del enlace enviado al registrarse
+export const VerifyEmail = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+  const [status, setStatus] = useState(token ? 'loading' : 'error');
+  const requested = useRef(false);
+
+  useEffect(() => {
+    // El token es de un solo uso: evitar el doble envío de StrictMode
+    if (!token || requested.current) return;
+    requested.current = true;
+
+    const apiUrl = process.env.REACT_APP_API_URL || '/api';
+    fetch(`${apiUrl}/auth/email/verify`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ token }),
+    })
+      .then((response) => setStatus(response.ok ? 'success' : 'error'))
+      .catch(() => setStatus('error'));
+  }, [token]);
+
+  return (
+    <Container component="main" maxWidth="sm">
+      <Box sx={{ minHeight: '100vh', display: 'flex', alignItems: 'center', justifyContent: 'center', py: 4 }}>
+        <Paper elevation={8} sx={{ p: 4, width: '100%', maxWidth: 400, borderRadius: 3, textAlign: 'center' }}>
+          <Typography variant="h4" component="h1" fontWeight="bold" color="primary" gutterBottom>
+            Vincula
+          </Typography>
+
+          {status === 'loading' && <CircularProgress sx={{ my: 3 }} />}
+          {status === 'success' && (
+            <Alert severity="success" sx={{ my: 2 }}>
+              Tu correo ha sido verificado. Ya puedes unirte a la cola de atención.
+            </Alert>
+          )}
+          {status === 'error' && (
+            <Alert severity="error" sx={{ my: 2 }}>
+              El enlace no es válido o ha expirado. Puedes solicitar uno nuevo desde tu panel.
+            </Alert>
+          )}
+
+          <Typography variant="body2">
+            <Link to="/dashboard" style={{ color: '#1976D2', textDecoration: 'none', fontWeight: 500 }}>
+              Ir al panel
+            </Link>
+          </Typography>
+        </Paper>
+      </Box>
+    </Container>
+  );
+};
@@ -0,0 +1,166 @@
+import React, { useState, useEffect } from 'react';
+import {
+  Alert,
+  Box,
+  Button,
+  Checkbox,
+  CircularProgress,
+  Dialog,
+  DialogActions,
+  DialogContent,
+  DialogTitle,
+  FormControlLabel,
+  FormGroup,
+  Link,
+  Stack,
+  Typography,
+} from '@mui/material';
+import { Description, RadioButtonChecked, Videocam } from '@mui/icons-material';
+import { apiRequest } from '../../utils/api';
+
+// Aceptación de la versión vigente del documento de consentimiento. La piden los
+// pacientes que no se registraron con el formulario (invitados, SSO, importados)
+// y todos cuando cambia CONSENT_TERMS_VERSION; sin ella no pueden unirse a la cola.
+function ConsentDialog({ open, onClose, onAccepted }) {
+  const [status, setStatus] = useState(null);
+  const [terms, setTerms] = useState(false);
+  const [recording, setRecording] = useState(false);
+  const [livestream, setLivestream] = useState(false);
+  const [saving, setSaving] = useState(false);
+  const [error, setError] = useState(null);
+
+  const loadStatus = async () => {
+    try {
+      const current = await apiRequest('/api/consents');
+      setStatus(current);
+      setRecording(current.recording_consent);
+      setLivestream(current.livestream_consent);
+    } catch (err) {
+      console.error('Error loading consent status:', err);
+      setError('No se pudo cargar el documento de consentimiento');
+    }
+  };
+
+  useEffect(() => {
+    if (open) {
+      setTerms(false);
+      setError(null);
+      loadStatus();
+    }
+  }, [open]);
+
+  const handleAccept = async () => {
+    try {
+      setSaving(true);
+      setError(null);
+      await apiRequest('/api/consents', {
+        method: 'POST',
+        body: JSON.stringify({
+          version: status.terms_version,
+          terms: true,
+          recording,
+          livestream,
+        }),
+      });
+      onAccepted();
+    } catch (err) {
+      // La versión ha cambiado mientras el diálogo estaba abierto
+      if (err?.response?.status === 409) {
+        setError('El documento se ha actualizado, revísalo de nuevo');
+        setTerms(false);
+        loadStatus();
+      } else {
+        setError('No se pudo guardar el consentimiento');
+      }
+    } finally {
+      setSaving(false);
+    }
+  };
+
+  return (
+    <Dialog open={open} onClose={onClose} maxWidth="sm" fullWidth>
+      <DialogTitle>
+        <Typography variant="h5" color="primary" fontWeight="bold">
+          Consentimiento informado
+        </Typography>
+      </DialogTitle>
+      <DialogContent>
+        {!status && !error && (
+          <Box sx={{ display: 'flex', justifyContent: 'center', py: 3 }}>
+            <CircularProgress />
+          </Box>
+        )}
+        {error && (
+          <Alert severity="error" sx={{ mb: 2 }}>
+            {error}
+          </Alert>
+        )}
+        {status && (
+          <Stack spacing={2}>
+            <Typography variant="body1" color="text.secondary">
+              Para solicitar una videollamada debes aceptar la versión {status.terms_version} del
+              documento de consentimiento.
+            </Typography>
+            {status.document_url && (
+              <Stack direction="row" spacing={1} alignItems="center">
+                <Description color="primary" />
+                <Link href={status.document_url} target="_blank" rel="noopener noreferrer">
+                  Leer el documento de consentimiento
+                </Link>
+              </Stack>
+            )}
+            <FormGroup>
+              <FormControlLabel
+                control={
+                  <Checkbox checked={terms} onChange={(e) => setTerms(e.target.checked)} color="primary" />
+                }
+                label={
+                  <Typography variant="body2">
+                    He leído y acepto el <strong>documento de consentimiento</strong>.
+                  </Typography>
+                }
+              />
+              <FormControlLabel
+                control={
+                  <Checkbox checked={recording} onChange={(e) => setRecording(e.target.checked)} color="primary" />
+                }
+                label={
+                  <Stack direction="row" spacing={1} alignItems="center">
+                    <RadioButtonChecked sx={{ fontSize: 18, color: 'error.main' }} />
+                    <Typography variant="body2">
+                      Acepto que mis videollamadas sean <strong>grabadas</strong> para fines médicos y de seguimiento.
+                    </Typography>
+                  </Stack>
+                }
+              />
+              <FormControlLabel
+                control={
+                  <Checkbox checked={livestream} onChange={(e) => setLivestream(e.target.checked)} color="primary" />
+                }
+                label={
+                  <Stack direction="row" spacing={1} alignItems="center">
+                    <Videocam sx={{ fontSize: 18, color: 'warning.main' }} />
+                    <Typography variant="body2">
+                      Acepto que mis videollamadas puedan ser <strong>vistas en vivo</strong> por familiares
+                      autorizados y personal médico.
+                    </Typography>
+                  </Stack>
+                }
+              />
+            </FormGroup>
+          </Stack>
+        )}
+      </DialogContent>
+      <DialogActions sx={{ pb: 3, px: 3 }}>
+        <Button onClick={onClose} disabled={saving}>
+          Ahora no
+        </Button>
+        <Button variant="contained" onClick={handleAccept} disabled={!status || !terms || saving}>
+          {saving ? <CircularProgress size={24} /> : 'Aceptar'}
+        </Button>
+      </DialogActions>
+    </Dialog>
+  );
+}
+
+export default ConsentDialog;
@@ -79,6 +79,7 @@ import { useAuthStore } from '../../stores/authStore';
 import { useCallStore } from '../../stores/callStore';
 import { useWebSocket, useCallRedirection } from '../../hooks/useWebSocket';
 import { apiRequest } from '../../utils/api';
+import ConsentDialog from './ConsentDialog';
 
 function PatientDashboard() {
   const { user, handleAuthError, logout } = useAuthStore();
@@ -88,6 +89,7 @@ function PatientDashboard() {
   const [estimatedWaitTime, setEstimatedWaitTime] = useState(null);
   const [loadingQueue, setLoadingQueue] = useState(false);
   const [showWelcomeDialog, setShowWelcomeDialog] = useState(false);
+  const [showConsentDialog, setShowConsentDialog] = useState(false);
 
   // Estados para el historial de llamadas
   const [callHistory, setCallHistory] = useState([]);
@@ -129,6 +131,10 @@ function PatientDashboard() {
       if (error?.response?.status === 401) {
         handleAuthError(error);
       }
+      // Sin el consentimiento vigente la cola rechaza al paciente
+      if (error?.response?.status === 403 && error?.data?.consent_required) {
+        setShowConsentDialog(true);
+      }
     } finally {
       setLoadingQueue(false);
     }
@@ -234,11 +240,24 @@ function PatientDashboard() {
     });
   };
 
+  // Pide el consentimiento antes de que el paciente intente unirse a la cola
+  const checkConsent = async () => {
+    try {
+      const status = await apiRequest('/api/consents');
+      if (status.consent_required) {
+        setShowConsentDialog(true);
+      }
+    } catch (error) {
+      console.error('Error checking consent status:', error);
+    }
+  };
+
   // Efecto para cargar datos iniciales
   useEffect(() => {
     if (user?.id) {
       checkQueueStatus();
       fetchCallHistory();
+      checkConsent();
       setShowWelcomeDialog(true);
     }
   }, [user?.id]);
@@ -819,6 +838,13 @@ function PatientDashboard() {
 
         {/* Dialog de bienvenida */}
         <WelcomeDialog />
+
+        {/* Consentimiento informado */}
+        <ConsentDialog
+          open={showConsentDialog && !showWelcomeDialog}
+          onClose={() => setShowConsentDialog(false)}
+          onAccepted={() => setShowConsentDialog(false)}
+        />
       </Container>
     </Box>
   );
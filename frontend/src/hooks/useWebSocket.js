@@ -1,6 +1,7 @@
 import { useEffect, useRef, useState, useCallback } from 'react';
 import { useNavigate } from 'react-router-dom';
 import { useAuthStore } from '../stores/authStore';
+import { apiRequest } from '../utils/api';
 
 // Use environment variable for WebSocket URL, fallback to current location
 const getWsUrl = () => {
@@ -23,7 +24,7 @@ export const useWebSocket = () => {
   const { token, user } = useAuthStore();
 
   // Conectar al WebSocket
-  const connect = useCallback(() => {
+  const connect = useCallback(async () => {
     if (!token || !user) {
       console.log('No token or user available for WebSocket connection');
       return;
@@ -44,9 +45,14 @@ export const useWebSocket = () => {
     }
 
     try {
-      // Construir URL con token de autenticación usando base relativo si no hay env
+      // El access token no viaja en la URL: se canjea por un ticket de un solo uso (30s)
+      const { ticket } = await apiRequest('/auth/ws-ticket', { method: 'POST' });
+      if (wsRef.current && wsRef.current.readyState <= WebSocket.OPEN) {
+        return;
+      }
+
       const base = WS_URL || (window.location.protocol.replace('http', 'ws') + '//' + window.location.host);
-      const wsUrl = `${base}/ws?token=${encodeURIComponent(token)}&user_id=${user.id}&role=${user.role}`;
+      const wsUrl = `${base}/ws?ticket=${encodeURIComponent(ticket)}`;
       
       console.log('Connecting to WebSocket...');
       wsRef.current = new WebSocket(wsUrl);
@@ -56,15 +62,11 @@ export const useWebSocket = () => {
         setIsConnected(true);
         setReconnectAttempts(0);
         
-        // Enviar mensaje de autenticación después de que el WebSocket esté conectado
+        // La identidad ya la fijó el ticket; el mensaje solo pide la confirmación
         if (wsRef.current && wsRef.current.readyState === WebSocket.OPEN) {
           const authMessage = {
             type: 'auth',
-            data: {
-              user_id: user.id,
-              role: user.role,
-              token: token
-            },
+            data: {},
             timestamp: new Date().toISOString(),
             user_id: user.id
           };
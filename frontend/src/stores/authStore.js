@@ -1,6 +1,25 @@
 import { create } from 'zustand';
 import { persist, createJSONStorage } from 'zustand/middleware';
 
+// POST a los endpoints públicos de autenticación; lanza Error con el mensaje del backend
+const postAuth = async (path, body, fallbackError) => {
+  const apiUrl = process.env.REACT_APP_API_URL || '/api';
+  const response = await fetch(`${apiUrl}${path}`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify(body),
+  });
+
+  if (!response.ok) {
+    const errorData = await response.json().catch(() => ({}));
+    throw new Error(errorData.error || fallbackError);
+  }
+
+  return response.json();
+};
+
 const useAuthStore = create(
   persist(
     (set, get) => ({
@@ -32,9 +51,21 @@ const useAuthStore = create(
           }
 
           const data = await response.json();
+
+          // Segundo factor: el login devuelve un token intermedio en lugar de la sesión
+          if (data.mfa_required) {
+            set({ isLoading: false });
+            return {
+              success: false,
+              mfaRequired: true,
+              mfaEnrollmentRequired: data.mfa_enrollment_required,
+              mfaToken: data.mfa_token,
+            };
+          }
           
           // Persistir en localStorage manualmente para asegurar que se guarde
           localStorage.setItem('token', data.token);
+          localStorage.setItem('refreshToken', data.refresh_token);
           localStorage.setItem('user', JSON.stringify(data.user));
           
           set({
@@ -71,11 +102,15 @@ const useAuthStore = create(
           });
 
           if (!response.ok) {
-            const errorData = await response.json();
-            throw new Error(errorData.message || 'Error al registrar usuario');
+            const errorData = await response.json().catch(() => ({}));
+            throw new Error(errorData.error || 'Error al registrar usuario');
           }
 
           const data = await response.json();
+
+          localStorage.setItem('token', data.token);
+          localStorage.setItem('refreshToken', data.refresh_token);
+          localStorage.setItem('user', JSON.stringify(data.user));
           
           set({
             user: data.user,
@@ -96,9 +131,164 @@ const useAuthStore = create(
         }
       },
 
+      // Segundo paso del login con código TOTP o código de recuperación
+      completeMfaLogin: async (mfaToken, { code, recoveryCode }) => {
+        set({ isLoading: true, error: null });
+
+        try {
+          const data = await postAuth('/auth/mfa/login', {
+            mfa_token: mfaToken,
+            code,
+            recovery_code: recoveryCode,
+          }, 'Código incorrecto');
+          get().setSession(data);
+          return { success: true, user: data.user };
+        } catch (error) {
+          set({ isLoading: false, error: error.message });
+          return { success: false, error: error.message };
+        }
+      },
+
+      // Alta de MFA obligatoria durante el login (sin sesión todavía)
+      startMfaEnrollment: async (mfaToken) => {
+        try {
+          const data = await postAuth('/auth/mfa/enroll', { mfa_token: mfaToken }, 'No se pudo iniciar el alta de MFA');
+          return { success: true, secret: data.secret, otpauthUrl: data.otpauth_url };
+        } catch (error) {
+          return { success: false, error: error.message };
+        }
+      },
+
+      confirmMfaEnrollment: async (mfaToken, code) => {
+        set({ isLoading: true, error: null });
+
+        try {
+          const data = await postAuth('/auth/mfa/enroll/confirm', { mfa_token: mfaToken, code }, 'Código incorrecto');
+          if (data.session) {
+            get().setSession(data.session);
+          } else {
+            set({ isLoading: false });
+          }
+          return { success: true, recoveryCodes: data.recovery_codes };
+        } catch (error) {
+          set({ isLoading: false, error: error.message });
+          return { success: false, error: error.message };
+        }
+      },
+
+      // Canjea el código de un solo uso que devuelve el callback del SSO
+      exchangeSsoCode: async (code) => {
+        set({ isLoading: true, error: null });
+
+        try {
+          const data = await postAuth('/auth/oidc/exchange', { code }, 'No se pudo completar el inicio de sesión');
+          if (data.mfa_required) {
+            set({ isLoading: false });
+            return {
+              success: false,
+              mfaRequired: true,
+              mfaEnrollmentRequired: data.mfa_enrollment_required,
+              mfaToken: data.mfa_token,
+            };
+          }
+          get().setSession(data);
+          return { success: true, user: data.user };
+        } catch (error) {
+          set({ isLoading: false, error: error.message });
+          return { success: false, error: error.message };
+        }
+      },
+
+      // Guarda la sesión devuelta por el backend
+      setSession: (data) => {
+        localStorage.setItem('token', data.token);
+        localStorage.setItem('refreshToken', data.refresh_token);
+        localStorage.setItem('user', JSON.stringify(data.user));
+
+        set({
+          user: data.user,
+          token: data.token,
+          isAuthenticated: true,
+          isLoading: false,
+          error: null,
+          connectionStatus: 'connected'
+        });
+      },
+
+      // Versión vigente del documento de consentimiento que deben aceptar las altas
+      getConsentTerms: async () => {
+        const apiUrl = process.env.REACT_APP_API_URL || '/api';
+        const response = await fetch(`${apiUrl}/auth/consent-terms`);
+        if (!response.ok) {
+          throw new Error('No se pudo cargar el documento de consentimiento');
+        }
+        return response.json();
+      },
+
+      // Alta de personal mediante invitación
+      acceptInvitation: async (invitationData) => {
+        set({ isLoading: true, error: null });
+
+        try {
+          const apiUrl = process.env.REACT_APP_API_URL || '/api';
+          const response = await fetch(`${apiUrl}/auth/invitations/accept`, {
+            method: 'POST',
+            headers: {
+              'Content-Type': 'application/json',
+            },
+            body: JSON.stringify(invitationData),
+          });
+
+          if (!response.ok) {
+            const errorData = await response.json().catch(() => ({}));
+            throw new Error(errorData.error || 'La invitación no es válida o ha expirado');
+          }
+
+          const data = await response.json();
+
+          localStorage.setItem('token', data.token);
+          localStorage.setItem('refreshToken', data.refresh_token);
+          localStorage.setItem('user', JSON.stringify(data.user));
+
+          set({
+            user: data.user,
+            token: data.token,
+            isAuthenticated: true,
+            isLoading: false,
+            error: null,
+            connectionStatus: 'connected'
+          });
+
+          return { success: true, user: data.user };
+        } catch (error) {
+          set({
+            isLoading: false,
+            error: error.message,
+          });
+          return { success: false, error: error.message };
+        }
+      },
+
       // Cerrar sesión
       logout: () => {
+        const { token } = get();
+        const refreshToken = localStorage.getItem('refreshToken');
+
+        // Revocar la sesión en el servidor (sin bloquear el cierre local)
+        if (token) {
+          const apiUrl = process.env.REACT_APP_API_URL || '/api';
+          fetch(`${apiUrl}/auth/logout`, {
+            method: 'POST',
+            headers: {
+              'Content-Type': 'application/json',
+              'Authorization': `Bearer ${token}`,
+            },
+            body: JSON.stringify({ refresh_token: refreshToken }),
+          }).catch(() => {});
+        }
+
         localStorage.removeItem('token');
+        localStorage.removeItem('refreshToken');
         localStorage.removeItem('user');
         set({ 
           isAuthenticated: false, 
@@ -286,9 +476,9 @@ const useAuthStore = create(
 
       // Refrescar token
       refreshToken: async () => {
-        const { token } = get();
+        const refreshToken = localStorage.getItem('refreshToken');
         
-        if (!token) {
+        if (!refreshToken) {
           return false;
         }
 
@@ -297,8 +487,9 @@ const useAuthStore = create(
           const response = await fetch(`${apiUrl}/auth/refresh`, {
             method: 'POST',
             headers: {
-              'Authorization': `Bearer ${token}`,
+              'Content-Type': 'application/json',
             },
+            body: JSON.stringify({ refresh_token: refreshToken }),
           });
 
           if (!response.ok) {
@@ -307,6 +498,10 @@ const useAuthStore = create(
           }
 
           const data = await response.json();
+
+          localStorage.setItem('token', data.token);
+          localStorage.setItem('refreshToken', data.refresh_token);
+          localStorage.setItem('user', JSON.stringify(data.user));
           
           set({
             token: data.token,
@@ -1,7 +1,33 @@
 // Use environment variable for API URL, fallback to relative path for development
 const API_BASE_URL = process.env.REACT_APP_API_URL || '/api';
 
-export const apiRequest = async (endpoint, options = {}) => {
+// Intercambia el refresh token por un nuevo access token; devuelve false si no es posible
+const refreshAccessToken = async () => {
+  const refreshToken = localStorage.getItem('refreshToken');
+  if (!refreshToken) {
+    return false;
+  }
+
+  try {
+    const response = await fetch(`${API_BASE_URL}/auth/refresh`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    });
+    if (!response.ok) {
+      return false;
+    }
+
+    const data = await response.json();
+    localStorage.setItem('token', data.token);
+    localStorage.setItem('refreshToken', data.refresh_token);
+    return true;
+  } catch (error) {
+    return false;
+  }
+};
+
+export const apiRequest = async (endpoint, options = {}, retried = false) => {
   const token = localStorage.getItem('token');
   
   const defaultOptions = {
@@ -26,10 +52,16 @@ export const apiRequest = async (endpoint, options = {}) => {
     if (!response.ok) {
       const errorData = await response.json().catch(() => ({}));
       
-      // Si es error 401, limpiar token y redirigir
+      // Si es error 401, intentar renovar el access token una vez
+      if (response.status === 401 && !retried && await refreshAccessToken()) {
+        return apiRequest(endpoint, options, true);
+      }
+
+      // Si sigue siendo 401, limpiar sesión y redirigir
       if (response.status === 401) {
         console.log('Authentication error detected, clearing session...');
         localStorage.removeItem('token');
+        localStorage.removeItem('refreshToken');
         localStorage.removeItem('user');
         
         // Solo redirigir si no estamos ya en login
@@ -42,6 +74,8 @@ export const apiRequest = async (endpoint, options = {}) => {
       
       const error = new Error(errorData.message || `HTTP ${response.status}: ${response.statusText}`);
       error.response = { status: response.status, statusText: response.statusText };
+      // Cuerpo de la respuesta (p. ej. consent_required al unirse a la cola)
+      error.data = errorData;
       throw error;
     }
 
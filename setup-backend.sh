@@ -40,8 +40,8 @@ if [ ! -f .env ]; then
     echo ""
     echo "Creando archivo .env para producción..."
     
-    # Generar JWT secret aleatorio
-    JWT_SECRET=$(openssl rand -hex 32)
+    # Generar clave de cifrado aleatoria (los JWT se firman con claves que genera user-service)
+    MFA_ENCRYPTION_KEY=$(openssl rand -hex 32)
     POSTGRES_PASSWORD=$(openssl rand -hex 16)
     LIVEKIT_SECRET=$(openssl rand -hex 32)
     
@@ -52,7 +52,7 @@ POSTGRES_USER=vincula_user
 POSTGRES_PASSWORD=${POSTGRES_PASSWORD}
 
 # Seguridad
-JWT_SECRET=${JWT_SECRET}
+MFA_ENCRYPTION_KEY=${MFA_ENCRYPTION_KEY}
 
 # LiveKit (para videollamadas y grabación)
 LIVEKIT_API_KEY=devkey